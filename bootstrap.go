@@ -6,12 +6,15 @@ import (
 	"github.com/YasiruR/didcomm-prober/didcomm/connection"
 	"github.com/YasiruR/didcomm-prober/didcomm/did"
 	"github.com/YasiruR/didcomm-prober/didcomm/invitation"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/YasiruR/didcomm-prober/log"
 	"github.com/YasiruR/didcomm-prober/prober"
 	"github.com/YasiruR/didcomm-prober/pubsub"
 	reqRepZmq "github.com/YasiruR/didcomm-prober/reqrep/zmq"
+	"github.com/YasiruR/didcomm-prober/store"
 	zmq "github.com/pebbe/zmq4"
 	log2 "log"
 	"net"
@@ -47,20 +50,47 @@ func setConfigs(args *container.Args) *container.Config {
 
 func initContainer(cfg *container.Config) *container.Container {
 	logger := log.NewLogger(cfg.Args.Verbose, 3, log.LevelTrace)
-	packer := crypto.NewPacker(logger)
-	km := crypto.NewKeyManager()
+	packers := map[domain.Envelope]services.Packer{
+		domain.EnvelopeRFC19: crypto.NewPacker(logger),
+		domain.EnvelopeV2:    crypto.NewJWEPacker(logger),
+	}
+	var km services.KeyManager = crypto.NewKeyManager()
+	if cfg.Wallet != `` {
+		w, err := crypto.NewFileWallet(cfg.Wallet, cfg.WalletPw)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf(`initializing wallet failed - %v`, err))
+		}
+		km = w
+	}
+
+	var cs services.ConnectionStore = store.NewMemory()
+	var gs services.GroupStore = store.NewMemoryGroups()
+	if cfg.Store != `` {
+		db, err := store.NewBolt(cfg.Store)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf(`initializing persistent store failed - %v`, err))
+		}
+		cs, gs = db, db
+	}
+
 	ctx, err := zmq.NewContext()
 	if err != nil {
 		logger.Fatal(fmt.Sprintf(`zmq context initialization failed - %v`, err))
 	}
 
+	didHandler := did.NewHandler()
+	resolver := did.NewRegistry(didHandler)
 	c := &container.Container{
 		Cfg:          cfg,
 		KeyManager:   km,
-		Packer:       packer,
-		DidAgent:     did.NewHandler(),
+		ConnStore:    cs,
+		GroupStore:   gs,
+		Packers:      packers,
+		Signer:       crypto.NewJWSSigner(),
+		DidAgent:     didHandler,
+		Resolver:     resolver,
 		Connector:    connection.NewConnector(),
-		OOB:          invitation.NewOOBService(cfg),
+		OOB:          invitation.NewOOBService(cfg, didHandler, resolver),
 		Client:       reqRepZmq.NewClient(ctx, logger),
 		Log:          logger,
 		ConnDoneChan: make(chan models.Connection),
@@ -78,6 +108,9 @@ func initContainer(cfg *container.Config) *container.Container {
 		logger.Fatal(`initializing prober failed`, err)
 	}
 	c.Prober = prb
+	if cfg.Mediator {
+		c.Mediator = prb
+	}
 
 	c.PubSub, err = pubsub.NewAgent(ctx, c)
 	if err != nil {
@@ -7,16 +7,21 @@ import (
 	"github.com/YasiruR/didcomm-prober/didcomm/discovery"
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/models"
 	"github.com/YasiruR/didcomm-prober/domain/services"
 	internalLog "github.com/YasiruR/didcomm-prober/log"
 	"github.com/tryfix/log"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
+const walletPwEnv = `PROBER_WALLET_PASSPHRASE`
+
 type runner struct {
 	cfg     *container.Config
 	reader  *bufio.Reader
@@ -36,6 +41,11 @@ func ParseArgs() *container.Args {
 	mocker := flag.Bool(`mock`, true, `enables mocking functions`)
 	mockPort := flag.Int(`mock_port`, 0, `port for mocking functions`)
 	v := flag.Bool(`v`, false, `logging`)
+	env := flag.String(`envelope`, `rfc19`, `preferred envelope for connections [rfc19,v2]`)
+	wallet := flag.String(`wallet`, ``, `path of the encrypted wallet file to persist keys (passphrase is read from `+walletPwEnv+`)`)
+	db := flag.String(`store`, ``, `path of the database file to persist connections and groups`)
+	mediator := flag.Bool(`mediator`, false, `enables the mediator role to relay forward messages`)
+	disclose := flag.String(`disclose`, `*`, `comma separated globs of the features disclosed to peers (eg: https://didcomm.org/*)`)
 	flag.Parse()
 
 	if *mocker == true && *mockPort == 0 {
@@ -43,6 +53,20 @@ func ParseArgs() *container.Args {
 		os.Exit(0)
 	}
 
+	envelope := domain.EnvelopeRFC19
+	if *env == `v2` {
+		envelope = domain.EnvelopeV2
+	} else if *env != `rfc19` {
+		fmt.Println("envelope should either be rfc19 or v2 (see -h or --help for details)")
+		os.Exit(0)
+	}
+
+	walletPw := os.Getenv(walletPwEnv)
+	if *wallet != `` && walletPw == `` {
+		fmt.Println(walletPwEnv + " should be set when the wallet is enabled (see -h or --help for details)")
+		os.Exit(0)
+	}
+
 	return &container.Args{
 		Name:     *n,
 		Port:     *p,
@@ -50,6 +74,12 @@ func ParseArgs() *container.Args {
 		Mocker:   *mocker,
 		MockPort: *mockPort,
 		Verbose:  *v,
+		Envelope: envelope,
+		Wallet:   *wallet,
+		WalletPw: walletPw,
+		Store:    *db,
+		Mediator: *mediator,
+		Disclose: strings.Split(*disclose, `,`),
 	}
 }
 
@@ -87,6 +117,14 @@ basicCmds:
 		"[7] Leave group\n\t" +
 		"[8] Group Info\n\t" +
 		"[9] Discover Features\n\t" +
+		"[10] Rotate connection keys\n\t" +
+		"[11] Trust ping\n\t" +
+		"[12] Pick up queued messages\n\t" +
+		"[13] Request mediation\n\t" +
+		"[14] Set disclosure policy\n\t" +
+		"[15] Generate connectionless invitation\n\t" +
+		"[16] List invitations\n\t" +
+		"[17] Revoke invitation\n\t" +
 		"[b] Back\n\t" +
 		"[e] Exit\n   Command: ")
 	atomic.AddUint64(&r.disCmds, 1)
@@ -116,6 +154,22 @@ basicCmds:
 		r.groupInfo()
 	case "9":
 		r.discover()
+	case "10":
+		r.rotate()
+	case "11":
+		r.ping()
+	case "12":
+		r.pickup()
+	case "13":
+		r.mediate()
+	case "14":
+		r.disclosurePolicy()
+	case "15":
+		r.connectionlessInvitation()
+	case "16":
+		r.listInvitations()
+	case "17":
+		r.revokeInvitation()
 	case "b":
 
 	case "e":
@@ -164,7 +218,23 @@ func (r *runner) listen() {
 /* command specific functions */
 
 func (r *runner) generateInvitation() {
-	inv, err := r.prober.Invite()
+	expiry, err := strconv.Atoi(r.input(`Expiry in minutes (0 for no expiry)`))
+	if err != nil || expiry < 0 {
+		r.error(`expiry should be a non-negative integer`, err)
+		return
+	}
+
+	maxUses, err := strconv.Atoi(r.input(`Maximum uses (0 for unlimited)`))
+	if err != nil || maxUses < 0 {
+		r.error(`maximum uses should be a non-negative integer`, err)
+		return
+	}
+
+	inv, err := r.prober.InviteWith(models.InvOptions{
+		Expiry:  time.Duration(expiry) * time.Minute,
+		MaxUses: maxUses,
+		Goal:    r.input(`Goal (leave empty to skip)`),
+	})
 	if err != nil {
 		r.error(`generating invitation failed`, err)
 		return
@@ -173,6 +243,82 @@ func (r *runner) generateInvitation() {
 	r.output(fmt.Sprintf("Invitation URL: %s", inv), true)
 }
 
+// connectionlessInvitation attaches a basic message and/or a trust ping to an
+// invitation which does not establish a connection
+func (r *runner) connectionlessInvitation() {
+	var reqs []interface{}
+	if text := r.input(`Message (leave empty to skip)`); text != `` {
+		reqs = append(reqs, messages.BasicMessage{
+			Message:  messages.NewMessage(messages.BasicMessageV1),
+			SentTime: time.Now().UTC().Format(messages.TimeFormat),
+			Content:  text,
+		})
+	}
+
+	ping, err := r.validBool(r.input(`Request trust ping response (Y/N)`))
+	if err != nil {
+		r.error(`invalid input`, err)
+		return
+	}
+
+	if ping {
+		reqs = append(reqs, messages.Ping{Message: messages.NewMessage(messages.TrustPingV1), ResponseRequested: true})
+	}
+
+	inv, err := r.prober.InviteConnectionless(reqs...)
+	if err != nil {
+		r.error(`generating connectionless invitation failed`, err)
+		return
+	}
+
+	r.output(fmt.Sprintf("Invitation URL: %s", inv), true)
+}
+
+func (r *runner) listInvitations() {
+	invs, err := r.prober.Invitations()
+	if err != nil {
+		r.error(`fetching invitations failed`, err)
+		return
+	}
+
+	if len(invs) == 0 {
+		r.output(`No invitations found`, true)
+		return
+	}
+
+	var lines []string
+	for _, inv := range invs {
+		status := `active`
+		if inv.Expired() {
+			status = `expired`
+		} else if inv.Exhausted() {
+			status = `exhausted`
+		}
+
+		expiry, maxUses := `never`, `unlimited`
+		if !inv.Expiry.IsZero() {
+			expiry = inv.Expiry.Format(time.RFC3339)
+		}
+		if inv.MaxUses > 0 {
+			maxUses = strconv.Itoa(inv.MaxUses)
+		}
+
+		lines = append(lines, fmt.Sprintf(`%s [%s] created: %s, expires: %s, uses: %d/%s, goal: '%s'`,
+			inv.Id, status, inv.Created.Format(time.RFC3339), expiry, inv.Uses, maxUses, inv.Goal))
+	}
+	r.outputList(`Invitations`, lines)
+}
+
+func (r *runner) revokeInvitation() {
+	id := r.input(`Invitation ID`)
+	if err := r.prober.RevokeInvitation(id); err != nil {
+		r.error(`revoking invitation failed`, err)
+		return
+	}
+
+	r.output(fmt.Sprintf(`Invitation %s revoked`, id), true)
+}
+
 func (r *runner) connectWithInv() {
 	u, err := url.Parse(r.input(`Provide invitation in URL form`))
 	if err != nil {
@@ -194,17 +340,114 @@ func (r *runner) connectWithInv() {
 func (r *runner) sendMsg() {
 	peer := r.input(`Recipient`)
 	msg := r.input(`Message`)
+	strAnon := r.input(`Anonymous (Y/N)`)
+
+	anon, err := r.validBool(strAnon)
+	if err != nil {
+		r.error(`invalid input`, err)
+		return
+	}
+
+	if anon {
+		err = r.prober.SendAnonMessage(models.TypData, peer, msg)
+	} else {
+		err = r.prober.SendMessage(models.TypData, peer, msg)
+	}
 
-	if err := r.prober.SendMessage(models.TypData, peer, msg); err != nil {
+	if err != nil {
 		r.error(`sending message failed`, err)
 	}
 }
 
+func (r *runner) rotate() {
+	peer := r.input(`Peer`)
+	if err := r.prober.Rotate(peer); err != nil {
+		r.error(`rotating connection keys failed`, err)
+		return
+	}
+	r.output(`Rotated keys of the connection with `+peer, true)
+}
+
+func (r *runner) ping() {
+	peer := r.input(`Peer`)
+	count, err := strconv.Atoi(r.input(`Number of pings`))
+	if err != nil || count < 1 {
+		r.error(`number of pings should be a positive integer`, err)
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		rtt, err := r.prober.Ping(peer)
+		if err != nil {
+			r.error(`trust ping failed`, err)
+			continue
+		}
+		r.output(fmt.Sprintf(`Ping response from %s: rtt=%s`, peer, rtt), i == 0)
+	}
+
+	stats, ok := r.prober.PingStats(peer)
+	if !ok {
+		return
+	}
+
+	r.output(fmt.Sprintf(`%d sent, %d received, min/avg/max = %s/%s/%s`, stats.Sent, stats.Received, stats.Min, stats.Avg, stats.Max), true)
+}
+
+func (r *runner) pickup() {
+	mediator := r.input(`Mediator`)
+	count, err := r.prober.QueuedMessages(mediator)
+	if err != nil {
+		r.error(`fetching status of the queued messages failed`, err)
+		return
+	}
+
+	if count == 0 {
+		r.output(`No queued messages found at `+mediator, true)
+		return
+	}
+
+	n, err := r.prober.Pickup(mediator)
+	if err != nil {
+		r.error(`picking up queued messages failed`, err)
+	}
+	r.output(fmt.Sprintf(`Picked up %d of %d queued messages from %s`, n, count, mediator), true)
+}
+
+func (r *runner) mediate() {
+	mediator := r.input(`Mediator`)
+	if err := r.prober.RequestMediation(mediator); err != nil {
+		r.error(`requesting mediation failed`, err)
+		return
+	}
+
+	keys, err := r.prober.MediatedKeys(mediator)
+	if err != nil {
+		r.error(`querying keys registered with the mediator failed`, err)
+		return
+	}
+	r.output(fmt.Sprintf(`Subsequent connections are routed through %s (%d keys registered)`, mediator, len(keys)), true)
+}
+
 func (r *runner) discover() {
-	endpoint := r.input(`Endpoint`)
-	query := r.input(`Query`)
-	comment := r.input(`Comment`)
-	features, err := r.disc.Query(endpoint, query, comment)
+	peer := r.input(`Peer (label or DID)`)
+	protocols := r.input(`Protocol query (eg: https://didcomm.org/*)`)
+	goals := r.input(`Goal code query`)
+
+	var queries []messages.FeatureQuery
+	if protocols != `` {
+		queries = append(queries, messages.FeatureQuery{FeatureType: models.FeatureProtocol, Match: protocols})
+	}
+
+	if goals != `` {
+		queries = append(queries, messages.FeatureQuery{FeatureType: models.FeatureGoalCode, Match: goals})
+	}
+
+	if len(queries) == 0 {
+		r.error(`either a protocol or a goal code query is required`, nil)
+		return
+	}
+
+	features, err := r.disc.Query(peer, queries...)
 	if err != nil {
 		r.error(`discovering features failed, please try again`, err)
 		return
@@ -212,17 +455,33 @@ func (r *runner) discover() {
 
 	var list []string
 	for _, f := range features {
+		if f.Type == models.FeatureGoalCode {
+			list = append(list, fmt.Sprintf(`Goal code: "%s"`, f.Id))
+			continue
+		}
 		list = append(list, fmt.Sprintf(`Protocol: "%s", Roles: %v`, f.Id, f.Roles))
 	}
 	r.outputList(`Supported features`, list)
 }
 
+func (r *runner) disclosurePolicy() {
+	peer := r.input(`Peer (* for all peers)`)
+	globs := strings.Split(r.input(`Disclosed features (comma separated globs)`), `,`)
+	for i, g := range globs {
+		globs[i] = strings.TrimSpace(g)
+	}
+
+	r.disc.SetPolicy(peer, globs...)
+	r.output(fmt.Sprintf(`Features disclosed to %s are restricted to %v`, peer, globs), true)
+}
+
 func (r *runner) createGroup() {
 	topic := r.input(`Topic`)
 	strPub := r.input(`Publisher (Y/N)`)
 	mode := r.input(`Group queue mode [single,multiple] (S/M)`)
 	strJoinConsist := r.input(`Strict consistency for join operation (Y/N)`)
 	strOrdrd := r.input(`Causal consistency for group messages (Y/N)`)
+	strSignd := r.input(`Sign group messages (Y/N)`)
 	//mode, strJoinConsist, strOrdrd, strPub = `m`, `y`, `y`, `y`
 
 	publisher, err := r.validBool(strPub)
@@ -243,6 +502,12 @@ func (r *runner) createGroup() {
 		return
 	}
 
+	signd, err := r.validBool(strSignd)
+	if err != nil {
+		r.error(`invalid input`, err)
+		return
+	}
+
 	var gm domain.GroupMode
 	if mode == `s` || mode == `S` {
 		gm = domain.SingleQueueMode
@@ -251,7 +516,7 @@ func (r *runner) createGroup() {
 	}
 
 	if err = r.pubsub.Create(topic, publisher,
-		models.GroupParams{OrderEnabled: ordrd, JoinConsistent: joinConsist, Mode: gm},
+		models.GroupParams{OrderEnabled: ordrd, JoinConsistent: joinConsist, Mode: gm, Signed: signd},
 	); err != nil {
 		r.error(`create group failed`, err)
 		return
@@ -1,8 +1,12 @@
 package crypto
 
 import (
+	"crypto/ed25519"
+	"fmt"
 	chacha "github.com/GoKillers/libsodium-go/crypto/aead/chacha20poly1305ietf"
+	xchacha "github.com/GoKillers/libsodium-go/crypto/aead/xchacha20poly1305ietf"
 	"github.com/GoKillers/libsodium-go/cryptobox"
+	"github.com/GoKillers/libsodium-go/cryptosign"
 )
 
 const (
@@ -14,65 +18,136 @@ type encryptor struct{}
 // Box encrypts the given message with nonce, receiver's public key and
 // sender's private key. MAC and encrypted message are stored together.
 func (e *encryptor) Box(payload, nonce, peerPubKey, mySecKey []byte) (encMsg []byte, err error) {
-	nonce = e.modifyNonce(nonce)
-	encMsg, _ = cryptobox.CryptoBoxEasy(payload, nonce, peerPubKey, mySecKey)
+	if len(nonce) != nonceBytes {
+		return nil, fmt.Errorf(`invalid nonce length (%d) for crypto box`, len(nonce))
+	}
+
+	encMsg, exit := cryptobox.CryptoBoxEasy(payload, nonce, peerPubKey, mySecKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`crypto box failed with exit code %d`, exit)
+	}
 	return encMsg, nil
 }
 
 func (e *encryptor) BoxOpen(cipher, nonce, peerPubKey, mySecKey []byte) (msg []byte, err error) {
-	nonce = e.modifyNonce(nonce)
-	msg, _ = cryptobox.CryptoBoxOpenEasy(cipher, nonce, peerPubKey, mySecKey)
+	if len(nonce) != nonceBytes {
+		return nil, fmt.Errorf(`invalid nonce length (%d) for crypto box`, len(nonce))
+	}
+
+	msg, exit := cryptobox.CryptoBoxOpenEasy(cipher, nonce, peerPubKey, mySecKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`crypto box open failed with exit code %d`, exit)
+	}
 	return msg, nil
 }
 
 func (e *encryptor) SealBox(payload, peerPubKey []byte) (encMsg []byte, err error) {
-	encMsg, _ = cryptobox.CryptoBoxSeal(payload, peerPubKey)
+	encMsg, exit := cryptobox.CryptoBoxSeal(payload, peerPubKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`sealed box failed with exit code %d`, exit)
+	}
 	return encMsg, nil
 }
 
 func (e *encryptor) SealBoxOpen(cipher, peerPubKey, mySecKey []byte) (msg []byte, err error) {
-	msg, _ = cryptobox.CryptoBoxSealOpen(cipher, peerPubKey, mySecKey)
+	msg, exit := cryptobox.CryptoBoxSealOpen(cipher, peerPubKey, mySecKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`sealed box open failed with exit code %d`, exit)
+	}
 	return msg, nil
 }
 
+// EncryptDetached uses xchacha20poly1305_ietf for 24-byte nonces and
+// chacha20poly1305_ietf for 12-byte nonces
 func (e *encryptor) EncryptDetached(msg, protectedVal string, nonce, key []byte) (cipher, mac []byte, err error) {
-	var convertedIv [chacha.NonceBytes]byte
-	copy(convertedIv[:], nonce)
+	if len(key) != chacha.KeyBytes {
+		return nil, nil, fmt.Errorf(`invalid content encryption key length (%d)`, len(key))
+	}
+
+	switch len(nonce) {
+	case xchacha.NonceBytes:
+		var convertedIv [xchacha.NonceBytes]byte
+		copy(convertedIv[:], nonce)
+
+		var convertedCek [xchacha.KeyBytes]byte
+		copy(convertedCek[:], key)
+
+		cipher, mac = xchacha.EncryptDetached([]byte(msg), []byte(protectedVal), &convertedIv, &convertedCek)
+	case chacha.NonceBytes:
+		var convertedIv [chacha.NonceBytes]byte
+		copy(convertedIv[:], nonce)
 
-	var convertedCek [chacha.KeyBytes]byte
-	copy(convertedCek[:], key)
+		var convertedCek [chacha.KeyBytes]byte
+		copy(convertedCek[:], key)
+
+		cipher, mac = chacha.EncryptDetached([]byte(msg), []byte(protectedVal), &convertedIv, &convertedCek)
+	default:
+		return nil, nil, fmt.Errorf(`invalid nonce length (%d) for aead encryption`, len(nonce))
+	}
 
-	cipher, mac = chacha.EncryptDetached([]byte(msg), []byte(protectedVal), &convertedIv, &convertedCek)
 	return cipher, mac, nil
 }
 
 func (e *encryptor) DecryptDetached(cipher, mac, protectedVal, nonce, key []byte) (msg []byte, err error) {
-	var convertedIv [chacha.NonceBytes]byte
-	copy(convertedIv[:], nonce)
+	if len(key) != chacha.KeyBytes {
+		return nil, fmt.Errorf(`invalid content encryption key length (%d)`, len(key))
+	}
+
+	if len(mac) != chacha.ABytes {
+		return nil, fmt.Errorf(`invalid mac length (%d)`, len(mac))
+	}
+
+	switch len(nonce) {
+	case xchacha.NonceBytes:
+		var convertedIv [xchacha.NonceBytes]byte
+		copy(convertedIv[:], nonce)
+
+		var convertedCek [xchacha.KeyBytes]byte
+		copy(convertedCek[:], key)
+
+		msg, err = xchacha.DecryptDetached(cipher, mac, protectedVal, &convertedIv, &convertedCek)
+	case chacha.NonceBytes:
+		var convertedIv [chacha.NonceBytes]byte
+		copy(convertedIv[:], nonce)
+
+		var convertedCek [chacha.KeyBytes]byte
+		copy(convertedCek[:], key)
+
+		msg, err = chacha.DecryptDetached(cipher, mac, protectedVal, &convertedIv, &convertedCek)
+	default:
+		return nil, fmt.Errorf(`invalid nonce length (%d) for aead decryption`, len(nonce))
+	}
 
-	var convertedCek [chacha.KeyBytes]byte
-	copy(convertedCek[:], key)
-	msg, err = chacha.DecryptDetached(cipher, mac, protectedVal, &convertedIv, &convertedCek)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf(`aead decryption failed - %v`, err)
 	}
 
 	return msg, nil
 }
 
-// modifyNonce adds noise or trim the byte slice to the cek nonce such that it
-// satisfies crypto box open function
-func (e *encryptor) modifyNonce(nonce []byte) []byte {
-	if len(nonce) < nonceBytes {
-		for {
-			if len(nonce) == nonceBytes {
-				break
-			}
-			nonce = append(nonce, 48)
-		}
-	} else if len(nonce) > nonceBytes {
-		nonce = nonce[:nonceBytes]
-	}
-
-	return nonce
+// x25519PubKey converts an Ed25519 verification key to the X25519 public
+// key used for key agreement
+func x25519PubKey(edPubKey []byte) ([]byte, error) {
+	if len(edPubKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf(`invalid verification key length (%d)`, len(edPubKey))
+	}
+
+	key, exit := cryptosign.CryptoSignEd25519PkToCurve25519(edPubKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`converting verification key failed with exit code %d`, exit)
+	}
+	return key, nil
+}
+
+// x25519PrvKey converts an Ed25519 private key to the X25519 private key
+func x25519PrvKey(edPrvKey []byte) ([]byte, error) {
+	if len(edPrvKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf(`invalid private key length (%d)`, len(edPrvKey))
+	}
+
+	key, exit := cryptosign.CryptoSignEd25519SkToCurve25519(edPrvKey)
+	if exit != 0 {
+		return nil, fmt.Errorf(`converting private key failed with exit code %d`, exit)
+	}
+	return key, nil
 }
@@ -0,0 +1,207 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+)
+
+/* JSON Web Algorithms (RFC-7518) required by DIDComm v2 envelopes */
+
+const (
+	algECDH1PU  = `ECDH-1PU+A256KW`
+	algECDHES   = `ECDH-ES+A256KW`
+	encA256CBC  = `A256CBC-HS512`
+	cbcKeyBytes = 64
+	cbcIvBytes  = 16
+	kekBytes    = 32
+)
+
+var kwDefaultIv = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// ecdh computes the shared secret of X25519 key agreement
+func ecdh(prvKey, pubKey []byte) ([]byte, error) {
+	z, err := curve25519.X25519(prvKey, pubKey)
+	if err != nil {
+		return nil, fmt.Errorf(`x25519 key agreement failed - %v`, err)
+	}
+	return z, nil
+}
+
+// concatKDF derives the key encryption key with the single-step KDF defined in
+// NIST SP 800-56A as used by ECDH-ES (RFC-7518 section 4.6.2). Tag is appended
+// to the supplementary public info only for ECDH-1PU as per draft-04 of the spec.
+func concatKDF(z []byte, alg string, apu, apv, tag []byte) []byte {
+	lenPrefixed := func(b []byte) []byte {
+		out := make([]byte, 4, 4+len(b))
+		binary.BigEndian.PutUint32(out, uint32(len(b)))
+		return append(out, b...)
+	}
+
+	var otherInfo []byte
+	otherInfo = append(otherInfo, lenPrefixed([]byte(alg))...)
+	otherInfo = append(otherInfo, lenPrefixed(apu)...)
+	otherInfo = append(otherInfo, lenPrefixed(apv)...)
+
+	keyDataLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(keyDataLen, kekBytes*8)
+	otherInfo = append(otherInfo, keyDataLen...)
+	if tag != nil {
+		otherInfo = append(otherInfo, lenPrefixed(tag)...)
+	}
+
+	// a single round is sufficient since the digest size equals the key size
+	h := sha256.New()
+	h.Write([]byte{0, 0, 0, 1})
+	h.Write(z)
+	h.Write(otherInfo)
+	return h.Sum(nil)
+}
+
+// keyWrap implements AES key wrap algorithm (RFC-3394)
+func keyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, fmt.Errorf(`invalid key length to be wrapped (%d)`, len(key))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf(`initializing aes cipher failed - %v`, err)
+	}
+
+	n := len(key) / 8
+	r := make([]byte, len(key))
+	copy(r, key)
+	a := make([]byte, 8)
+	copy(a, kwDefaultIv)
+
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf[:8], a)
+			copy(buf[8:], r[i*8:(i+1)*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r[i*8:(i+1)*8], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// keyUnwrap reverses keyWrap and validates the integrity check value
+func keyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf(`invalid wrapped key length (%d)`, len(wrapped))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf(`initializing aes cipher failed - %v`, err)
+	}
+
+	n := len(wrapped)/8 - 1
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[i*8:(i+1)*8])
+			block.Decrypt(buf, buf)
+
+			copy(a, buf[:8])
+			copy(r[i*8:(i+1)*8], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, kwDefaultIv) != 1 {
+		return nil, errors.New(`integrity check of the wrapped key failed`)
+	}
+
+	return r, nil
+}
+
+// cbcHmacEncrypt implements AES_256_CBC_HMAC_SHA_512 authenticated
+// encryption (RFC-7518 section 5.2.5)
+func cbcHmacEncrypt(key, iv, plaintext, aad []byte) (ciphertext, tag []byte, err error) {
+	if len(key) != cbcKeyBytes {
+		return nil, nil, fmt.Errorf(`invalid content encryption key length (%d)`, len(key))
+	}
+
+	block, err := aes.NewCipher(key[cbcKeyBytes/2:])
+	if err != nil {
+		return nil, nil, fmt.Errorf(`initializing aes cipher failed - %v`, err)
+	}
+
+	// PKCS#7 padding
+	padLen := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := make([]byte, len(plaintext)+padLen)
+	copy(padded, plaintext)
+	for i := len(plaintext); i < len(padded); i++ {
+		padded[i] = byte(padLen)
+	}
+
+	ciphertext = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return ciphertext, cbcTag(key[:cbcKeyBytes/2], iv, ciphertext, aad), nil
+}
+
+func cbcHmacDecrypt(key, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if len(key) != cbcKeyBytes {
+		return nil, fmt.Errorf(`invalid content encryption key length (%d)`, len(key))
+	}
+
+	if len(iv) != cbcIvBytes {
+		return nil, fmt.Errorf(`invalid iv length (%d)`, len(iv))
+	}
+
+	if !hmac.Equal(tag, cbcTag(key[:cbcKeyBytes/2], iv, ciphertext, aad)) {
+		return nil, errors.New(`authentication tag mismatch`)
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf(`invalid ciphertext length (%d)`, len(ciphertext))
+	}
+
+	block, err := aes.NewCipher(key[cbcKeyBytes/2:])
+	if err != nil {
+		return nil, fmt.Errorf(`initializing aes cipher failed - %v`, err)
+	}
+
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+
+	padLen := int(padded[len(padded)-1])
+	if padLen == 0 || padLen > aes.BlockSize {
+		return nil, errors.New(`invalid padding`)
+	}
+
+	return padded[:len(padded)-padLen], nil
+}
+
+func cbcTag(macKey, iv, ciphertext, aad []byte) []byte {
+	al := make([]byte, 8)
+	binary.BigEndian.PutUint64(al, uint64(len(aad))*8)
+
+	m := hmac.New(sha512.New, macKey)
+	m.Write(aad)
+	m.Write(iv)
+	m.Write(ciphertext)
+	m.Write(al)
+	return m.Sum(nil)[:cbcKeyBytes/2]
+}
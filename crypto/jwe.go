@@ -0,0 +1,354 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/tryfix/log"
+	"golang.org/x/crypto/curve25519"
+	"sort"
+	"strings"
+)
+
+const (
+	typEncrypted = `application/didcomm-encrypted+json`
+	kty          = `OKP`
+	crvX25519    = `X25519`
+	prefixKeyDID = `did:key:`
+)
+
+// multicodec prefixes of the keys in did:key identifiers
+var (
+	codecEd25519 = []byte{0xed, 0x01}
+	codecX25519  = []byte{0xec, 0x01}
+)
+
+// JWEPacker packs messages as DIDComm v2 encrypted messages in JWE general
+// JSON serialization. Authcrypt uses ECDH-1PU+A256KW with A256CBC-HS512 as
+// mandated by the spec while anoncrypt uses ECDH-ES+A256KW. Keys of the agent are
+// Ed25519 verification keys which are converted to X25519 keys for the key
+// agreement, and key ids are the DID URLs of the X25519 keys in the did:key docs
+// of the verification keys (did:key:z6Mk...#z6LS...).
+// see: https://identity.foundation/didcomm-messaging/spec/#sender-authenticated-encryption
+type JWEPacker struct {
+	log log.Logger
+}
+
+func NewJWEPacker(logger log.Logger) *JWEPacker {
+	return &JWEPacker{log: logger}
+}
+
+// Pack encrypts the content once and wraps the content encryption key for
+// each recipient with the same ephemeral key
+func (j *JWEPacker) Pack(input []byte, recPubKeys [][]byte, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error) {
+	return j.pack(input, recPubKeys, sendPubKey, sendPrvKey)
+}
+
+// PackAnon omits the sender key id from the protected header and derives the
+// key encryption key only from the ephemeral key agreement (ECDH-ES)
+func (j *JWEPacker) PackAnon(input []byte, recPubKeys [][]byte) (messages.AuthCryptMsg, error) {
+	return j.pack(input, recPubKeys, nil, nil)
+}
+
+// pack anoncrypts the message if sender keys are not provided
+func (j *JWEPacker) pack(input []byte, recPubKeys [][]byte, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error) {
+	if len(recPubKeys) == 0 {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`no recipient keys provided`)
+	}
+
+	epkPrv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(epkPrv); err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating ephemeral key failed - %v`, err)
+	}
+
+	epkPub, err := curve25519.X25519(epkPrv, curve25519.Basepoint)
+	if err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`deriving ephemeral public key failed - %v`, err)
+	}
+
+	// apv is the hash of sorted recipient key ids concatenated with '.'
+	var kids []string
+	var recXPubKeys [][]byte
+	for _, k := range recPubKeys {
+		recXPubKey, err := x25519PubKey(k)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`converting recipient key failed - %v`, err)
+		}
+		kids = append(kids, keyId(k, recXPubKey))
+		recXPubKeys = append(recXPubKeys, recXPubKey)
+	}
+	sortedKids := append([]string{}, kids...)
+	sort.Strings(sortedKids)
+	apv := sha256.Sum256([]byte(strings.Join(sortedKids, `.`)))
+
+	header := messages.JWEHeader{
+		Typ: typEncrypted,
+		Alg: algECDHES,
+		Enc: encA256CBC,
+		Apv: base64.RawURLEncoding.EncodeToString(apv[:]),
+		Epk: messages.JWK{Kty: kty, Crv: crvX25519, X: base64.RawURLEncoding.EncodeToString(epkPub)},
+	}
+
+	anon := sendPrvKey == nil
+	var sendXPrvKey []byte
+	if !anon {
+		if sendXPrvKey, err = x25519PrvKey(sendPrvKey); err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`converting sender key failed - %v`, err)
+		}
+
+		sendXPubKey, err := x25519PubKey(sendPubKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`converting sender key failed - %v`, err)
+		}
+
+		skid := keyId(sendPubKey, sendXPubKey)
+		header.Alg = algECDH1PU
+		header.Skid = skid
+		header.Apu = base64.RawURLEncoding.EncodeToString([]byte(skid))
+	}
+
+	headerByts, err := json.Marshal(header)
+	if err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`marshalling protected header failed - %v`, err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(headerByts)
+
+	cek, iv := make([]byte, cbcKeyBytes), make([]byte, cbcIvBytes)
+	if _, err = rand.Read(cek); err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating content encryption key failed - %v`, err)
+	}
+
+	if _, err = rand.Read(iv); err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating iv failed - %v`, err)
+	}
+
+	// content is encrypted first since ECDH-1PU binds the tag to the key wrapping
+	cipher, tag, err := cbcHmacEncrypt(cek, iv, input, []byte(protected))
+	if err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`encrypting content failed - %v`, err)
+	}
+
+	var recs []messages.Recipient
+	for i, recXPubKey := range recXPubKeys {
+		encKey, err := j.wrapCek(cek, epkPrv, recXPubKey, sendXPrvKey, header.Skid, apv[:], tag)
+		if err != nil {
+			return messages.AuthCryptMsg{}, err
+		}
+
+		recs = append(recs, messages.Recipient{
+			EncryptedKey: base64.RawURLEncoding.EncodeToString(encKey),
+			Header:       messages.Header{Kid: kids[i]},
+		})
+	}
+
+	return messages.AuthCryptMsg{
+		Protected:  protected,
+		Recipients: recs,
+		Iv:         base64.RawURLEncoding.EncodeToString(iv),
+		Ciphertext: base64.RawURLEncoding.EncodeToString(cipher),
+		Tag:        base64.RawURLEncoding.EncodeToString(tag),
+	}, nil
+}
+
+// wrapCek derives the key encryption key for the recipient and wraps cek with
+// it. Sender key agreement is only included for authcrypt (ECDH-1PU).
+func (j *JWEPacker) wrapCek(cek, epkPrv, recPubKey, sendPrvKey []byte, skid string, apv, tag []byte) ([]byte, error) {
+	z, err := ecdh(epkPrv, recPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var kek []byte
+	if sendPrvKey == nil {
+		kek = concatKDF(z, algECDHES, nil, apv, nil)
+	} else {
+		zs, err := ecdh(sendPrvKey, recPubKey)
+		if err != nil {
+			return nil, err
+		}
+		kek = concatKDF(append(z, zs...), algECDH1PU, []byte(skid), apv, tag)
+	}
+
+	encKey, err := keyWrap(kek, cek)
+	if err != nil {
+		return nil, fmt.Errorf(`wrapping content encryption key failed - %v`, err)
+	}
+	return encKey, nil
+}
+
+func (j *JWEPacker) Unpack(data, recPubKey, recPrvKey []byte) (output, sendPubKey []byte, err error) {
+	var msg messages.AuthCryptMsg
+	if err = json.Unmarshal(data, &msg); err != nil {
+		return nil, nil, fmt.Errorf(`unmarshalling jwe failed - %v`, err)
+	}
+
+	headerByts, err := base64.RawURLEncoding.DecodeString(msg.Protected)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding protected header failed - %v`, err)
+	}
+
+	var header messages.JWEHeader
+	if err = json.Unmarshal(headerByts, &header); err != nil {
+		return nil, nil, fmt.Errorf(`unmarshalling protected header failed - %v`, err)
+	}
+
+	if (header.Alg != algECDH1PU && header.Alg != algECDHES) || header.Enc != encA256CBC {
+		return nil, nil, fmt.Errorf(`unsupported algorithms (alg=%s, enc=%s)`, header.Alg, header.Enc)
+	}
+
+	recXPubKey, err := x25519PubKey(recPubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`converting recipient key failed - %v`, err)
+	}
+
+	kid := keyId(recPubKey, recXPubKey)
+	var encKey string
+	for _, r := range msg.Recipients {
+		if r.Header.Kid == kid {
+			encKey = r.EncryptedKey
+			break
+		}
+	}
+
+	if encKey == `` {
+		return nil, nil, fmt.Errorf(`message is not intended to the recipient key (%s)`, kid)
+	}
+
+	epkPub, err := base64.RawURLEncoding.DecodeString(header.Epk.X)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding ephemeral public key failed - %v`, err)
+	}
+
+	apu, err := base64.RawURLEncoding.DecodeString(header.Apu)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding apu failed - %v`, err)
+	}
+
+	apv, err := base64.RawURLEncoding.DecodeString(header.Apv)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding apv failed - %v`, err)
+	}
+
+	wrappedKey, iv, cipher, tag, err := j.decodeContent(encKey, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recXPrvKey, err := x25519PrvKey(recPrvKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`converting recipient key failed - %v`, err)
+	}
+
+	z, err := ecdh(recXPrvKey, epkPub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var kek []byte
+	if header.Alg == algECDHES {
+		kek = concatKDF(z, header.Alg, apu, apv, nil)
+	} else {
+		var sendXPubKey []byte
+		sendPubKey, sendXPubKey, err = keysByKid(header.Skid)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`invalid sender key id - %v`, err)
+		}
+
+		zs, err := ecdh(recXPrvKey, sendXPubKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		kek = concatKDF(append(z, zs...), header.Alg, apu, apv, tag)
+	}
+
+	cek, err := keyUnwrap(kek, wrappedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`unwrapping content encryption key failed - %v`, err)
+	}
+
+	output, err = cbcHmacDecrypt(cek, iv, cipher, tag, []byte(msg.Protected))
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decrypting content failed - %v`, err)
+	}
+
+	return output, sendPubKey, nil
+}
+
+// RecipientKeys returns the verification keys of the recipients which
+// are listed in the top level of the JWE
+func (j *JWEPacker) RecipientKeys(data []byte) ([][]byte, error) {
+	var msg messages.AuthCryptMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf(`unmarshalling jwe failed - %v`, err)
+	}
+
+	var keys [][]byte
+	for _, r := range msg.Recipients {
+		key, _, err := keysByKid(r.Header.Kid)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid recipient key id - %v`, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// keyId returns the DID URL of the X25519 key in the did:key doc of the
+// Ed25519 verification key
+func keyId(edPubKey, xPubKey []byte) string {
+	return prefixKeyDID + multibase(codecEd25519, edPubKey) + `#` + multibase(codecX25519, xPubKey)
+}
+
+// keysByKid decodes the verification key from the did:key of the key id and
+// checks that the fragment is the key agreement key derived from it
+func keysByKid(kid string) (edPubKey, xPubKey []byte, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(kid, prefixKeyDID), `#`, 2)
+	if !strings.HasPrefix(kid, prefixKeyDID) || len(parts) != 2 {
+		return nil, nil, fmt.Errorf(`%s is not a did:key url`, kid)
+	}
+
+	byts := base58.Decode(strings.TrimPrefix(parts[0], `z`))
+	if !strings.HasPrefix(parts[0], `z`) || len(byts) != len(codecEd25519)+ed25519.PublicKeySize || string(byts[:2]) != string(codecEd25519) {
+		return nil, nil, fmt.Errorf(`%s is not an Ed25519 did:key`, kid)
+	}
+
+	edPubKey = byts[2:]
+	if xPubKey, err = x25519PubKey(edPubKey); err != nil {
+		return nil, nil, err
+	}
+
+	if parts[1] != multibase(codecX25519, xPubKey) {
+		return nil, nil, fmt.Errorf(`%s does not refer to the key agreement key of the did`, kid)
+	}
+
+	return edPubKey, xPubKey, nil
+}
+
+func multibase(codec, key []byte) string {
+	return `z` + base58.Encode(append(append([]byte{}, codec...), key...))
+}
+
+func (j *JWEPacker) decodeContent(encKey string, msg messages.AuthCryptMsg) (wrappedKey, iv, cipher, tag []byte, err error) {
+	if wrappedKey, err = base64.RawURLEncoding.DecodeString(encKey); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf(`decoding encrypted key failed - %v`, err)
+	}
+
+	if iv, err = base64.RawURLEncoding.DecodeString(msg.Iv); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf(`decoding iv failed - %v`, err)
+	}
+
+	if cipher, err = base64.RawURLEncoding.DecodeString(msg.Ciphertext); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf(`decoding ciphertext failed - %v`, err)
+	}
+
+	if tag, err = base64.RawURLEncoding.DecodeString(msg.Tag); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf(`decoding tag failed - %v`, err)
+	}
+
+	return wrappedKey, iv, cipher, tag, nil
+}
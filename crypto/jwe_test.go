@@ -0,0 +1,273 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/tryfix/log"
+	"strings"
+	"testing"
+)
+
+func decodeRawURL(t *testing.T, val string) []byte {
+	byts, err := base64.RawURLEncoding.DecodeString(val)
+	if err != nil {
+		t.Fatalf(`value is not base64url (%s) - %v`, val, err)
+	}
+	return byts
+}
+
+func unhex(t *testing.T, val string) []byte {
+	byts, err := hex.DecodeString(strings.ReplaceAll(val, ` `, ``))
+	if err != nil {
+		t.Fatalf(`decoding hex failed - %v`, err)
+	}
+	return byts
+}
+
+// TestKeyWrap uses the vectors of RFC-3394 section 4.3 and 4.6
+func TestKeyWrap(t *testing.T) {
+	kek := unhex(t, `000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F`)
+	tests := []struct {
+		name, key, wrapped string
+	}{
+		{`128 bit key`, `00112233445566778899AABBCCDDEEFF`, `64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7`},
+		{`256 bit key`, `00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F`,
+			`28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wrapped, err := keyWrap(kek, unhex(t, test.key))
+			if err != nil {
+				t.Fatalf(`wrapping failed - %v`, err)
+			}
+
+			if !bytes.Equal(wrapped, unhex(t, test.wrapped)) {
+				t.Fatalf(`wrapped key mismatch (got %x)`, wrapped)
+			}
+
+			key, err := keyUnwrap(kek, wrapped)
+			if err != nil {
+				t.Fatalf(`unwrapping failed - %v`, err)
+			}
+
+			if !bytes.Equal(key, unhex(t, test.key)) {
+				t.Fatalf(`unwrapped key mismatch (got %x)`, key)
+			}
+
+			wrapped[0] ^= 0xff
+			if _, err = keyUnwrap(kek, wrapped); err == nil {
+				t.Error(`unwrapping a modified key should fail`)
+			}
+		})
+	}
+}
+
+// TestCbcHmac uses the AES_256_CBC_HMAC_SHA_512 vector of RFC-7518 appendix B.3
+func TestCbcHmac(t *testing.T) {
+	key := unhex(t, `000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f`+
+		`202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f`)
+	plaintext := []byte(`A cipher system must not be required to be secret, and it must be able to fall into the hands of the enemy without inconvenience`)
+	iv := unhex(t, `1af38c2dc2b96ffdd86694092341bc04`)
+	aad := []byte(`The second principle of Auguste Kerckhoffs`)
+	expCipher := unhex(t, `4affaaadb78c31c5da4b1b590d10ffbd3dd8d5d302423526912da037ecbcc7bd`+
+		`822c301dd67c373bccb584ad3e9279c2e6d12a1374b77f077553df829410446b`+
+		`36ebd97066296ae6427ea75c2e0846a11a09ccf5370dc80bfecbad28c73f09b3`+
+		`a3b75e662a2594410ae496b2e2e6609e31e6e02cc837f053d21f37ff4f51950bbe2638d09dd7a4930930806d0703b1f6`)
+	expTag := unhex(t, `4dd3b4c088a7f45c216839645b2012bf2e6269a8c56a816dbc1b267761955bc5`)
+
+	cipher, tag, err := cbcHmacEncrypt(key, iv, plaintext, aad)
+	if err != nil {
+		t.Fatalf(`encrypting failed - %v`, err)
+	}
+
+	if !bytes.Equal(cipher, expCipher) {
+		t.Errorf(`ciphertext mismatch (got %x)`, cipher)
+	}
+
+	if !bytes.Equal(tag, expTag) {
+		t.Errorf(`tag mismatch (got %x)`, tag)
+	}
+
+	output, err := cbcHmacDecrypt(key, iv, cipher, tag, aad)
+	if err != nil {
+		t.Fatalf(`decrypting failed - %v`, err)
+	}
+
+	if !bytes.Equal(output, plaintext) {
+		t.Errorf(`plaintext mismatch (got %s)`, output)
+	}
+
+	if _, err = cbcHmacDecrypt(key, iv, cipher, tag, []byte(`modified aad`)); err == nil {
+		t.Error(`decrypting with a different aad should fail`)
+	}
+}
+
+// TestECDH uses the X25519 vector of RFC-7748 section 6.1
+func TestECDH(t *testing.T) {
+	alicePrv := unhex(t, `77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a`)
+	alicePub := unhex(t, `8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a`)
+	bobPrv := unhex(t, `5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb`)
+	bobPub := unhex(t, `de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f`)
+	exp := unhex(t, `4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742`)
+
+	for _, keys := range [][2][]byte{{alicePrv, bobPub}, {bobPrv, alicePub}} {
+		z, err := ecdh(keys[0], keys[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(z, exp) {
+			t.Fatalf(`shared secret mismatch (got %x)`, z)
+		}
+	}
+}
+
+// TestECDH1PU uses the key derivation of appendix A of draft-04 of ECDH-1PU
+// where Alice sends to Bob with direct key agreement for A256GCM
+func TestECDH1PU(t *testing.T) {
+	ze := unhex(t, `9e56d91d817135d372834283bf84269cfb316ea3da806a48f6daa7798cfe90c4`)
+	zs := unhex(t, `e3ca3474384c9f62b30bfd4c688b3e7d4110a1b4badc3cc54ef7b81241efd50d`)
+	key := concatKDF(append(ze, zs...), `A256GCM`, []byte(`Alice`), []byte(`Bob`), nil)
+	if !bytes.Equal(key, unhex(t, `6caf13723d14850ad4b42cd6dde935bffd2fff00a9ba70de05c203a5e1722ca7`)) {
+		t.Fatalf(`derived key mismatch (got %x)`, key)
+	}
+}
+
+func protectedHeader(t *testing.T, msg messages.AuthCryptMsg) messages.JWEHeader {
+	var header messages.JWEHeader
+	if err := json.Unmarshal(decodeRawURL(t, msg.Protected), &header); err != nil {
+		t.Fatalf(`unmarshalling protected header failed - %v`, err)
+	}
+	return header
+}
+
+func TestJWEPacker_MultipleRecipients(t *testing.T) {
+	j := NewJWEPacker(log.Constructor.Log())
+	sender := newKeyPair(t)
+	recs := []keyPair{newKeyPair(t), newKeyPair(t), newKeyPair(t)}
+	input := []byte(`{"@type":"https://didcomm.org/basicmessage/1.0/message"}`)
+
+	var recKeys [][]byte
+	for _, r := range recs {
+		recKeys = append(recKeys, r.pub)
+	}
+
+	for _, anon := range []bool{false, true} {
+		var msg messages.AuthCryptMsg
+		var err error
+		if anon {
+			msg, err = j.PackAnon(input, recKeys)
+		} else {
+			msg, err = j.Pack(input, recKeys, sender.pub, sender.prv)
+		}
+		if err != nil {
+			t.Fatalf(`packing failed - %v`, err)
+		}
+
+		header := protectedHeader(t, msg)
+		if !anon && header.Skid != keyId(sender.pub, sender.xPub[:]) {
+			t.Errorf(`skid %s is not the did url of the sender key`, header.Skid)
+		}
+
+		for i, r := range msg.Recipients {
+			if !strings.HasPrefix(r.Header.Kid, `did:key:z6Mk`) || !strings.Contains(r.Header.Kid, `#z6LS`) {
+				t.Errorf(`kid %s is not a did:key url`, r.Header.Kid)
+			}
+
+			if r.Header.Kid != keyId(recs[i].pub, recs[i].xPub[:]) {
+				t.Errorf(`kid %s does not refer to the key of recipient %d`, r.Header.Kid, i)
+			}
+		}
+
+		keys, err := j.RecipientKeys(marshal(t, msg))
+		if err != nil {
+			t.Fatalf(`parsing recipient keys failed - %v`, err)
+		}
+
+		for i, r := range recs {
+			if !bytes.Equal(keys[i], r.pub) {
+				t.Errorf(`recipient key %d mismatch`, i)
+			}
+
+			output, sendPubKey, err := j.Unpack(marshal(t, msg), r.pub, r.prv)
+			if err != nil {
+				t.Fatalf(`unpacking by recipient %d failed - %v`, i, err)
+			}
+
+			if !bytes.Equal(output, input) {
+				t.Errorf(`unpacked message mismatch for recipient %d`, i)
+			}
+
+			if anon && sendPubKey != nil || !anon && !bytes.Equal(sendPubKey, sender.pub) {
+				t.Errorf(`sender key mismatch for recipient %d (anoncrypt=%t)`, i, anon)
+			}
+		}
+	}
+}
+
+func TestJWEPacker_UnpackErrors(t *testing.T) {
+	j := NewJWEPacker(log.Constructor.Log())
+	rec, sender, other := newKeyPair(t), newKeyPair(t), newKeyPair(t)
+
+	msg, err := j.Pack([]byte(`secret`), [][]byte{rec.pub}, sender.pub, sender.prv)
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	modify := func(val string) string {
+		byts := decodeRawURL(t, val)
+		byts[0] ^= 0xff
+		return base64.RawURLEncoding.EncodeToString(byts)
+	}
+
+	withHeader := func(f func(h *messages.JWEHeader)) string {
+		header := protectedHeader(t, msg)
+		f(&header)
+		byts, err := json.Marshal(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(byts)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *messages.AuthCryptMsg)
+		key    keyPair
+	}{
+		{`wrong recipient`, func(m *messages.AuthCryptMsg) {}, other},
+		{`tag`, func(m *messages.AuthCryptMsg) { m.Tag = modify(m.Tag) }, rec},
+		{`ciphertext`, func(m *messages.AuthCryptMsg) { m.Ciphertext = modify(m.Ciphertext) }, rec},
+		{`encrypted key`, func(m *messages.AuthCryptMsg) {
+			m.Recipients = []messages.Recipient{{EncryptedKey: modify(m.Recipients[0].EncryptedKey), Header: m.Recipients[0].Header}}
+		}, rec},
+		{`protected header`, func(m *messages.AuthCryptMsg) {
+			m.Protected = withHeader(func(h *messages.JWEHeader) { h.Apv = base64.RawURLEncoding.EncodeToString([]byte(`apv`)) })
+		}, rec},
+		{`sender key id`, func(m *messages.AuthCryptMsg) {
+			m.Protected = withHeader(func(h *messages.JWEHeader) { h.Skid = keyId(other.pub, other.xPub[:]) })
+		}, rec},
+		{`sender key id without did`, func(m *messages.AuthCryptMsg) {
+			m.Protected = withHeader(func(h *messages.JWEHeader) { h.Skid = keyId(sender.pub, sender.xPub[:])[len(prefixKeyDID):] })
+		}, rec},
+		{`key agreement key of another did`, func(m *messages.AuthCryptMsg) {
+			m.Protected = withHeader(func(h *messages.JWEHeader) {
+				h.Skid = strings.Split(keyId(sender.pub, sender.xPub[:]), `#`)[0] + `#` + strings.Split(keyId(other.pub, other.xPub[:]), `#`)[1]
+			})
+		}, rec},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := msg
+			test.modify(&tampered)
+			if _, _, err = j.Unpack(marshal(t, tampered), test.key.pub, test.key.prv); err == nil {
+				t.Error(`unpacking should fail`)
+			}
+		})
+	}
+}
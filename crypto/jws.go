@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	typSigned = `application/didcomm-signed+json`
+	algEdDSA  = `EdDSA`
+)
+
+// JWSSigner signs messages as DIDComm v2 signed messages with Ed25519 keys.
+// Key IDs are base58 encoded verification keys.
+type JWSSigner struct{}
+
+func NewJWSSigner() *JWSSigner {
+	return &JWSSigner{}
+}
+
+func (j *JWSSigner) Sign(payload, pubKey, prvKey []byte) ([]byte, error) {
+	if len(prvKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf(`invalid signing key length (%d)`, len(prvKey))
+	}
+
+	headerByts, err := json.Marshal(messages.JWSHeader{Typ: typSigned, Alg: algEdDSA})
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling protected header failed - %v`, err)
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(headerByts)
+	encPayload := base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(prvKey, []byte(protected+`.`+encPayload))
+
+	data, err := json.Marshal(messages.JWS{
+		Payload: encPayload,
+		Signatures: []messages.Signature{{
+			Protected: protected,
+			Signature: base64.RawURLEncoding.EncodeToString(sig),
+			Header:    messages.SigHeader{Kid: base58.Encode(pubKey)},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling jws failed - %v`, err)
+	}
+
+	return data, nil
+}
+
+// Verify returns the payload along with the key of the first valid signature
+func (j *JWSSigner) Verify(data []byte) (payload, signerKey []byte, err error) {
+	var jws messages.JWS
+	if err = json.Unmarshal(data, &jws); err != nil {
+		return nil, nil, fmt.Errorf(`unmarshalling jws failed - %v`, err)
+	}
+
+	payload, err = base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding payload failed - %v`, err)
+	}
+
+	for _, s := range jws.Signatures {
+		headerByts, err := base64.RawURLEncoding.DecodeString(s.Protected)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`decoding protected header failed - %v`, err)
+		}
+
+		var header messages.JWSHeader
+		if err = json.Unmarshal(headerByts, &header); err != nil {
+			return nil, nil, fmt.Errorf(`unmarshalling protected header failed - %v`, err)
+		}
+
+		if header.Alg != algEdDSA {
+			continue
+		}
+
+		sig, err := base64.RawURLEncoding.DecodeString(s.Signature)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`decoding signature failed - %v`, err)
+		}
+
+		key := base58.Decode(s.Header.Kid)
+		if len(key) != ed25519.PublicKeySize {
+			continue
+		}
+
+		if ed25519.Verify(key, []byte(s.Protected+`.`+jws.Payload), sig) {
+			return payload, key, nil
+		}
+	}
+
+	return nil, nil, errors.New(`no valid signature found`)
+}
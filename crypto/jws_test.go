@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"testing"
+)
+
+func signTest(t *testing.T, payload, pubKey, prvKey []byte) messages.JWS {
+	data, err := NewJWSSigner().Sign(payload, pubKey, prvKey)
+	if err != nil {
+		t.Fatalf(`signing failed - %v`, err)
+	}
+
+	var jws messages.JWS
+	if err = json.Unmarshal(data, &jws); err != nil {
+		t.Fatalf(`unmarshalling jws failed - %v`, err)
+	}
+	return jws
+}
+
+func TestJWSSigner_Verify(t *testing.T) {
+	signer, other := newKeyPair(t), newKeyPair(t)
+	payload := []byte(`{"content":"signed group message"}`)
+	encode := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		name   string
+		tamper func(jws *messages.JWS)
+		valid  bool
+	}{
+		{`valid`, func(*messages.JWS) {}, true},
+		{`tampered payload`, func(jws *messages.JWS) {
+			jws.Payload = encode([]byte(`{"content":"tampered group message"}`))
+		}, false},
+		{`tampered protected header`, func(jws *messages.JWS) {
+			jws.Signatures[0].Protected = encode([]byte(`{"typ":"application/didcomm-plain+json","alg":"EdDSA"}`))
+		}, false},
+		{`wrong key`, func(jws *messages.JWS) {
+			jws.Signatures[0].Header.Kid = signTest(t, payload, other.pub, other.prv).Signatures[0].Header.Kid
+		}, false},
+		{`unsupported algorithm`, func(jws *messages.JWS) {
+			// signature is valid over the header with the other algorithm
+			protected := encode([]byte(`{"typ":"application/didcomm-signed+json","alg":"ES256"}`))
+			jws.Signatures[0].Protected = protected
+			jws.Signatures[0].Signature = encode(ed25519.Sign(signer.prv, []byte(protected+`.`+jws.Payload)))
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jws := signTest(t, payload, signer.pub, signer.prv)
+			test.tamper(&jws)
+			data, err := json.Marshal(jws)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, signerKey, err := NewJWSSigner().Verify(data)
+			if !test.valid {
+				if err == nil {
+					t.Error(`verification should fail`)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(`verification failed - %v`, err)
+			}
+
+			if !bytes.Equal(output, payload) || !bytes.Equal(signerKey, signer.pub) {
+				t.Errorf(`expected payload %s signed by %x but got %s by %x`, payload, signer.pub, output, signerKey)
+			}
+		})
+	}
+}
+
+func TestJWSSigner_SignInvalidKey(t *testing.T) {
+	kp := newKeyPair(t)
+	if _, err := NewJWSSigner().Sign([]byte(`payload`), kp.pub, kp.prv[:16]); err == nil {
+		t.Error(`signing with a key of invalid length should fail`)
+	}
+}
@@ -1,49 +1,129 @@
 package crypto
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"golang.org/x/crypto/nacl/box"
+	"github.com/btcsuite/btcutil/base58"
 	"sync"
+	"time"
 )
 
+// keys are Ed25519 key pairs where the verification key identifies the key pair
+// in envelopes and did docs, and the corresponding X25519 keys are derived by
+// the packers for key agreement
 type keys struct {
-	pub *[32]byte
-	prv *[32]byte
+	pub ed25519.PublicKey
+	prv ed25519.PrivateKey
+}
+
+func newKeys() (keys, error) {
+	pub, prv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return keys{}, err
+	}
+	return keys{pub: pub, prv: prv}, nil
+}
+
+func (k keys) public() []byte {
+	return append([]byte{}, k.pub...)
+}
+
+func (k keys) private() []byte {
+	return append([]byte{}, k.prv...)
+}
+
+// retiredKeys are the keys of a connection replaced by a rotation
+type retiredKeys struct {
+	keys
+	peer    string
+	expires time.Time
 }
 
 type KeyManager struct {
-	invPubKey *[32]byte
-	invPrvKey *[32]byte
-	keyStore  *sync.Map       // key: peer label
-	invKeys   map[string]keys // key: topic
+	invKeys  *sync.Map // key: invitation id
+	inviter  *keys
+	routing  *keys
+	lock     *sync.RWMutex // guards inviter and routing keys
+	keyStore *sync.Map     // key: peer label
+	retired  *sync.Map     // key: base58 encoded public key
+	grpKeys  *sync.Map     // key: topic
+	sigKeys  *sync.Map     // key: topic
 }
 
 func NewKeyManager() *KeyManager {
-	return &KeyManager{keyStore: &sync.Map{}}
+	return &KeyManager{invKeys: &sync.Map{}, keyStore: &sync.Map{}, retired: &sync.Map{}, grpKeys: &sync.Map{}, sigKeys: &sync.Map{}, lock: &sync.RWMutex{}}
 }
 
 func (k *KeyManager) GenerateKeys(peer string) error {
-	reader := rand.Reader
-	pubKey, prvKey, err := box.GenerateKey(reader)
+	ks, err := newKeys()
 	if err != nil {
 		return err
 	}
 
-	k.keyStore.Store(peer, keys{pub: pubKey, prv: prvKey})
+	k.keyStore.Store(peer, ks)
 	return nil
 }
 
+func (k *KeyManager) RotateKeys(peer string, grace time.Duration) error {
+	val, ok := k.keyStore.Load(peer)
+	if !ok {
+		return fmt.Errorf(`no keys found for the connection with %s`, peer)
+	}
+
+	if err := k.GenerateKeys(peer); err != nil {
+		return err
+	}
+
+	prevKeys := val.(keys)
+	k.retired.Store(base58.Encode(prevKeys.pub), retiredKeys{keys: prevKeys, peer: peer, expires: time.Now().Add(grace)})
+	return nil
+}
+
+// RestoreKeys reinstates the retired key-pair of the public key as the keys
+// of the connection with peer such that a rotation can be reverted
+func (k *KeyManager) RestoreKeys(peer string, pubKey []byte) error {
+	id := base58.Encode(pubKey)
+	val, ok := k.retired.Load(id)
+	if !ok {
+		return fmt.Errorf(`no retired keys found for the public key (base58-encoded: %s)`, id)
+	}
+
+	rk := val.(retiredKeys)
+	if rk.peer != peer {
+		return fmt.Errorf(`retired keys do not belong to the connection with %s`, peer)
+	}
+
+	k.keyStore.Store(peer, rk.keys)
+	k.retired.Delete(id)
+	return nil
+}
+
+// retiredKey returns the retired keys corresponding to the public key
+// if the grace period has not elapsed
+func (k *KeyManager) retiredKey(pubKey []byte) (retiredKeys, bool) {
+	id := base58.Encode(pubKey)
+	val, ok := k.retired.Load(id)
+	if !ok {
+		return retiredKeys{}, false
+	}
+
+	rk := val.(retiredKeys)
+	if time.Now().After(rk.expires) {
+		k.retired.Delete(id)
+		return retiredKeys{}, false
+	}
+
+	return rk, true
+}
+
 func (k *KeyManager) PrivateKey(peer string) ([]byte, error) {
 	val, ok := k.keyStore.Load(peer)
 	if !ok {
 		return nil, fmt.Errorf(`no private key found for the connection with %s`, peer)
 	}
-	key := val.(keys)
-
-	tmpPrvKey := *key.prv
-	return tmpPrvKey[:], nil
+	return val.(keys).private(), nil
 }
 
 func (k *KeyManager) PublicKey(peer string) ([]byte, error) {
@@ -51,10 +131,7 @@ func (k *KeyManager) PublicKey(peer string) ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf(`no public key found for the connection with %s`, peer)
 	}
-	key := val.(keys)
-
-	tmpPubKey := *key.pub
-	return tmpPubKey[:], nil
+	return val.(keys).public(), nil
 }
 
 func (k *KeyManager) Peer(pubKey []byte) (name string, err error) {
@@ -72,33 +149,206 @@ func (k *KeyManager) Peer(pubKey []byte) (name string, err error) {
 		return name, nil
 	}
 
+	if rk, ok := k.retiredKey(pubKey); ok {
+		return rk.peer, nil
+	}
+
 	return ``, fmt.Errorf(`could not find the requested public key (base64-encoded: %s)`, base64.StdEncoding.EncodeToString(pubKey))
 }
 
-func (k *KeyManager) GenerateInvKeys() error {
-	// uses one key-pair for all invitations but can use separate ones for higher security
-	if k.invPrvKey != nil && k.invPubKey != nil {
-		return nil
+func (k *KeyManager) PrivateKeyByPubKey(pubKey []byte) ([]byte, error) {
+	var prvKey []byte
+	k.keyStore.Range(func(_, val any) bool {
+		key := val.(keys)
+		if string(key.pub) == string(pubKey) {
+			prvKey = key.private()
+			return false
+		}
+		return true
+	})
+
+	if prvKey != nil {
+		return prvKey, nil
+	}
+
+	if rk, ok := k.retiredKey(pubKey); ok {
+		return rk.private(), nil
 	}
 
-	reader := rand.Reader
-	pubKey, prvKey, err := box.GenerateKey(reader)
+	return nil, fmt.Errorf(`could not find the private key of the public key (base64-encoded: %s)`, base64.StdEncoding.EncodeToString(pubKey))
+}
+
+// GenerateInvKeys creates a separate key-pair for each invitation such that
+// invitations can be revoked independently
+func (k *KeyManager) GenerateInvKeys(invId string) error {
+	ks, err := newKeys()
 	if err != nil {
 		return err
 	}
 
-	k.invPrvKey = prvKey
-	k.invPubKey = pubKey
+	k.invKeys.Store(invId, ks)
+	return nil
+}
+
+func (k *KeyManager) InvPrivateKey(invId string) ([]byte, error) {
+	val, ok := k.invKeys.Load(invId)
+	if !ok {
+		return nil, fmt.Errorf(`no private key found for the invitation %s`, invId)
+	}
 
+	return val.(keys).private(), nil
+}
+
+func (k *KeyManager) InvPublicKey(invId string) ([]byte, error) {
+	val, ok := k.invKeys.Load(invId)
+	if !ok {
+		return nil, fmt.Errorf(`no public key found for the invitation %s`, invId)
+	}
+
+	return val.(keys).public(), nil
+}
+
+// Invitation returns the id of the invitation which the public key belongs to
+func (k *KeyManager) Invitation(pubKey []byte) (invId string, err error) {
+	k.invKeys.Range(func(key, val any) bool {
+		if string(val.(keys).pub) == string(pubKey) {
+			invId = key.(string)
+			return false
+		}
+		return true
+	})
+
+	if invId == `` {
+		return ``, fmt.Errorf(`could not find the invitation of the public key (base64-encoded: %s)`, base64.StdEncoding.EncodeToString(pubKey))
+	}
+
+	return invId, nil
+}
+
+func (k *KeyManager) RemoveInvKeys(invId string) error {
+	k.invKeys.Delete(invId)
+	return nil
+}
+
+// GenerateInviterKeys creates the long-lived key-pair which identifies the
+// agent across its invitations if it does not exist already
+func (k *KeyManager) GenerateInviterKeys() error {
+	_, err := k.generateOnce(&k.inviter)
+	return err
+}
+
+func (k *KeyManager) InviterPublicKey() []byte {
+	if ks := k.agentKeys(&k.inviter); ks != nil {
+		return ks.public()
+	}
+	return nil
+}
+
+func (k *KeyManager) GenerateRoutingKeys() error {
+	_, err := k.generateOnce(&k.routing)
+	return err
+}
+
+func (k *KeyManager) RoutingPublicKey() []byte {
+	if ks := k.agentKeys(&k.routing); ks != nil {
+		return ks.public()
+	}
 	return nil
 }
 
-func (k *KeyManager) InvPrivateKey() []byte {
-	tmpPrvKey := *k.invPrvKey
-	return tmpPrvKey[:]
+func (k *KeyManager) RoutingPrivateKey() []byte {
+	if ks := k.agentKeys(&k.routing); ks != nil {
+		return ks.private()
+	}
+	return nil
+}
+
+// generateOnce creates the key-pair of the agent referred by ks unless it
+// exists already and reports whether a key-pair was created
+func (k *KeyManager) generateOnce(ks **keys) (created bool, err error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if *ks != nil {
+		return false, nil
+	}
+
+	newKs, err := newKeys()
+	if err != nil {
+		return false, err
+	}
+
+	*ks = &newKs
+	return true, nil
+}
+
+func (k *KeyManager) agentKeys(ks **keys) *keys {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return *ks
+}
+
+func (k *KeyManager) setAgentKeys(ks **keys, val keys) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	*ks = &val
+}
+
+func (k *KeyManager) GenerateGroupKeys(topic string) error {
+	_, err := generateByTopic(k.grpKeys, topic)
+	return err
+}
+
+func (k *KeyManager) GroupPublicKey(topic string) ([]byte, error) {
+	val, ok := k.grpKeys.Load(topic)
+	if !ok {
+		return nil, fmt.Errorf(`no group public key found for the topic %s`, topic)
+	}
+
+	return val.(keys).public(), nil
+}
+
+func (k *KeyManager) GroupPrivateKey(topic string) ([]byte, error) {
+	val, ok := k.grpKeys.Load(topic)
+	if !ok {
+		return nil, fmt.Errorf(`no group private key found for the topic %s`, topic)
+	}
+
+	return val.(keys).private(), nil
+}
+
+func (k *KeyManager) GenerateSigningKeys(topic string) error {
+	_, err := generateByTopic(k.sigKeys, topic)
+	return err
 }
 
-func (k *KeyManager) InvPublicKey() []byte {
-	tmpPubKey := *k.invPubKey
-	return tmpPubKey[:]
+// generateByTopic creates a key-pair for the topic in store unless it
+// exists already and reports whether a key-pair was created
+func generateByTopic(store *sync.Map, topic string) (created bool, err error) {
+	if _, ok := store.Load(topic); ok {
+		return false, nil
+	}
+
+	ks, err := newKeys()
+	if err != nil {
+		return false, err
+	}
+
+	_, loaded := store.LoadOrStore(topic, ks)
+	return !loaded, nil
+}
+
+func (k *KeyManager) SigningPublicKey(topic string) ([]byte, error) {
+	val, ok := k.sigKeys.Load(topic)
+	if !ok {
+		return nil, fmt.Errorf(`no signing public key found for the topic %s`, topic)
+	}
+	return val.(keys).public(), nil
+}
+
+func (k *KeyManager) SigningPrivateKey(topic string) ([]byte, error) {
+	val, ok := k.sigKeys.Load(topic)
+	if !ok {
+		return nil, fmt.Errorf(`no signing private key found for the topic %s`, topic)
+	}
+	return val.(keys).private(), nil
 }
@@ -5,14 +5,29 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/tryfix/log"
-	rand2 "math/rand"
-	"strconv"
+	"strings"
 )
 
+const (
+	algAuthcrypt = `Authcrypt`
+	algAnoncrypt = `Anoncrypt`
+	encXChacha   = `xchacha20poly1305_ietf`
+	encChacha    = `chacha20poly1305_ietf`
+	typJWM       = `JWM/1.0`
+	cekBytes     = 32
+	xchachaIv    = 24
+	chachaIv     = 12
+)
+
+// Packer implements the Aries envelope (RFC-0019). All binary values are
+// base64url encoded and key ids are base58 encoded Ed25519 verification keys
+// which are converted to X25519 keys for the crypto boxes.
+// see: https://github.com/hyperledger/aries-rfcs/tree/main/features/0019-encryption-envelope
 type Packer struct {
 	enc services.Encryptor
 	log log.Logger
@@ -22,155 +37,294 @@ func NewPacker(logger log.Logger) *Packer {
 	return &Packer{enc: &encryptor{}, log: logger}
 }
 
-func (p *Packer) Pack(input []byte, recPubKey, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error) {
-	// generating and encoding the nonce
-	cekIv := []byte(strconv.Itoa(rand2.Int()))
-	encodedCekIv := base64.StdEncoding.EncodeToString(cekIv)
-
-	// generating content encryption key
-	cek := make([]byte, 64)
-	_, err := rand.Read(cek)
+// Pack encrypts the content once and wraps the content encryption key
+// separately for each of the recipient keys
+func (p *Packer) Pack(input []byte, recPubKeys [][]byte, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error) {
+	cek, err := random(cekBytes)
 	if err != nil {
-		return messages.AuthCryptMsg{}, err
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating content encryption key failed - %v`, err)
 	}
 
-	// encrypting cek so it will be decrypted by recipient
-	encryptedCek, err := p.enc.Box(cek, cekIv, recPubKey, sendPrvKey)
+	sendXPrvKey, err := x25519PrvKey(sendPrvKey)
 	if err != nil {
-		return messages.AuthCryptMsg{}, err
+		return messages.AuthCryptMsg{}, fmt.Errorf(`converting sender key failed - %v`, err)
+	}
+
+	var recs []messages.Recipient
+	for _, recPubKey := range recPubKeys {
+		recXPubKey, err := x25519PubKey(recPubKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`converting recipient key failed - %v`, err)
+		}
+
+		cekIv, err := random(nonceBytes)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`generating nonce failed - %v`, err)
+		}
+
+		// encrypting cek so it will be decrypted by recipient
+		encryptedCek, err := p.enc.Box(cek, cekIv, recXPubKey, sendXPrvKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`encrypting content encryption key failed - %v`, err)
+		}
+
+		// encrypting base58 encoded sender ver key
+		encryptedSendKey, err := p.enc.SealBox([]byte(base58.Encode(sendPubKey)), recXPubKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`encrypting sender key failed - %v`, err)
+		}
+
+		recs = append(recs, messages.Recipient{
+			EncryptedKey: base64.URLEncoding.EncodeToString(encryptedCek),
+			Header: messages.Header{
+				Kid:    base58.Encode(recPubKey),
+				Iv:     base64.URLEncoding.EncodeToString(cekIv),
+				Sender: base64.URLEncoding.EncodeToString(encryptedSendKey),
+			},
+		})
 	}
 
-	// encrypting sender ver key
-	encryptedSendKey, err := p.enc.SealBox(sendPubKey, recPubKey)
+	return p.encrypt(input, cek, algAuthcrypt, recs)
+}
+
+// PackAnon encrypts the content encryption key with a sealed box such that
+// the recipient can decrypt the message without knowing the sender
+func (p *Packer) PackAnon(input []byte, recPubKeys [][]byte) (messages.AuthCryptMsg, error) {
+	cek, err := random(cekBytes)
 	if err != nil {
-		return messages.AuthCryptMsg{}, err
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating content encryption key failed - %v`, err)
 	}
 
+	var recs []messages.Recipient
+	for _, recPubKey := range recPubKeys {
+		recXPubKey, err := x25519PubKey(recPubKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`converting recipient key failed - %v`, err)
+		}
+
+		encryptedCek, err := p.enc.SealBox(cek, recXPubKey)
+		if err != nil {
+			return messages.AuthCryptMsg{}, fmt.Errorf(`encrypting content encryption key failed - %v`, err)
+		}
+
+		recs = append(recs, messages.Recipient{
+			EncryptedKey: base64.URLEncoding.EncodeToString(encryptedCek),
+			Header:       messages.Header{Kid: base58.Encode(recPubKey)},
+		})
+	}
+
+	return p.encrypt(input, cek, algAnoncrypt, recs)
+}
+
+// encrypt constructs the protected payload for the given recipients and
+// encrypts the input with the content encryption key. The encoded protected
+// value is used as the additional authenticated data.
+func (p *Packer) encrypt(input, cek []byte, alg string, recs []messages.Recipient) (messages.AuthCryptMsg, error) {
 	// constructing payload
 	payload := messages.Payload{
-		Enc: "xchacha20poly1305_ietf",
-		Typ: "JWM/1.0",
-		Alg: "Authcrypt",
-		Recipients: []messages.Recipient{
-			{
-				EncryptedKey: base64.StdEncoding.EncodeToString(encryptedCek),
-				Header: messages.Header{
-					Kid:    base58.Encode(recPubKey),
-					Iv:     encodedCekIv,
-					Sender: base64.StdEncoding.EncodeToString(encryptedSendKey),
-				},
-			},
-		},
+		Enc:        encXChacha,
+		Typ:        typJWM,
+		Alg:        alg,
+		Recipients: recs,
 	}
 
 	// base64 encoding of the payload
 	encPayload, err := json.Marshal(payload)
 	if err != nil {
-		return messages.AuthCryptMsg{}, err
+		return messages.AuthCryptMsg{}, fmt.Errorf(`marshalling protected payload failed - %v`, err)
 	}
-	protectedVal := base64.StdEncoding.EncodeToString(encPayload)
+	protectedVal := base64.URLEncoding.EncodeToString(encPayload)
 
-	// encrypt with chachapoly1305 detached mode
-	iv := []byte(strconv.Itoa(rand2.Int()))
-	cipher, mac, err := p.enc.EncryptDetached(string(input), string(encPayload), iv, cek)
+	iv, err := random(xchachaIv)
 	if err != nil {
-		return messages.AuthCryptMsg{}, err
+		return messages.AuthCryptMsg{}, fmt.Errorf(`generating iv failed - %v`, err)
+	}
+
+	// encrypt with xchacha20poly1305 detached mode
+	cipher, mac, err := p.enc.EncryptDetached(string(input), protectedVal, iv, cek)
+	if err != nil {
+		return messages.AuthCryptMsg{}, fmt.Errorf(`encrypting content failed - %v`, err)
 	}
 
 	// constructing the final message
 	authCryptMsg := messages.AuthCryptMsg{
 		Protected:  protectedVal,
-		Iv:         base64.StdEncoding.EncodeToString(iv),
-		Ciphertext: base64.StdEncoding.EncodeToString(cipher),
-		Tag:        base64.StdEncoding.EncodeToString(mac),
+		Iv:         base64.URLEncoding.EncodeToString(iv),
+		Ciphertext: base64.URLEncoding.EncodeToString(cipher),
+		Tag:        base64.URLEncoding.EncodeToString(mac),
 	}
 
 	return authCryptMsg, nil
 }
 
-func (p *Packer) Unpack(data, recPubKey, recPrvKey []byte) (output []byte, err error) {
-	// unmarshal into authcrypt message
-	var msg messages.AuthCryptMsg
-	err = json.Unmarshal(data, &msg)
+func (p *Packer) Unpack(data, recPubKey, recPrvKey []byte) (output, sendPubKey []byte, err error) {
+	msg, payload, err := p.parse(data)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	// selects the recipient entry which corresponds to the given key
+	rec, ok := p.recipient(payload.Recipients, recPubKey)
+	if !ok {
+		return nil, nil, errors.New("message is not intended to the recipient key")
 	}
 
-	// decode protected payload
-	var payload messages.Payload
-	decodedVal, err := base64.StdEncoding.DecodeString(msg.Protected)
+	recXPubKey, err := x25519PubKey(recPubKey)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`converting recipient key failed - %v`, err)
 	}
 
-	err = json.Unmarshal(decodedVal, &payload)
+	recXPrvKey, err := x25519PrvKey(recPrvKey)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`converting recipient private key failed - %v`, err)
 	}
 
-	if len(payload.Recipients) == 0 {
-		return nil, errors.New("no recipients found")
+	// decrypt cek
+	decodedCek, err := decodeBase64(rec.EncryptedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding encrypted key failed - %v`, err)
+	}
+
+	var cek []byte
+	switch payload.Alg {
+	case algAnoncrypt:
+		cek, err = p.enc.SealBoxOpen(decodedCek, recXPubKey, recXPrvKey)
+	case algAuthcrypt:
+		sendPubKey, cek, err = p.openAuthcryptCek(rec, decodedCek, recXPubKey, recXPrvKey)
+	default:
+		return nil, nil, fmt.Errorf(`unsupported algorithm (%s)`, payload.Alg)
 	}
-	rec := payload.Recipients[0]
 
-	// decrypt sender verification key
-	decodedSendKey, err := base64.StdEncoding.DecodeString(rec.Header.Sender) // note: array length should be checked
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`decrypting content encryption key failed - %v`, err)
 	}
 
-	sendPubKey, err := p.enc.SealBoxOpen(decodedSendKey, recPubKey, recPrvKey)
+	// decrypt cipher text
+	decodedCipher, err := decodeBase64(msg.Ciphertext)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`decoding ciphertext failed - %v`, err)
 	}
 
-	// decrypt cek
-	decodedCek, err := base64.StdEncoding.DecodeString(rec.EncryptedKey) // note: array length should be checked
+	mac, err := decodeBase64(msg.Tag)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`decoding tag failed - %v`, err)
 	}
 
-	cekIv, err := base64.StdEncoding.DecodeString(rec.Header.Iv)
+	iv, err := decodeBase64(msg.Iv)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`decoding iv failed - %v`, err)
 	}
 
-	cek, err := p.enc.BoxOpen(decodedCek, cekIv, sendPubKey, recPrvKey)
+	// nonce size differs based on the content encryption algorithm
+	if (payload.Enc == encXChacha && len(iv) != xchachaIv) || (payload.Enc == encChacha && len(iv) != chachaIv) {
+		return nil, nil, fmt.Errorf(`invalid iv length (%d) for %s`, len(iv), payload.Enc)
+	} else if payload.Enc != encXChacha && payload.Enc != encChacha {
+		return nil, nil, fmt.Errorf(`unsupported content encryption (%s)`, payload.Enc)
+	}
+
+	output, err = p.enc.DecryptDetached(decodedCipher, mac, []byte(msg.Protected), iv, cek)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf(`decrypting content failed - %v`, err)
 	}
 
-	// decrypt cipher text
-	decodedCipher, err := base64.StdEncoding.DecodeString(msg.Ciphertext)
+	return output, sendPubKey, nil
+}
+
+// openAuthcryptCek decrypts the sender verification key and uses it to
+// decrypt the content encryption key of an authcrypt message with the
+// X25519 keys of the recipient
+func (p *Packer) openAuthcryptCek(rec messages.Recipient, encCek, recXPubKey, recXPrvKey []byte) (sendPubKey, cek []byte, err error) {
+	// decrypt sender verification key
+	decodedSendKey, err := decodeBase64(rec.Header.Sender)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, fmt.Errorf(`decoding sender failed - %v`, err)
 	}
 
-	mac, err := base64.StdEncoding.DecodeString(msg.Tag)
+	encodedSendKey, err := p.enc.SealBoxOpen(decodedSendKey, recXPubKey, recXPrvKey)
 	if err != nil {
-		p.log.Error(err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	sendPubKey = base58.Decode(string(encodedSendKey))
+	if len(sendPubKey) == 0 {
+		return nil, nil, errors.New(`sender key is not base58 encoded`)
+	}
+
+	sendXPubKey, err := x25519PubKey(sendPubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`converting sender key failed - %v`, err)
+	}
+
+	cekIv, err := decodeBase64(rec.Header.Iv)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`decoding nonce failed - %v`, err)
+	}
+
+	cek, err = p.enc.BoxOpen(encCek, cekIv, sendXPubKey, recXPrvKey)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	iv, err := base64.StdEncoding.DecodeString(msg.Iv)
+	return sendPubKey, cek, nil
+}
+
+// RecipientKeys returns the verification keys of the recipients which are
+// included in the protected payload
+func (p *Packer) RecipientKeys(data []byte) ([][]byte, error) {
+	_, payload, err := p.parse(data)
 	if err != nil {
-		p.log.Error(err)
 		return nil, err
 	}
 
-	output, err = p.enc.DecryptDetached(decodedCipher, mac, decodedVal, iv, cek)
+	var keys [][]byte
+	for _, r := range payload.Recipients {
+		keys = append(keys, base58.Decode(r.Header.Kid))
+	}
+	return keys, nil
+}
+
+// parse decodes the envelope along with its protected payload
+func (p *Packer) parse(data []byte) (msg messages.AuthCryptMsg, payload messages.Payload, err error) {
+	if err = json.Unmarshal(data, &msg); err != nil {
+		return msg, payload, fmt.Errorf(`unmarshalling envelope failed - %v`, err)
+	}
+
+	decodedVal, err := decodeBase64(msg.Protected)
 	if err != nil {
-		p.log.Error(err)
+		return msg, payload, fmt.Errorf(`decoding protected value failed - %v`, err)
+	}
+
+	if err = json.Unmarshal(decodedVal, &payload); err != nil {
+		return msg, payload, fmt.Errorf(`unmarshalling protected payload failed - %v`, err)
+	}
+
+	return msg, payload, nil
+}
+
+func (p *Packer) recipient(recs []messages.Recipient, recPubKey []byte) (messages.Recipient, bool) {
+	kid := base58.Encode(recPubKey)
+	for _, r := range recs {
+		if r.Header.Kid == kid {
+			return r, true
+		}
+	}
+	return messages.Recipient{}, false
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
 		return nil, err
 	}
+	return b, nil
+}
 
-	return output, nil
+// decodeBase64 accepts both padded and unpadded values of base64url as well
+// as the standard encoding since agents differ in the encodings they use
+func decodeBase64(val string) ([]byte, error) {
+	val = strings.TrimRight(val, `=`)
+	if strings.ContainsAny(val, `+/`) {
+		return base64.RawStdEncoding.DecodeString(val)
+	}
+	return base64.RawURLEncoding.DecodeString(val)
 }
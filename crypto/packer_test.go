@@ -0,0 +1,466 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/tryfix/log"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"math/big"
+	"testing"
+)
+
+// Envelopes are validated against an independent implementation of RFC-0019
+// built on golang.org/x/crypto instead of libsodium, which also derives the
+// X25519 keys from the Ed25519 keys independently.
+
+// keyPair holds the Ed25519 keys used by the agent along with the
+// corresponding X25519 keys used by the reference implementation
+type keyPair struct {
+	pub, prv   []byte
+	xPub, xPrv *[32]byte
+}
+
+func newKeyPair(t *testing.T) keyPair {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatalf(`generating seed failed - %v`, err)
+	}
+	return keyPairFromSeed(seed)
+}
+
+func keyPairFromSeed(seed []byte) keyPair {
+	prv := ed25519.NewKeyFromSeed(seed)
+	h := sha512.Sum512(seed)
+	var xPub, xPrv [32]byte
+	copy(xPrv[:], h[:32])
+	xPrv[0] &= 248
+	xPrv[31] &= 127
+	xPrv[31] |= 64
+	curve25519.ScalarBaseMult(&xPub, &xPrv)
+	return keyPair{pub: prv.Public().(ed25519.PublicKey), prv: prv, xPub: &xPub, xPrv: &xPrv}
+}
+
+// refX25519PubKey maps an Ed25519 key to X25519 with u = (1 + y) / (1 - y)
+func refX25519PubKey(t *testing.T, edKey []byte) *[32]byte {
+	p, _ := new(big.Int).SetString(`7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed`, 16)
+	le := make([]byte, 32)
+	for i := range edKey {
+		le[31-i] = edKey[i]
+	}
+	le[0] &= 0x7f
+
+	y := new(big.Int).SetBytes(le)
+	den := new(big.Int).Sub(big.NewInt(1), y)
+	den.Mod(den, p)
+	if den.ModInverse(den, p) == nil {
+		t.Fatalf(`invalid verification key %x`, edKey)
+	}
+
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, den).Mod(u, p)
+	be := u.FillBytes(make([]byte, 32))
+
+	var xKey [32]byte
+	for i := range be {
+		xKey[31-i] = be[i]
+	}
+	return &xKey
+}
+
+func newTestPacker() *Packer {
+	return NewPacker(log.Constructor.Log(log.WithLevel(log.FATAL)))
+}
+
+func marshal(t *testing.T, msg messages.AuthCryptMsg) []byte {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf(`marshalling envelope failed - %v`, err)
+	}
+	return data
+}
+
+func decodeURL(t *testing.T, val string) []byte {
+	b, err := base64.URLEncoding.DecodeString(val)
+	if err != nil {
+		t.Fatalf(`value is not padded base64url (%s) - %v`, val, err)
+	}
+	return b
+}
+
+// refUnpack opens an envelope by following the steps of RFC-0019
+func refUnpack(t *testing.T, msg messages.AuthCryptMsg, rec keyPair) (output []byte, sender string) {
+	var payload messages.Payload
+	if err := json.Unmarshal(decodeURL(t, msg.Protected), &payload); err != nil {
+		t.Fatalf(`unmarshalling protected payload failed - %v`, err)
+	}
+
+	if payload.Typ != typJWM || payload.Enc != encXChacha {
+		t.Fatalf(`unexpected protected header (typ=%s, enc=%s)`, payload.Typ, payload.Enc)
+	}
+
+	var r *messages.Recipient
+	for i := range payload.Recipients {
+		if payload.Recipients[i].Header.Kid == base58.Encode(rec.pub) {
+			r = &payload.Recipients[i]
+		}
+	}
+	if r == nil {
+		t.Fatal(`recipient key is not included in the envelope`)
+	}
+
+	var cek []byte
+	var ok bool
+	switch payload.Alg {
+	case algAuthcrypt:
+		encSender := decodeURL(t, r.Header.Sender)
+		senderByts, ok := box.OpenAnonymous(nil, encSender, rec.xPub, rec.xPrv)
+		if !ok {
+			t.Fatal(`opening sender failed`)
+		}
+		sender = string(senderByts)
+
+		nonce := decodeURL(t, r.Header.Iv)
+		if len(nonce) != 24 {
+			t.Fatalf(`invalid cek nonce length (%d)`, len(nonce))
+		}
+
+		var n [24]byte
+		copy(n[:], nonce)
+		sendPub := refX25519PubKey(t, base58.Decode(sender))
+		cek, ok = box.Open(nil, decodeURL(t, r.EncryptedKey), &n, sendPub, rec.xPrv)
+		if !ok {
+			t.Fatal(`opening cek failed`)
+		}
+	case algAnoncrypt:
+		if r.Header.Sender != `` || r.Header.Iv != `` {
+			t.Fatal(`anoncrypt recipient header should only contain kid`)
+		}
+
+		cek, ok = box.OpenAnonymous(nil, decodeURL(t, r.EncryptedKey), rec.xPub, rec.xPrv)
+		if !ok {
+			t.Fatal(`opening cek failed`)
+		}
+	default:
+		t.Fatalf(`unexpected alg %s`, payload.Alg)
+	}
+
+	if len(cek) != chacha20poly1305.KeySize {
+		t.Fatalf(`invalid cek length (%d)`, len(cek))
+	}
+
+	aead, err := chacha20poly1305.NewX(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iv := decodeURL(t, msg.Iv)
+	if len(iv) != chacha20poly1305.NonceSizeX {
+		t.Fatalf(`invalid iv length (%d)`, len(iv))
+	}
+
+	sealed := append(decodeURL(t, msg.Ciphertext), decodeURL(t, msg.Tag)...)
+	output, err = aead.Open(nil, iv, sealed, []byte(msg.Protected))
+	if err != nil {
+		t.Fatalf(`decrypting content failed - %v`, err)
+	}
+
+	return output, sender
+}
+
+// refPack creates an authcrypt envelope as other agents would, using the
+// given content encryption algorithm and unpadded base64url values
+func refPack(t *testing.T, input []byte, enc string, rec, sender keyPair) []byte {
+	cek := make([]byte, chacha20poly1305.KeySize)
+	var nonce [24]byte
+	rand.Read(cek)
+	rand.Read(nonce[:])
+
+	encSender, err := box.SealAnonymous(nil, []byte(base58.Encode(sender.pub)), rec.xPub, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := messages.Payload{
+		Enc: enc,
+		Typ: typJWM,
+		Alg: algAuthcrypt,
+		Recipients: []messages.Recipient{{
+			EncryptedKey: base64.RawURLEncoding.EncodeToString(box.Seal(nil, cek, &nonce, rec.xPub, sender.xPrv)),
+			Header: messages.Header{
+				Kid:    base58.Encode(rec.pub),
+				Iv:     base64.RawURLEncoding.EncodeToString(nonce[:]),
+				Sender: base64.RawURLEncoding.EncodeToString(encSender),
+			},
+		}},
+	}
+
+	payloadByts, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(payloadByts)
+
+	aead, err := chacha20poly1305.New(cek)
+	if enc == encXChacha {
+		aead, err = chacha20poly1305.NewX(cek)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iv := make([]byte, aead.NonceSize())
+	rand.Read(iv)
+	sealed := aead.Seal(nil, iv, input, []byte(protected))
+	tagIndex := len(sealed) - aead.Overhead()
+
+	return marshal(t, messages.AuthCryptMsg{
+		Protected:  protected,
+		Iv:         base64.RawURLEncoding.EncodeToString(iv),
+		Ciphertext: base64.RawURLEncoding.EncodeToString(sealed[:tagIndex]),
+		Tag:        base64.RawURLEncoding.EncodeToString(sealed[tagIndex:]),
+	})
+}
+
+func TestPacker_Authcrypt(t *testing.T) {
+	p := newTestPacker()
+	rec, sender := newKeyPair(t), newKeyPair(t)
+	input := []byte(`{"@type":"https://didcomm.org/basicmessage/1.0/message","content":"hello"}`)
+
+	msg, err := p.Pack(input, [][]byte{rec.pub}, sender.pub, sender.prv)
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	output, senderKid := refUnpack(t, msg, rec)
+	if !bytes.Equal(output, input) {
+		t.Errorf(`reference unpack returned %s, expected %s`, output, input)
+	}
+
+	if senderKid != base58.Encode(sender.pub) {
+		t.Errorf(`sender should be the base58 encoded key, got %s`, senderKid)
+	}
+
+	output, sendPubKey, err := p.Unpack(marshal(t, msg), rec.pub, rec.prv)
+	if err != nil {
+		t.Fatalf(`unpacking failed - %v`, err)
+	}
+
+	if !bytes.Equal(output, input) || !bytes.Equal(sendPubKey, sender.pub) {
+		t.Errorf(`unpack returned (%s, %x), expected (%s, %x)`, output, sendPubKey, input, sender.pub)
+	}
+}
+
+func TestPacker_Anoncrypt(t *testing.T) {
+	p := newTestPacker()
+	rec := newKeyPair(t)
+	input := []byte(`anonymous`)
+
+	msg, err := p.PackAnon(input, [][]byte{rec.pub})
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	if output, _ := refUnpack(t, msg, rec); !bytes.Equal(output, input) {
+		t.Errorf(`reference unpack returned %s, expected %s`, output, input)
+	}
+
+	output, sendPubKey, err := p.Unpack(marshal(t, msg), rec.pub, rec.prv)
+	if err != nil {
+		t.Fatalf(`unpacking failed - %v`, err)
+	}
+
+	if !bytes.Equal(output, input) || sendPubKey != nil {
+		t.Errorf(`unpack returned (%s, %x), expected (%s, nil)`, output, sendPubKey, input)
+	}
+}
+
+func TestPacker_MultipleRecipients(t *testing.T) {
+	p := newTestPacker()
+	recs, sender := []keyPair{newKeyPair(t), newKeyPair(t), newKeyPair(t)}, newKeyPair(t)
+	input := []byte(`group message`)
+
+	var recKeys [][]byte
+	for _, r := range recs {
+		recKeys = append(recKeys, r.pub)
+	}
+
+	msg, err := p.Pack(input, recKeys, sender.pub, sender.prv)
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	data := marshal(t, msg)
+	for i, r := range recs {
+		output, _, err := p.Unpack(data, r.pub, r.prv)
+		if err != nil {
+			t.Fatalf(`unpacking failed for recipient %d - %v`, i, err)
+		}
+
+		if !bytes.Equal(output, input) {
+			t.Errorf(`recipient %d received %s, expected %s`, i, output, input)
+		}
+	}
+}
+
+func TestPacker_UnpackReference(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  string
+	}{
+		{name: `xchacha20poly1305_ietf`, enc: encXChacha},
+		{name: `chacha20poly1305_ietf`, enc: encChacha},
+	}
+
+	p := newTestPacker()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec, sender := newKeyPair(t), newKeyPair(t)
+			input := []byte(`packed by another agent`)
+
+			output, sendPubKey, err := p.Unpack(refPack(t, input, test.enc, rec, sender), rec.pub, rec.prv)
+			if err != nil {
+				t.Fatalf(`unpacking failed - %v`, err)
+			}
+
+			if !bytes.Equal(output, input) || !bytes.Equal(sendPubKey, sender.pub) {
+				t.Errorf(`unpack returned (%s, %x), expected (%s, %x)`, output, sendPubKey, input, sender.pub)
+			}
+		})
+	}
+}
+
+func TestPacker_UnpackErrors(t *testing.T) {
+	p := newTestPacker()
+	rec, sender, other := newKeyPair(t), newKeyPair(t), newKeyPair(t)
+
+	msg, err := p.Pack([]byte(`secret`), [][]byte{rec.pub}, sender.pub, sender.prv)
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	if _, _, err = p.Unpack(marshal(t, msg), other.pub, other.prv); err == nil {
+		t.Error(`unpacking with a key which is not a recipient should fail`)
+	}
+
+	tampered := msg
+	tag := decodeURL(t, msg.Tag)
+	tag[0] ^= 0xff
+	tampered.Tag = base64.URLEncoding.EncodeToString(tag)
+	if _, _, err = p.Unpack(marshal(t, tampered), rec.pub, rec.prv); err == nil {
+		t.Error(`unpacking a message with a modified tag should fail`)
+	}
+
+	tampered = msg
+	tampered.Iv = base64.URLEncoding.EncodeToString(make([]byte, chachaIv))
+	if _, _, err = p.Unpack(marshal(t, tampered), rec.pub, rec.prv); err == nil {
+		t.Error(`unpacking a message with an iv of invalid length should fail`)
+	}
+}
+
+// TestX25519Keys checks the conversion against the published vector of
+// libsodium (test/default/ed25519_convert) and the reference derivation
+func TestX25519Keys(t *testing.T) {
+	seed, _ := hex.DecodeString(`421151a459faeade3d247115f94aedae42318124095afabe4d1451a559faedee`)
+	kp := keyPairFromSeed(seed)
+
+	xPub, err := x25519PubKey(kp.pub)
+	if err != nil {
+		t.Fatalf(`converting public key failed - %v`, err)
+	}
+
+	xPrv, err := x25519PrvKey(kp.prv)
+	if err != nil {
+		t.Fatalf(`converting private key failed - %v`, err)
+	}
+
+	if hex.EncodeToString(xPub) != `f1814f0e8ff1043d8a44d25babff3cedcae6c22c3edaa48f857ae70de2baae50` {
+		t.Errorf(`unexpected X25519 public key %x`, xPub)
+	}
+
+	if hex.EncodeToString(xPrv) != `8052030376d47112be7f73ed7a019293dd12ad910b654455798b4667d73de166` {
+		t.Errorf(`unexpected X25519 private key %x`, xPrv)
+	}
+
+	if !bytes.Equal(xPub, kp.xPub[:]) || !bytes.Equal(xPub, refX25519PubKey(t, kp.pub)[:]) {
+		t.Errorf(`converted key %x does not match the reference derivation`, xPub)
+	}
+
+	if _, err = x25519PubKey(kp.pub[:16]); err == nil {
+		t.Error(`converting a key of invalid length should fail`)
+	}
+}
+
+// TestPacker_VerificationKeyIds uses the keys of RFC-8032 (test 1 and 2) such
+// that the key ids are the published verification keys
+func TestPacker_VerificationKeyIds(t *testing.T) {
+	recSeed, _ := hex.DecodeString(`9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60`)
+	sendSeed, _ := hex.DecodeString(`4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb`)
+	recVerKey, _ := hex.DecodeString(`d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a`)
+	sendVerKey, _ := hex.DecodeString(`3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c`)
+	rec, sender := keyPairFromSeed(recSeed), keyPairFromSeed(sendSeed)
+
+	p := newTestPacker()
+	msg, err := p.Pack([]byte(`verkeys`), [][]byte{rec.pub}, sender.pub, sender.prv)
+	if err != nil {
+		t.Fatalf(`packing failed - %v`, err)
+	}
+
+	var payload messages.Payload
+	if err = json.Unmarshal(decodeURL(t, msg.Protected), &payload); err != nil {
+		t.Fatalf(`unmarshalling protected payload failed - %v`, err)
+	}
+
+	if kid := payload.Recipients[0].Header.Kid; kid != base58.Encode(recVerKey) {
+		t.Errorf(`kid should be the verification key %s, got %s`, base58.Encode(recVerKey), kid)
+	}
+
+	if _, senderKid := refUnpack(t, msg, rec); senderKid != base58.Encode(sendVerKey) {
+		t.Errorf(`sender should be the verification key %s, got %s`, base58.Encode(sendVerKey), senderKid)
+	}
+
+	keys, err := p.RecipientKeys(marshal(t, msg))
+	if err != nil || len(keys) != 1 || !bytes.Equal(keys[0], recVerKey) {
+		t.Errorf(`recipient keys should be [%x], got %x (%v)`, recVerKey, keys, err)
+	}
+}
+
+// TestPacker_UnpackFixture unpacks a fixed authcrypt envelope with padded
+// base64url values which was produced by the reference implementation above
+// with the keys of RFC-8032 (test 1 as recipient and test 2 as sender), a
+// fixed cek, nonces and ephemeral key. The envelope is a regression fixture
+// rather than a published vector of another agent, and hence only guards the
+// unpacking against changes of the packer instead of proving interoperability.
+func TestPacker_UnpackFixture(t *testing.T) {
+	recSeed, _ := hex.DecodeString(`9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60`)
+	sendVerKey, _ := hex.DecodeString(`3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c`)
+	rec := keyPairFromSeed(recSeed)
+
+	envelope := `{` +
+		`"protected":"eyJlbmMiOiJ4Y2hhY2hhMjBwb2x5MTMwNV9pZXRmIiwidHlwIjoiSldNLzEuMCIsImFsZyI6IkF1dGhjcnlwdCIsInJlY2lwaWVudHMiOlt7ImVuY3J5cHRlZF9rZXkiOiJkS1JlbFBqcWEzTGFKVDdwazVTLWozMUc4OGdhN1BxdHl4N19LUURCQndmWUZ4c0YxcS12LVNhVFFQYV9SYXg5IiwiaGVhZGVyIjp7ImtpZCI6IkZWZW4zWDY2OXhMenNpNk4yVjkxRG9peXpIemcxdUFncWlUOGpaOW5TOTZaIiwiaXYiOiJRRUZDUTBSRlJrZElTVXBMVEUxT1QxQlJVbE5VVlZaWCIsInNlbmRlciI6InNOQ1BOYlJvTTRGSW12c3lnbDVaRlMxSDBadko0RkRXMWFsVW1FeWRIaXp6UnRaTlVnSnNLaUxhMFFLSkd0Q1VkSVBMZWd0U1NiVGdybTlrcFp6VkoyZUlLNXhoUFUyd1VIT0h4aDFjRWpTS2Y1VENpZnJDTE1MSUdWMD0ifX1dfQ==",` +
+		`"iv":"gIGCg4SFhoeIiYqLjI2Oj5CRkpOUlZaX",` +
+		`"ciphertext":"OcwZMIO2eUgkeRGYed4rtFSkOuLg1o6Y01xH49PmL1xcLlN3Sti2zIXjy9srxRRyX__AeDNZANiSpSsSAF2mbmQ0vqpQjqRbT6Dxltao-9ckOkunYXbNLWpY0XDk49RbfsGA5p-8stcfvzzPoEx7Y0M9O3vnp6xGdjAqxPg8",` +
+		`"tag":"utby7sOctph6H0VHkl_ifQ=="}`
+	expected := `{"@id":"8a2c6c3e-5f3c-4a36-9d43-1b0f2b6f6f0a","@type":"https://didcomm.org/basicmessage/1.0/message","content":"known answer"}`
+
+	output, sendPubKey, err := newTestPacker().Unpack([]byte(envelope), rec.pub, rec.prv)
+	if err != nil {
+		t.Fatalf(`unpacking failed - %v`, err)
+	}
+
+	if string(output) != expected {
+		t.Errorf(`unpack returned %s, expected %s`, output, expected)
+	}
+
+	if !bytes.Equal(sendPubKey, sendVerKey) {
+		t.Errorf(`sender key should be %x, got %x`, sendVerKey, sendPubKey)
+	}
+}
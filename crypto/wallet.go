@@ -0,0 +1,371 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	walletVersion = 1
+	walletAad     = `didcomm-prober-wallet/1`
+	kdfArgon2id   = `argon2id`
+	saltBytes     = 16
+)
+
+// parameters of argon2id for memory-constrained environments as recommended
+// by RFC-9106 (section 4). Parameters are stored with each wallet file and
+// hence wallets created with different ones remain readable.
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// walletFile is the persisted format of the wallet where the content is
+// encrypted with xchacha20poly1305_ietf under the key derived from the passphrase
+type walletFile struct {
+	Version    int       `json:"version"`
+	KDF        kdfParams `json:"kdf"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Tag        []byte    `json:"tag"`
+}
+
+type kdfParams struct {
+	Alg     string `json:"alg"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+type storedKeys struct {
+	Pub []byte `json:"pub"`
+	Prv []byte `json:"prv"`
+}
+
+type storedRetiredKeys struct {
+	storedKeys
+	Peer    string    `json:"peer"`
+	Expires time.Time `json:"expires"`
+}
+
+type walletContent struct {
+	Invs    map[string]storedKeys        `json:"invs"`
+	Inviter *storedKeys                  `json:"inviter,omitempty"`
+	Routing *storedKeys                  `json:"routing,omitempty"`
+	Conns   map[string]storedKeys        `json:"conns"`
+	Retired map[string]storedRetiredKeys `json:"retired"`
+	Groups  map[string]storedKeys        `json:"groups"`
+	Signing map[string]storedKeys        `json:"signing"`
+}
+
+// FileWallet is a KeyManager which persists all keys to a file encrypted
+// at rest with a key derived from the passphrase using argon2id. Keys are
+// served from memory and the file is rewritten whenever keys are generated.
+type FileWallet struct {
+	*KeyManager
+	path string
+	enc  *encryptor
+	kdf  kdfParams
+	key  []byte
+	lock *sync.Mutex
+}
+
+// NewFileWallet opens the wallet at path or creates a new one if the file
+// does not exist
+func NewFileWallet(path, passphrase string) (*FileWallet, error) {
+	if passphrase == `` {
+		return nil, errors.New(`passphrase of the wallet should not be empty`)
+	}
+
+	w := &FileWallet{KeyManager: NewKeyManager(), path: path, enc: &encryptor{}, lock: &sync.Mutex{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		salt, err := random(saltBytes)
+		if err != nil {
+			return nil, fmt.Errorf(`generating salt failed - %v`, err)
+		}
+
+		w.kdf = kdfParams{Alg: kdfArgon2id, Salt: salt, Time: argonTime, Memory: argonMemory, Threads: argonThreads}
+		w.key = w.deriveKey(passphrase)
+		if err = w.save(); err != nil {
+			return nil, fmt.Errorf(`creating wallet failed - %v`, err)
+		}
+		return w, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(`reading wallet failed - %v`, err)
+	}
+
+	if err = w.load(data, passphrase); err != nil {
+		return nil, fmt.Errorf(`opening wallet failed - %v`, err)
+	}
+
+	return w, nil
+}
+
+func (w *FileWallet) GenerateKeys(peer string) error {
+	if err := w.KeyManager.GenerateKeys(peer); err != nil {
+		return err
+	}
+	return w.save()
+}
+
+func (w *FileWallet) RotateKeys(peer string, grace time.Duration) error {
+	if err := w.KeyManager.RotateKeys(peer, grace); err != nil {
+		return err
+	}
+	return w.save()
+}
+
+func (w *FileWallet) RestoreKeys(peer string, pubKey []byte) error {
+	if err := w.KeyManager.RestoreKeys(peer, pubKey); err != nil {
+		return err
+	}
+	return w.save()
+}
+
+func (w *FileWallet) GenerateInvKeys(invId string) error {
+	if err := w.KeyManager.GenerateInvKeys(invId); err != nil {
+		return err
+	}
+	return w.save()
+}
+
+func (w *FileWallet) RemoveInvKeys(invId string) error {
+	if err := w.KeyManager.RemoveInvKeys(invId); err != nil {
+		return err
+	}
+	return w.save()
+}
+
+// the following keys are generated only once and hence the wallet
+// is rewritten only if they did not exist already
+
+func (w *FileWallet) GenerateInviterKeys() error {
+	return w.saveIfCreated(w.generateOnce(&w.inviter))
+}
+
+func (w *FileWallet) GenerateRoutingKeys() error {
+	return w.saveIfCreated(w.generateOnce(&w.routing))
+}
+
+func (w *FileWallet) GenerateGroupKeys(topic string) error {
+	return w.saveIfCreated(generateByTopic(w.grpKeys, topic))
+}
+
+func (w *FileWallet) GenerateSigningKeys(topic string) error {
+	return w.saveIfCreated(generateByTopic(w.sigKeys, topic))
+}
+
+func (w *FileWallet) saveIfCreated(created bool, err error) error {
+	if err != nil || !created {
+		return err
+	}
+	return w.save()
+}
+
+func (w *FileWallet) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), w.kdf.Salt, w.kdf.Time, w.kdf.Memory, w.kdf.Threads, cekBytes)
+}
+
+func (w *FileWallet) load(data []byte, passphrase string) error {
+	var file walletFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf(`unmarshalling wallet file failed - %v`, err)
+	}
+
+	if file.Version != walletVersion {
+		return fmt.Errorf(`unsupported wallet version (%d)`, file.Version)
+	}
+
+	if file.KDF.Alg != kdfArgon2id || len(file.KDF.Salt) != saltBytes {
+		return fmt.Errorf(`unsupported key derivation (%s)`, file.KDF.Alg)
+	}
+
+	w.kdf = file.KDF
+	w.key = w.deriveKey(passphrase)
+	plaintext, err := w.enc.DecryptDetached(file.Ciphertext, file.Tag, []byte(walletAad), file.Nonce, w.key)
+	if err != nil {
+		return errors.New(`invalid passphrase or corrupted wallet`)
+	}
+
+	var content walletContent
+	if err = json.Unmarshal(plaintext, &content); err != nil {
+		return fmt.Errorf(`unmarshalling wallet content failed - %v`, err)
+	}
+
+	return w.restore(content)
+}
+
+// restore populates the in-memory stores with the persisted keys
+func (w *FileWallet) restore(content walletContent) error {
+	for id, sk := range content.Invs {
+		k, err := sk.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid keys for the invitation %s - %v`, id, err)
+		}
+		w.invKeys.Store(id, k)
+	}
+
+	if content.Inviter != nil {
+		k, err := content.Inviter.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid inviter keys - %v`, err)
+		}
+		w.setAgentKeys(&w.inviter, k)
+	}
+
+	if content.Routing != nil {
+		k, err := content.Routing.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid routing keys - %v`, err)
+		}
+		w.setAgentKeys(&w.routing, k)
+	}
+
+	for peer, sk := range content.Conns {
+		k, err := sk.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid keys for the connection with %s - %v`, peer, err)
+		}
+		w.keyStore.Store(peer, k)
+	}
+
+	for id, rk := range content.Retired {
+		if time.Now().After(rk.Expires) {
+			continue
+		}
+
+		k, err := rk.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid retired keys for the connection with %s - %v`, rk.Peer, err)
+		}
+		w.retired.Store(id, retiredKeys{keys: k, peer: rk.Peer, expires: rk.Expires})
+	}
+
+	for topic, sk := range content.Groups {
+		k, err := sk.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid group keys for %s - %v`, topic, err)
+		}
+		w.grpKeys.Store(topic, k)
+	}
+
+	for topic, sk := range content.Signing {
+		k, err := sk.keys()
+		if err != nil {
+			return fmt.Errorf(`invalid signing keys for %s - %v`, topic, err)
+		}
+		w.sigKeys.Store(topic, k)
+	}
+
+	return nil
+}
+
+// save encrypts a snapshot of all keys and replaces the wallet file
+func (w *FileWallet) save() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	plaintext, err := json.Marshal(w.snapshot())
+	if err != nil {
+		return fmt.Errorf(`marshalling wallet content failed - %v`, err)
+	}
+
+	nonce, err := random(xchachaIv)
+	if err != nil {
+		return fmt.Errorf(`generating nonce failed - %v`, err)
+	}
+
+	cipher, tag, err := w.enc.EncryptDetached(string(plaintext), walletAad, nonce, w.key)
+	if err != nil {
+		return fmt.Errorf(`encrypting wallet failed - %v`, err)
+	}
+
+	data, err := json.Marshal(walletFile{Version: walletVersion, KDF: w.kdf, Nonce: nonce, Ciphertext: cipher, Tag: tag})
+	if err != nil {
+		return fmt.Errorf(`marshalling wallet file failed - %v`, err)
+	}
+
+	// written to a temporary file first so that a failure does not corrupt the wallet
+	tmpPath := w.path + `.tmp`
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf(`writing wallet failed - %v`, err)
+	}
+
+	if err = os.Rename(tmpPath, w.path); err != nil {
+		return fmt.Errorf(`replacing wallet failed - %v`, err)
+	}
+
+	return nil
+}
+
+func (w *FileWallet) snapshot() walletContent {
+	content := walletContent{
+		Invs:    map[string]storedKeys{},
+		Conns:   map[string]storedKeys{},
+		Retired: map[string]storedRetiredKeys{},
+		Groups:  map[string]storedKeys{},
+		Signing: map[string]storedKeys{},
+	}
+
+	w.invKeys.Range(func(key, val any) bool {
+		k := val.(keys)
+		content.Invs[key.(string)] = storedKeys{Pub: k.pub, Prv: k.prv}
+		return true
+	})
+
+	if k := w.agentKeys(&w.inviter); k != nil {
+		content.Inviter = &storedKeys{Pub: k.pub, Prv: k.prv}
+	}
+
+	if k := w.agentKeys(&w.routing); k != nil {
+		content.Routing = &storedKeys{Pub: k.pub, Prv: k.prv}
+	}
+
+	w.keyStore.Range(func(key, val any) bool {
+		k := val.(keys)
+		content.Conns[key.(string)] = storedKeys{Pub: k.pub, Prv: k.prv}
+		return true
+	})
+
+	w.retired.Range(func(key, val any) bool {
+		rk := val.(retiredKeys)
+		content.Retired[key.(string)] = storedRetiredKeys{
+			storedKeys: storedKeys{Pub: rk.pub, Prv: rk.prv},
+			Peer:       rk.peer,
+			Expires:    rk.expires,
+		}
+		return true
+	})
+
+	w.grpKeys.Range(func(key, val any) bool {
+		k := val.(keys)
+		content.Groups[key.(string)] = storedKeys{Pub: k.pub, Prv: k.prv}
+		return true
+	})
+
+	w.sigKeys.Range(func(key, val any) bool {
+		k := val.(keys)
+		content.Signing[key.(string)] = storedKeys{Pub: k.pub, Prv: k.prv}
+		return true
+	})
+
+	return content
+}
+
+func (s storedKeys) keys() (keys, error) {
+	if len(s.Pub) != ed25519.PublicKeySize || len(s.Prv) != ed25519.PrivateKeySize {
+		return keys{}, errors.New(`keys should be an Ed25519 key pair`)
+	}
+	return keys{pub: s.Pub, prv: s.Prv}, nil
+}
@@ -0,0 +1,194 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/btcsuite/btcutil/base58"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPassphrase = `correct horse battery staple`
+
+func newTestWallet(t *testing.T) (*FileWallet, string) {
+	path := filepath.Join(t.TempDir(), `wallet.json`)
+	w, err := NewFileWallet(path, testPassphrase)
+	if err != nil {
+		t.Fatalf(`creating wallet failed - %v`, err)
+	}
+	return w, path
+}
+
+func TestFileWallet_Reopen(t *testing.T) {
+	w, path := newTestWallet(t)
+	if err := w.GenerateKeys(`bob`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.GenerateInvKeys(`inv`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gen := range []func() error{w.GenerateInviterKeys, w.GenerateRoutingKeys} {
+		if err := gen(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.GenerateGroupKeys(`topic`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.GenerateSigningKeys(`topic`); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileWallet(path, testPassphrase)
+	if err != nil {
+		t.Fatalf(`reopening wallet failed - %v`, err)
+	}
+
+	tests := []struct {
+		name string
+		key  func(km *KeyManager) ([]byte, error)
+	}{
+		{`connection`, func(km *KeyManager) ([]byte, error) { return km.PrivateKey(`bob`) }},
+		{`invitation`, func(km *KeyManager) ([]byte, error) { return km.InvPrivateKey(`inv`) }},
+		{`inviter`, func(km *KeyManager) ([]byte, error) { return km.InviterPublicKey(), nil }},
+		{`routing`, func(km *KeyManager) ([]byte, error) { return km.RoutingPrivateKey(), nil }},
+		{`group`, func(km *KeyManager) ([]byte, error) { return km.GroupPrivateKey(`topic`) }},
+		{`signing`, func(km *KeyManager) ([]byte, error) { return km.SigningPrivateKey(`topic`) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want, err := test.key(w.KeyManager)
+			if err != nil || len(want) == 0 {
+				t.Fatalf(`keys were not generated (err: %v)`, err)
+			}
+
+			got, err := test.key(reopened.KeyManager)
+			if err != nil {
+				t.Fatalf(`keys were not restored - %v`, err)
+			}
+
+			if !bytes.Equal(want, got) {
+				t.Error(`restored keys do not match the generated keys`)
+			}
+		})
+	}
+}
+
+func TestFileWallet_WrongPassphrase(t *testing.T) {
+	w, path := newTestWallet(t)
+	if err := w.GenerateKeys(`bob`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileWallet(path, `wrong passphrase`); err == nil {
+		t.Error(`opening the wallet with a wrong passphrase should fail`)
+	}
+}
+
+func TestFileWallet_Tampered(t *testing.T) {
+	w, path := newTestWallet(t)
+	if err := w.GenerateKeys(`bob`); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file walletFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	file.Ciphertext[0] ^= 0x01
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewFileWallet(path, testPassphrase); err == nil {
+		t.Error(`opening a wallet with a tampered ciphertext should fail`)
+	}
+}
+
+func TestFileWallet_ExpiredRetiredKeys(t *testing.T) {
+	w, path := newTestWallet(t)
+	for _, peer := range []string{`bob`, `charlie`} {
+		if err := w.GenerateKeys(peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expired, _ := w.PublicKey(`bob`)
+	if err := w.RotateKeys(`bob`, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	active, _ := w.PublicKey(`charlie`)
+	if err := w.RotateKeys(`charlie`, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileWallet(path, testPassphrase)
+	if err != nil {
+		t.Fatalf(`reopening wallet failed - %v`, err)
+	}
+
+	if _, ok := reopened.retired.Load(base58.Encode(expired)); ok {
+		t.Error(`retired keys with an elapsed grace period should be dropped on load`)
+	}
+
+	if _, ok := reopened.retired.Load(base58.Encode(active)); !ok {
+		t.Error(`retired keys within the grace period should be restored`)
+	}
+}
+
+// TestFileWallet_GenerateOnce checks that the wallet is not rewritten
+// when keys which are generated once exist already
+func TestFileWallet_GenerateOnce(t *testing.T) {
+	w, path := newTestWallet(t)
+	gens := []func() error{
+		w.GenerateInviterKeys,
+		w.GenerateRoutingKeys,
+		func() error { return w.GenerateGroupKeys(`topic`) },
+		func() error { return w.GenerateSigningKeys(`topic`) },
+	}
+
+	for _, gen := range gens {
+		if err := gen(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gen := range gens {
+		if err = gen(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each save encrypts with a fresh nonce
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(saved, data) {
+		t.Error(`wallet should not be rewritten for existing keys`)
+	}
+}
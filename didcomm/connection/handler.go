@@ -15,18 +15,13 @@ func NewConnector() *Connector {
 }
 
 func (c *Connector) CreateConnReq(label, pthid, did string, encDidDoc messages.AuthCryptMsg) (messages.ConnReq, error) {
-	id := uuid.New().String()
 	req := messages.ConnReq{
-		Id:   id,
-		Type: messages.DIDExchangeReqV1,
-		Thread: struct {
-			ThId  string `json:"thid"`
-			PThId string `json:"pthid"`
-		}{ThId: id, PThId: pthid},
-		Label: label,
-		Goal:  "connection establishment",
-		DID:   did,
+		Message: messages.NewMessage(messages.DIDExchangeReqV1),
+		Label:   label,
+		Goal:    "connection establishment",
+		DID:     did,
 	}
+	req.SetThread(req.Id, pthid)
 
 	// marshals the encrypted did doc
 	encDocBytes, err := json.Marshal(encDidDoc)
@@ -41,29 +36,27 @@ func (c *Connector) CreateConnReq(label, pthid, did string, encDidDoc messages.A
 	return req, nil
 }
 
-func (c *Connector) ParseConnReq(data []byte) (label, exchThId, peerDid string, encDocBytes []byte, err error) {
+func (c *Connector) ParseConnReq(data []byte) (label, exchThId, invId, peerDid string, encDocBytes []byte, err error) {
 	var req messages.ConnReq
 	if err = json.Unmarshal(data, &req); err != nil {
-		return ``, ``, ``, nil, fmt.Errorf(`unmarshalling connection request failed - %v`, err)
+		return ``, ``, ``, ``, nil, fmt.Errorf(`unmarshalling connection request failed - %v`, err)
+	}
+
+	if req.Expired() {
+		return ``, ``, ``, ``, nil, fmt.Errorf(`connection request has expired`)
 	}
 
 	encDocBytes, err = base64.StdEncoding.DecodeString(req.DIDDocAttach.Data.Base64)
 	if err != nil {
-		return ``, ``, ``, nil, fmt.Errorf(`decoding did doc failed - %v`, err)
+		return ``, ``, ``, ``, nil, fmt.Errorf(`decoding did doc failed - %v`, err)
 	}
 
-	return req.Label, req.Thread.ThId, req.DID, encDocBytes, nil
+	return req.Label, req.ThId(), req.PThId(), req.DID, encDocBytes, nil
 }
 
 func (c *Connector) CreateConnRes(pthId, did string, encDidDoc messages.AuthCryptMsg) (messages.ConnRes, error) {
-	res := messages.ConnRes{
-		Id:   uuid.New().String(),
-		Type: messages.DIDExchangeResV1,
-		Thread: struct {
-			ThId string `json:"thid"`
-		}{ThId: pthId},
-		DID: did,
-	}
+	res := messages.ConnRes{Message: messages.NewMessage(messages.DIDExchangeResV1), DID: did}
+	res.SetThread(pthId, ``)
 
 	// marshals the encrypted did doc
 	encDocBytes, err := json.Marshal(encDidDoc)
@@ -78,16 +71,86 @@ func (c *Connector) CreateConnRes(pthId, did string, encDidDoc messages.AuthCryp
 	return res, nil
 }
 
-func (c *Connector) ParseConnRes(data []byte) (exchThId string, encDocBytes []byte, err error) {
+func (c *Connector) ParseConnRes(data []byte) (exchThId, peerDid string, encDocBytes []byte, err error) {
 	var res messages.ConnRes
 	if err = json.Unmarshal(data, &res); err != nil {
-		return ``, nil, fmt.Errorf(`unmarshalling connection response failed - %v`, err)
+		return ``, ``, nil, fmt.Errorf(`unmarshalling connection response failed - %v`, err)
+	}
+
+	if res.Expired() {
+		return ``, ``, nil, fmt.Errorf(`connection response has expired`)
 	}
 
 	encDocBytes, err = base64.StdEncoding.DecodeString(res.DIDDocAttach.Data.Base64)
 	if err != nil {
-		return ``, nil, fmt.Errorf(`decoding did doc failed - %v`, err)
+		return ``, ``, nil, fmt.Errorf(`decoding did doc failed - %v`, err)
+	}
+
+	return res.ThId(), res.DID, encDocBytes, nil
+}
+
+func (c *Connector) CreateConnComplete(thId, pthId string) messages.ConnComplete {
+	comp := messages.ConnComplete{Message: messages.NewMessage(messages.DIDExchangeCompV1)}
+	comp.SetThread(thId, pthId)
+	return comp
+}
+
+func (c *Connector) ParseConnComplete(data []byte) (thId, pthId string, err error) {
+	var comp messages.ConnComplete
+	if err = json.Unmarshal(data, &comp); err != nil {
+		return ``, ``, fmt.Errorf(`unmarshalling complete message failed - %v`, err)
 	}
 
-	return res.Thread.ThId, encDocBytes, nil
+	if comp.Type != messages.DIDExchangeCompV1 {
+		return ``, ``, fmt.Errorf(`invalid message type for complete message (%s)`, comp.Type)
+	}
+
+	if comp.Expired() {
+		return ``, ``, fmt.Errorf(`complete message has expired`)
+	}
+
+	return comp.ThId(), comp.PThId(), nil
+}
+
+func (c *Connector) CreateRotate(did string, didDoc messages.DIDDocument) (messages.Rotate, error) {
+	rm := messages.Rotate{Message: messages.NewMessage(messages.DIDRotateV1), ToDID: did}
+
+	docBytes, err := json.Marshal(didDoc)
+	if err != nil {
+		return messages.Rotate{}, fmt.Errorf(`marshalling did doc failed - %v`, err)
+	}
+
+	rm.DIDDocAttach.Id = uuid.New().String()
+	rm.DIDDocAttach.MimeType = `application/json`
+	rm.DIDDocAttach.Data.Base64 = base64.StdEncoding.EncodeToString(docBytes)
+
+	return rm, nil
+}
+
+func (c *Connector) ParseRotate(data []byte) (id, toDid string, docBytes []byte, err error) {
+	var rm messages.Rotate
+	if err = json.Unmarshal(data, &rm); err != nil {
+		return ``, ``, nil, fmt.Errorf(`unmarshalling rotate message failed - %v`, err)
+	}
+
+	if rm.Type != messages.DIDRotateV1 {
+		return ``, ``, nil, fmt.Errorf(`invalid message type for rotate (%s)`, rm.Type)
+	}
+
+	if rm.Expired() {
+		return ``, ``, nil, fmt.Errorf(`rotate message has expired`)
+	}
+
+	docBytes, err = base64.StdEncoding.DecodeString(rm.DIDDocAttach.Data.Base64)
+	if err != nil {
+		return ``, ``, nil, fmt.Errorf(`decoding did doc failed - %v`, err)
+	}
+
+	return rm.ThId(), rm.ToDID, docBytes, nil
+}
+
+func (c *Connector) CreateRotateAck(thId string) messages.RotateAck {
+	ack := messages.RotateAck{Message: messages.NewMessage(messages.DIDRotateAckV1), Status: `OK`}
+	ack.SetThread(thId, ``)
+	return ack
 }
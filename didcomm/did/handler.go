@@ -1,7 +1,6 @@
 package did
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -9,6 +8,8 @@ import (
 	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/models"
 	"github.com/btcsuite/btcutil/base58"
+	"strconv"
+	"strings"
 )
 
 type Handler struct{}
@@ -17,28 +18,100 @@ func NewHandler() *Handler {
 	return &Handler{}
 }
 
+// CreateDIDDoc creates a did doc where each distinct key of the services is an
+// Ed25519 authentication key referred to by the services, followed by the X25519
+// key derived from it for key agreement. The id and the controllers are assigned
+// with AssignDID once the did is derived from the doc.
 func (h *Handler) CreateDIDDoc(svcs []models.Service) messages.DIDDocument {
-	var msgSvcs []messages.Service
+	doc := messages.DIDDocument{Context: append(append([]string{}, didContexts...), ctxEd25519, ctxX25519)}
+	keyIds := map[string]string{} // key: base58 encoded key
 	for _, svc := range svcs {
-		encodedKey := make([]byte, 64)
-		base64.StdEncoding.Encode(encodedKey, svc.PubKey)
-		// removes redundant elements from the allocated byte slice
-		encodedKey = bytes.Trim(encodedKey, "\x00")
+		encKey := base58.Encode(svc.PubKey)
+		if _, ok := keyIds[encKey]; !ok {
+			id := `#key-` + strconv.Itoa(len(doc.VerificationMethod)+1)
+			keyIds[encKey] = id
+			doc.VerificationMethod = append(doc.VerificationMethod, messages.VerificationMethod{Id: id, Type: typEd25519Key2018, PublicKeyBase58: encKey})
+			doc.Authentication = append(doc.Authentication, id)
 
-		s := messages.Service{
+			// keys which are not valid Ed25519 keys are only used for authentication
+			if xKey, err := edToX25519(svc.PubKey); err == nil {
+				xId := `#key-` + strconv.Itoa(len(doc.VerificationMethod)+1)
+				doc.VerificationMethod = append(doc.VerificationMethod, messages.VerificationMethod{Id: xId, Type: typX25519Key2019, PublicKeyBase58: base58.Encode(xKey)})
+				doc.KeyAgreement = append(doc.KeyAgreement, xId)
+			}
+		}
+
+		doc.Service = append(doc.Service, messages.Service{
 			Id:              svc.Id,
 			Type:            svc.Type,
-			RecipientKeys:   []string{string(encodedKey)},
-			RoutingKeys:     nil,
+			RecipientKeys:   []string{keyIds[encKey]},
+			RoutingKeys:     routingRefs(svc.RoutingKeys),
 			ServiceEndpoint: svc.Endpoint,
-			Accept:          nil,
+			Accept:          svc.Accept,
+		})
+	}
+
+	return doc
+}
+
+// AssignDID sets the did as the id of the doc and the controller of its verification methods
+func (h *Handler) AssignDID(doc messages.DIDDocument, did string) messages.DIDDocument {
+	doc.Id = did
+	vms := make([]messages.VerificationMethod, len(doc.VerificationMethod))
+	for i, vm := range doc.VerificationMethod {
+		vm.Controller = did
+		vms[i] = vm
+	}
+	doc.VerificationMethod = vms
+	return doc
+}
+
+// ServiceKeys returns the Ed25519 recipient keys of the service which may either be
+// references to verification methods of the doc, did:key or base58/base64 encoded
+// raw keys (did docs prior to verification methods). Services without recipient keys use
+// all authentication keys of the doc.
+func (h *Handler) ServiceKeys(doc messages.DIDDocument, svc messages.Service) ([][]byte, error) {
+	return serviceKeys(doc, svc.RecipientKeys)
+}
+
+// RoutingKeys returns the Ed25519 keys of the mediators of the service which
+// are referred similar to recipient keys
+func (h *Handler) RoutingKeys(doc messages.DIDDocument, svc messages.Service) ([][]byte, error) {
+	if len(svc.RoutingKeys) == 0 {
+		return nil, nil
+	}
+	return serviceKeys(doc, svc.RoutingKeys)
+}
+
+// routingRefs refers to the routing keys as did:key identifiers since
+// they are not controlled by the subject of the doc
+func routingRefs(keys [][]byte) []string {
+	var refs []string
+	for _, k := range keys {
+		refs = append(refs, prefixKey+multibase(codecEd25519, k))
+	}
+	return refs
+}
+
+func serviceKeys(doc messages.DIDDocument, refs []string) ([][]byte, error) {
+	if len(refs) == 0 {
+		refs = doc.Authentication
+	}
+
+	var keys [][]byte
+	for _, ref := range refs {
+		key, err := keyByRef(doc, ref)
+		if err != nil {
+			return nil, err
 		}
-		msgSvcs = append(msgSvcs, s)
+		keys = append(keys, key)
 	}
 
-	return messages.DIDDocument{Service: msgSvcs}
+	return keys, nil
 }
 
+// CreatePeerDID creates a did:peer:1 which can not be resolved without
+// the stored variant of the did doc
 func (h *Handler) CreatePeerDID(doc messages.DIDDocument) (did string, err error) {
 	// make a did-doc but omit DID value from doc = stored variant
 	byts, err := json.Marshal(doc)
@@ -52,21 +125,82 @@ func (h *Handler) CreatePeerDID(doc messages.DIDDocument) (did string, err error
 		return ``, fmt.Errorf(`generating sha256 hash of did doc failed - %v`, err)
 	}
 
-	// base58 encode numeric basis
-	enc := base58.Encode(hash.Sum(nil))
+	// base58 encode numeric basis as a sha2-256 multihash
+	enc := base58.Encode(append([]byte{0x12, 0x20}, hash.Sum(nil)...))
 	// did:peer:1z<encoded-numeric-basis>
 	return `did:peer:1z` + enc, nil
 }
 
+// ValidatePeerDID checks the format of the did and that the keys
+// and services of numalgo 0 and 2 can be resolved
 func (h *Handler) ValidatePeerDID(did string) error {
-	if len(did) < 11 {
-		return fmt.Errorf(`invalid did in invitation: %s`, did)
+	if !peerDIDRegex.MatchString(did) {
+		return fmt.Errorf(`invalid peer did: %s`, did)
 	}
 
-	// should ideally use a regex
-	if did[:11] != `did:peer:1z` {
-		return fmt.Errorf(`did type is not peer: %s`, did[:11])
+	if did[len(prefixPeer)] == '1' {
+		return nil
+	}
+
+	if _, err := h.ResolvePeerDID(did); err != nil {
+		return fmt.Errorf(`resolving peer did failed - %v`, err)
 	}
 
 	return nil
 }
+
+// keyByRef resolves a recipient key of a service to the Ed25519 key
+func keyByRef(doc messages.DIDDocument, ref string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(ref, prefixKey):
+		encKey := strings.SplitN(ref[len(prefixKey):], `#`, 2)[0]
+		key, codec := decodeMultibase(encKey)
+		if codec != string(codecEd25519) || len(key) != keyBytes {
+			return nil, fmt.Errorf(`%s is not an Ed25519 key`, ref)
+		}
+		return key, nil
+	case strings.Contains(ref, `#`):
+		for _, vm := range doc.VerificationMethod {
+			if vm.Id == ref || doc.Id+vm.Id == ref || vm.Id == doc.Id+ref {
+				return vmKey(vm)
+			}
+		}
+		return nil, fmt.Errorf(`unknown key reference (%s)`, ref)
+	}
+
+	// raw keys are base58 encoded in aries did docs and base64 encoded in earlier docs of the agent
+	key, err := base64.StdEncoding.DecodeString(ref)
+	if err == nil && len(key) == keyBytes {
+		return key, nil
+	}
+
+	if key = base58.Decode(ref); len(key) != keyBytes {
+		return nil, fmt.Errorf(`invalid recipient key (%s)`, ref)
+	}
+	return key, nil
+}
+
+// vmKey decodes the public key of an Ed25519 verification method
+func vmKey(vm messages.VerificationMethod) ([]byte, error) {
+	var key []byte
+	switch vm.Type {
+	case typEd25519:
+		k, codec := decodeMultibase(vm.PublicKeyMultibase)
+		if codec == string(codecEd25519) {
+			key = k
+		}
+	case typEd25519Key2018:
+		key = base58.Decode(vm.PublicKeyBase58)
+	case typJWK2020:
+		if vm.PublicKeyJwk != nil && vm.PublicKeyJwk.Kty == jwkOKP && vm.PublicKeyJwk.Crv == jwkEd25519 {
+			key, _ = base64.RawURLEncoding.DecodeString(vm.PublicKeyJwk.X)
+		}
+	default:
+		return nil, fmt.Errorf(`verification method %s is not a verification key (%s)`, vm.Id, vm.Type)
+	}
+
+	if len(key) != keyBytes {
+		return nil, fmt.Errorf(`invalid Ed25519 key of the verification method %s`, vm.Id)
+	}
+	return key, nil
+}
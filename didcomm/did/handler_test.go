@@ -0,0 +1,142 @@
+package did
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/btcsuite/btcutil/base58"
+	"reflect"
+	"testing"
+)
+
+func TestHandler_CreateDIDDoc(t *testing.T) {
+	h := NewHandler()
+	key, otherKey, routingKey := newEd25519Key(t), newEd25519Key(t), newEd25519Key(t)
+	doc := h.CreateDIDDoc([]models.Service{
+		{Id: `msg`, Type: svcDIDCommMsg, Endpoint: `tcp://127.0.0.1:6000`, PubKey: key, RoutingKeys: [][]byte{routingKey}},
+		{Id: `join`, Type: `group-join`, Endpoint: `tcp://127.0.0.1:6001`, PubKey: key},
+		{Id: `other`, Type: svcDIDCommMsg, Endpoint: `tcp://127.0.0.1:6002`, PubKey: otherKey},
+	})
+
+	// each distinct key is followed by the derived key agreement key
+	types := []string{typEd25519Key2018, typX25519Key2019, typEd25519Key2018, typX25519Key2019}
+	if len(doc.VerificationMethod) != len(types) {
+		t.Fatalf(`expected %d verification methods but got %v`, len(types), doc.VerificationMethod)
+	}
+
+	for i, vm := range doc.VerificationMethod {
+		if id := fmt.Sprintf(`#key-%d`, i+1); vm.Id != id || vm.Type != types[i] {
+			t.Errorf(`expected %s of type %s but got %v`, id, types[i], vm)
+		}
+	}
+
+	if !reflect.DeepEqual(doc.Authentication, []string{`#key-1`, `#key-3`}) || !reflect.DeepEqual(doc.KeyAgreement, []string{`#key-2`, `#key-4`}) {
+		t.Errorf(`unexpected key purposes (authentication: %v, key agreement: %v)`, doc.Authentication, doc.KeyAgreement)
+	}
+
+	xKey, err := agreementKey(doc.VerificationMethod[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if derived, _ := edToX25519(key); !bytes.Equal(xKey, derived) {
+		t.Error(`key agreement key should be derived from the verification key`)
+	}
+
+	refs := [][]string{{`#key-1`}, {`#key-1`}, {`#key-3`}}
+	for i, s := range doc.Service {
+		if !reflect.DeepEqual(s.RecipientKeys, refs[i]) {
+			t.Errorf(`expected service %s to refer to %v but got %v`, s.Id, refs[i], s.RecipientKeys)
+		}
+	}
+
+	routingKeys, err := h.RoutingKeys(doc, doc.Service[0])
+	if err != nil || len(routingKeys) != 1 || !bytes.Equal(routingKeys[0], routingKey) {
+		t.Errorf(`routing keys should be referred to as did:key (refs: %v, err: %v)`, doc.Service[0].RoutingKeys, err)
+	}
+
+	doc = h.AssignDID(doc, `did:peer:1zTest`)
+	for _, vm := range doc.VerificationMethod {
+		if vm.Controller != `did:peer:1zTest` {
+			t.Errorf(`controller of %s is not assigned (%s)`, vm.Id, vm.Controller)
+		}
+	}
+}
+
+func TestHandler_ServiceKeys(t *testing.T) {
+	key, otherKey := newEd25519Key(t), newEd25519Key(t)
+	xKey, _ := edToX25519(key)
+	b64Key, b58Key := base64.StdEncoding.EncodeToString(key), base58.Encode(key)
+
+	// did docs of the agent prior to verification methods
+	legacyDoc := fmt.Sprintf(`{"@context":["https://w3id.org/did/v1"],"id":"did:peer:1zLegacy","service":[
+		{"id":"base64","type":"message","recipientKeys":["%s"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+		{"id":"base58","type":"message","recipientKeys":["%s"],"serviceEndpoint":"tcp://127.0.0.1:6000"}]}`, b64Key, b58Key)
+
+	w3cDoc := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/did/v1"],"id":"did:example:alice",
+		"verificationMethod":[
+			{"id":"#key-1","type":"Ed25519VerificationKey2018","controller":"did:example:alice","publicKeyBase58":"%s"},
+			{"id":"did:example:alice#key-2","type":"Ed25519VerificationKey2020","controller":"did:example:alice","publicKeyMultibase":"%s"},
+			{"id":"#key-3","type":"X25519KeyAgreementKey2019","controller":"did:example:alice","publicKeyBase58":"%s"},
+			{"id":"#key-4","type":"JsonWebKey2020","controller":"did:example:alice","publicKeyJwk":{"kty":"OKP","crv":"Ed25519","x":"%s"}}],
+		"authentication":["#key-1"],"keyAgreement":["#key-3"],"service":[
+			{"id":"relative","type":"DIDCommMessaging","recipientKeys":["#key-1"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"absolute","type":"DIDCommMessaging","recipientKeys":["did:example:alice#key-1"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"absolute-method","type":"DIDCommMessaging","recipientKeys":["#key-2"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"jwk","type":"DIDCommMessaging","recipientKeys":["#key-4"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"did-key","type":"DIDCommMessaging","recipientKeys":["%s"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"authentication","type":"DIDCommMessaging","serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"key-agreement","type":"DIDCommMessaging","recipientKeys":["#key-3"],"serviceEndpoint":"tcp://127.0.0.1:6000"},
+			{"id":"unknown","type":"DIDCommMessaging","recipientKeys":["#key-5"],"serviceEndpoint":"tcp://127.0.0.1:6000"}]}`,
+		b58Key, multibase(codecEd25519, otherKey), base58.Encode(xKey), base64.RawURLEncoding.EncodeToString(otherKey),
+		prefixKey+multibase(codecEd25519, otherKey)+`#`+multibase(codecEd25519, otherKey))
+
+	tests := []struct {
+		doc string
+		svc string
+		key []byte
+	}{
+		{legacyDoc, `base64`, key},
+		{legacyDoc, `base58`, key},
+		{w3cDoc, `relative`, key},
+		{w3cDoc, `absolute`, key},
+		{w3cDoc, `absolute-method`, otherKey},
+		{w3cDoc, `jwk`, otherKey},
+		{w3cDoc, `did-key`, otherKey},
+		{w3cDoc, `authentication`, key},
+		{w3cDoc, `key-agreement`, nil},
+		{w3cDoc, `unknown`, nil},
+	}
+
+	h := NewHandler()
+	for _, test := range tests {
+		t.Run(test.svc, func(t *testing.T) {
+			var doc messages.DIDDocument
+			if err := json.Unmarshal([]byte(test.doc), &doc); err != nil {
+				t.Fatalf(`unmarshalling did doc failed - %v`, err)
+			}
+
+			var svc messages.Service
+			for _, s := range doc.Service {
+				if s.Id == test.svc {
+					svc = s
+				}
+			}
+
+			keys, err := h.ServiceKeys(doc, svc)
+			if test.key == nil {
+				if err == nil {
+					t.Errorf(`expected an error for the recipient keys %v`, svc.RecipientKeys)
+				}
+				return
+			}
+
+			if err != nil || len(keys) != 1 || !bytes.Equal(keys[0], test.key) {
+				t.Errorf(`unexpected recipient keys for %v (err: %v)`, svc.RecipientKeys, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package did
+
+import (
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"math/big"
+	"strings"
+)
+
+/* Key DID method (Ed25519 and X25519 keys)
+   see: https://w3c-ccg.github.io/did-method-key */
+
+const prefixKey = `did:key:`
+
+// field prime of curve25519 (2^255 - 19)
+var fieldPrime, _ = new(big.Int).SetString(`7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed`, 16)
+
+// CreateKeyDID creates a did:key from an Ed25519 or X25519 public key
+func (h *Handler) CreateKeyDID(typ domain.KeyType, pubKey []byte) (string, error) {
+	if len(pubKey) != keyBytes {
+		return ``, fmt.Errorf(`invalid public key length (%d)`, len(pubKey))
+	}
+
+	switch typ {
+	case domain.KeyX25519:
+		return prefixKey + multibase(codecX25519, pubKey), nil
+	case domain.KeyEd25519:
+		return prefixKey + multibase(codecEd25519, pubKey), nil
+	}
+
+	return ``, fmt.Errorf(`unsupported key type (%s)`, typ)
+}
+
+// ResolveKeyDID constructs the did doc of a did:key. The key agreement key of
+// an Ed25519 did:key is derived from the verification key as in the spec.
+func (h *Handler) ResolveKeyDID(did string) (messages.DIDDocument, error) {
+	if !strings.HasPrefix(did, prefixKey) {
+		return messages.DIDDocument{}, fmt.Errorf(`invalid key did: %s`, did)
+	}
+
+	doc, err := inceptionDoc(did, did, did[len(prefixKey):])
+	if err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`invalid key did: %s - %v`, did, err)
+	}
+
+	return doc, nil
+}
+
+// inceptionDoc constructs the did doc of a single key where the ids of the
+// verification methods are the encoded keys prefixed by idPrefix
+func inceptionDoc(did, idPrefix, encKey string) (messages.DIDDocument, error) {
+	vm, err := verificationMethod(did, idPrefix+`#`+encKey, encKey)
+	if err != nil {
+		return messages.DIDDocument{}, err
+	}
+
+	doc := messages.DIDDocument{Context: didContexts, Id: did, VerificationMethod: []messages.VerificationMethod{vm}}
+	if vm.Type == typX25519 {
+		doc.KeyAgreement = []string{vm.Id}
+		return doc, nil
+	}
+
+	edKey, _ := decodeMultibase(encKey)
+	xKey, err := edToX25519(edKey)
+	if err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`deriving key agreement key failed - %v`, err)
+	}
+
+	encXKey := multibase(codecX25519, xKey)
+	doc.Authentication = []string{vm.Id}
+	doc.VerificationMethod = append(doc.VerificationMethod, messages.VerificationMethod{
+		Id:                 idPrefix + `#` + encXKey,
+		Type:               typX25519,
+		Controller:         did,
+		PublicKeyMultibase: encXKey,
+	})
+	doc.KeyAgreement = []string{idPrefix + `#` + encXKey}
+
+	return doc, nil
+}
+
+// VerificationKey returns the first Ed25519 authentication key of the did doc
+func (h *Handler) VerificationKey(doc messages.DIDDocument) ([]byte, error) {
+	for _, id := range doc.Authentication {
+		for _, vm := range doc.VerificationMethod {
+			if vm.Id != id {
+				continue
+			}
+
+			if key, err := vmKey(vm); err == nil {
+				return key, nil
+			}
+		}
+	}
+
+	return nil, errors.New(`did doc does not contain an Ed25519 authentication key`)
+}
+
+// edToX25519 maps an Ed25519 public key to the corresponding X25519 public
+// key using the birational map u = (1 + y) / (1 - y)
+func edToX25519(edKey []byte) ([]byte, error) {
+	// little-endian y coordinate without the sign bit of x
+	yBytes := make([]byte, keyBytes)
+	for i := range edKey {
+		yBytes[keyBytes-1-i] = edKey[i]
+	}
+	yBytes[0] &= 0x7f
+
+	y := new(big.Int).SetBytes(yBytes)
+	if y.Cmp(fieldPrime) >= 0 {
+		return nil, errors.New(`y coordinate is not in the field`)
+	}
+
+	den := new(big.Int).Sub(big.NewInt(1), y)
+	den.Mod(den, fieldPrime)
+	if den.Sign() == 0 {
+		return nil, errors.New(`key maps to the point at infinity`)
+	}
+
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, den.ModInverse(den, fieldPrime))
+	u.Mod(u, fieldPrime)
+
+	uBytes := u.FillBytes(make([]byte, keyBytes))
+	xKey := make([]byte, keyBytes)
+	for i := range uBytes {
+		xKey[keyBytes-1-i] = uBytes[i]
+	}
+
+	return xKey, nil
+}
@@ -0,0 +1,77 @@
+package did
+
+import (
+	"github.com/YasiruR/didcomm-prober/domain"
+	"reflect"
+	"testing"
+)
+
+// test vectors of the did:key spec (https://w3c-ccg.github.io/did-method-key)
+func TestHandler_ResolveKeyDID(t *testing.T) {
+	tests := []struct {
+		did      string
+		typ      domain.KeyType
+		keyAgrmt string
+	}{
+		{`did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`, domain.KeyEd25519, `z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p`},
+		{`did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp`, domain.KeyEd25519, `z6LShs9GGnqk85isEBzzshkuVWrVKsRp24GnDuHk8QWkARMW`},
+		{`did:key:z6LSeu9HkTHSfLLeUs2nnzUSNedgDUevfNQgQjQC23ZCit6F`, domain.KeyX25519, `z6LSeu9HkTHSfLLeUs2nnzUSNedgDUevfNQgQjQC23ZCit6F`},
+	}
+
+	h := NewHandler()
+	for _, test := range tests {
+		t.Run(test.did, func(t *testing.T) {
+			doc, err := h.ResolveKeyDID(test.did)
+			if err != nil {
+				t.Fatalf(`resolving key did failed - %v`, err)
+			}
+
+			encKey := test.did[len(prefixKey):]
+			keyAgrmtId := test.did + `#` + test.keyAgrmt
+			if !reflect.DeepEqual(doc.KeyAgreement, []string{keyAgrmtId}) {
+				t.Errorf(`expected key agreement %s but got %v`, keyAgrmtId, doc.KeyAgreement)
+			}
+
+			vm := doc.VerificationMethod[len(doc.VerificationMethod)-1]
+			if vm.Id != keyAgrmtId || vm.Type != typX25519 || vm.PublicKeyMultibase != test.keyAgrmt {
+				t.Errorf(`unexpected key agreement method (%v)`, vm)
+			}
+
+			var key []byte
+			switch test.typ {
+			case domain.KeyEd25519:
+				authId := test.did + `#` + encKey
+				if !reflect.DeepEqual(doc.Authentication, []string{authId}) || len(doc.VerificationMethod) != 2 {
+					t.Fatalf(`expected the verification key %s followed by the key agreement key but got %v`, authId, doc.VerificationMethod)
+				}
+
+				if key, err = h.VerificationKey(doc); err != nil {
+					t.Fatal(err)
+				}
+			case domain.KeyX25519:
+				if len(doc.Authentication) != 0 || len(doc.VerificationMethod) != 1 {
+					t.Fatalf(`X25519 key should only be used for key agreement (%v)`, doc.VerificationMethod)
+				}
+				key, _ = decodeMultibase(encKey)
+			}
+
+			did, err := h.CreateKeyDID(test.typ, key)
+			if err != nil || did != test.did {
+				t.Errorf(`expected %s from the key but got %s (err: %v)`, test.did, did, err)
+			}
+		})
+	}
+}
+
+func TestHandler_ResolveKeyDID_Invalid(t *testing.T) {
+	for _, did := range []string{
+		`did:peer:0z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`,
+		`did:key:6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`,
+		`did:key:zQ3shokFTS3brHcDQrn82RUDfCZESWL1ZdCEJwekUDPQiYBme`,
+		`did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2d`,
+	} {
+		if _, err := NewHandler().ResolveKeyDID(did); err == nil {
+			t.Errorf(`expected an error when resolving %s`, did)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package did
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/btcsuite/btcutil/base58"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/* Peer DID method (numalgo 0 and 2)
+   see: https://identity.foundation/peer-did-method-spec */
+
+const (
+	prefixPeer      = `did:peer:`
+	multibaseBase58 = 'z'
+	keyBytes        = 32
+	purposeKeyAgrmt = 'E'
+	purposeAuthn    = 'V'
+	purposeService  = 'S'
+	typX25519       = `X25519KeyAgreementKey2020`
+	typEd25519      = `Ed25519VerificationKey2020`
+	svcDIDCommMsg   = `DIDCommMessaging`
+)
+
+// verification method types of keys in did docs created by the agent and
+// resolved from other did methods (JsonWebKey2020 with Ed25519 OKP keys)
+const (
+	typEd25519Key2018 = `Ed25519VerificationKey2018`
+	typX25519Key2019  = `X25519KeyAgreementKey2019`
+	typJWK2020        = `JsonWebKey2020`
+	ctxEd25519        = `https://w3id.org/security/suites/ed25519-2018/v1`
+	ctxX25519         = `https://w3id.org/security/suites/x25519-2019/v1`
+	jwkOKP            = `OKP`
+	jwkEd25519        = `Ed25519`
+	jwkX25519         = `X25519`
+)
+
+// multicodec prefixes (varint encoded) of public keys
+var (
+	codecX25519  = []byte{0xec, 0x01}
+	codecEd25519 = []byte{0xed, 0x01}
+)
+
+var didContexts = []string{`https://www.w3.org/ns/did/v1`}
+
+var peerDIDRegex = regexp.MustCompile(`^did:peer:(([01](z)([1-9a-km-zA-HJ-NP-Z]{46,47}))|(2((\.[AEVID](z)([1-9a-km-zA-HJ-NP-Z]{46,47}))+(\.(S)[0-9a-zA-Z_=-]*)*)))$`)
+
+// abbreviated service as encoded in numalgo 2 where the endpoint is either
+// a uri or an abbreviated endpoint object (DIDComm v2.1)
+type abbrService struct {
+	Id          string          `json:"id,omitempty"`
+	Type        string          `json:"t"`
+	Endpoint    json.RawMessage `json:"s"`
+	RoutingKeys []string        `json:"r,omitempty"`
+	Accept      []string        `json:"a,omitempty"`
+}
+
+type abbrEndpoint struct {
+	URI         string   `json:"uri"`
+	RoutingKeys []string `json:"r,omitempty"`
+	Accept      []string `json:"a,omitempty"`
+}
+
+// CreatePeerDID0 creates a did:peer:0 with the Ed25519 inception key
+func (h *Handler) CreatePeerDID0(pubKey []byte) (string, error) {
+	if len(pubKey) != keyBytes {
+		return ``, fmt.Errorf(`invalid public key length (%d)`, len(pubKey))
+	}
+	return prefixPeer + `0` + multibase(codecEd25519, pubKey), nil
+}
+
+// CreatePeerDID2 encodes the authentication and key agreement keys of the doc in
+// the order of verification methods, and the abbreviated services into a
+// did:peer:2. Since numalgo 2 services do not refer to keys, recipient keys of
+// the services are resolved as the authentication keys and hence services
+// must use all of them.
+func (h *Handler) CreatePeerDID2(doc messages.DIDDocument) (string, error) {
+	authKeys := map[string]bool{} // key: base64 encoded authentication key
+	var encKeys, encSvcs strings.Builder
+	for _, vm := range doc.VerificationMethod {
+		if key, err := vmKey(vm); err == nil {
+			authKeys[base64.StdEncoding.EncodeToString(key)] = true
+			encKeys.WriteString(`.` + string(purposeAuthn) + multibase(codecEd25519, key))
+			continue
+		}
+
+		key, err := agreementKey(vm)
+		if err != nil {
+			return ``, err
+		}
+		encKeys.WriteString(`.` + string(purposeKeyAgrmt) + multibase(codecX25519, key))
+	}
+
+	if len(authKeys) == 0 {
+		return ``, errors.New(`did doc does not contain any verification key`)
+	}
+
+	for _, s := range doc.Service {
+		svcKeys, err := h.ServiceKeys(doc, s)
+		if err != nil {
+			return ``, fmt.Errorf(`invalid recipient key of the service %s - %v`, s.Id, err)
+		}
+
+		svcKeyMap := map[string]bool{}
+		for _, key := range svcKeys {
+			encKey := base64.StdEncoding.EncodeToString(key)
+			if !authKeys[encKey] {
+				return ``, fmt.Errorf(`recipient key of the service %s is not a verification method of the doc`, s.Id)
+			}
+			svcKeyMap[encKey] = true
+		}
+
+		if len(svcKeyMap) != len(authKeys) {
+			return ``, fmt.Errorf(`service %s does not use all verification keys of the doc`, s.Id)
+		}
+
+		endpoint, err := json.Marshal(s.ServiceEndpoint)
+		if err != nil {
+			return ``, fmt.Errorf(`marshalling service endpoint failed - %v`, err)
+		}
+
+		byts, err := json.Marshal(abbrService{Id: s.Id, Type: abbrType(s.Type), Endpoint: endpoint, RoutingKeys: s.RoutingKeys, Accept: s.Accept})
+		if err != nil {
+			return ``, fmt.Errorf(`marshalling service failed - %v`, err)
+		}
+		encSvcs.WriteString(`.` + string(purposeService) + base64.RawURLEncoding.EncodeToString(byts))
+	}
+
+	return prefixPeer + `2` + encKeys.String() + encSvcs.String(), nil
+}
+
+// ResolvePeerDID constructs the did doc of a did:peer:0 or did:peer:2. Recipient
+// keys of the services are resolved to references of the authentication keys.
+func (h *Handler) ResolvePeerDID(did string) (messages.DIDDocument, error) {
+	if !peerDIDRegex.MatchString(did) {
+		return messages.DIDDocument{}, fmt.Errorf(`invalid peer did: %s`, did)
+	}
+
+	switch did[len(prefixPeer)] {
+	case '0':
+		return h.resolveNumalgo0(did)
+	case '2':
+		return h.resolveNumalgo2(did)
+	}
+
+	return messages.DIDDocument{}, fmt.Errorf(`peer did with numalgo %c can not be resolved`, did[len(prefixPeer)])
+}
+
+// resolveNumalgo0 resolves the inception key similar to a did:key
+func (h *Handler) resolveNumalgo0(did string) (messages.DIDDocument, error) {
+	return inceptionDoc(did, ``, did[len(prefixPeer)+1:])
+}
+
+func (h *Handler) resolveNumalgo2(did string) (messages.DIDDocument, error) {
+	doc := messages.DIDDocument{Context: didContexts, Id: did}
+	for _, elem := range strings.Split(did[len(prefixPeer)+2:], `.`) {
+		purpose, val := elem[0], elem[1:]
+		switch purpose {
+		case purposeKeyAgrmt, purposeAuthn:
+			id := `#key-` + strconv.Itoa(len(doc.VerificationMethod)+1)
+			vm, err := verificationMethod(did, id, val)
+			if err != nil {
+				return messages.DIDDocument{}, err
+			}
+
+			if (purpose == purposeKeyAgrmt) != (vm.Type == typX25519) {
+				return messages.DIDDocument{}, fmt.Errorf(`key type %s does not match the purpose %c`, vm.Type, purpose)
+			}
+
+			doc.VerificationMethod = append(doc.VerificationMethod, vm)
+			if purpose == purposeKeyAgrmt {
+				doc.KeyAgreement = append(doc.KeyAgreement, id)
+				continue
+			}
+
+			doc.Authentication = append(doc.Authentication, id)
+		case purposeService:
+			svc, err := resolveService(val, doc.Authentication, len(doc.Service))
+			if err != nil {
+				return messages.DIDDocument{}, err
+			}
+			doc.Service = append(doc.Service, svc)
+		}
+	}
+
+	return doc, nil
+}
+
+// resolveService expands the abbreviated service where the recipient keys
+// are the authentication keys of the did
+func resolveService(val string, keyIds []string, index int) (messages.Service, error) {
+	byts, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(val, `=`))
+	if err != nil {
+		return messages.Service{}, fmt.Errorf(`decoding service failed - %v`, err)
+	}
+
+	var as abbrService
+	if err = json.Unmarshal(byts, &as); err != nil {
+		return messages.Service{}, fmt.Errorf(`unmarshalling service failed - %v`, err)
+	}
+
+	svc := messages.Service{Id: as.Id, Type: as.Type, RoutingKeys: as.RoutingKeys, Accept: as.Accept}
+	if svc.Type == `dm` {
+		svc.Type = svcDIDCommMsg
+	}
+
+	if err = json.Unmarshal(as.Endpoint, &svc.ServiceEndpoint); err != nil {
+		var ae abbrEndpoint
+		if err = json.Unmarshal(as.Endpoint, &ae); err != nil || ae.URI == `` {
+			return messages.Service{}, fmt.Errorf(`invalid service endpoint (%s)`, as.Endpoint)
+		}
+		svc.ServiceEndpoint, svc.RoutingKeys, svc.Accept = ae.URI, ae.RoutingKeys, ae.Accept
+	}
+
+	// ids are assigned in the order of services if not provided
+	if svc.Id == `` {
+		svc.Id = `#service`
+		if index > 0 {
+			svc.Id += `-` + strconv.Itoa(index)
+		}
+	}
+
+	svc.RecipientKeys = append([]string{}, keyIds...)
+	return svc, nil
+}
+
+func verificationMethod(did, id, encKey string) (messages.VerificationMethod, error) {
+	key, codec := decodeMultibase(encKey)
+	vm := messages.VerificationMethod{Id: id, Controller: did, PublicKeyMultibase: encKey}
+	switch {
+	case codec == string(codecX25519):
+		vm.Type = typX25519
+	case codec == string(codecEd25519):
+		vm.Type = typEd25519
+	default:
+		return messages.VerificationMethod{}, fmt.Errorf(`unsupported key type of %s`, encKey)
+	}
+
+	if len(key) != keyBytes {
+		return messages.VerificationMethod{}, fmt.Errorf(`invalid key length (%d)`, len(key))
+	}
+
+	return vm, nil
+}
+
+// agreementKey decodes the public key of an X25519 verification method
+func agreementKey(vm messages.VerificationMethod) ([]byte, error) {
+	var key []byte
+	switch vm.Type {
+	case typX25519:
+		k, codec := decodeMultibase(vm.PublicKeyMultibase)
+		if codec == string(codecX25519) {
+			key = k
+		}
+	case typX25519Key2019:
+		key = base58.Decode(vm.PublicKeyBase58)
+	case typJWK2020:
+		if vm.PublicKeyJwk != nil && vm.PublicKeyJwk.Kty == jwkOKP && vm.PublicKeyJwk.Crv == jwkX25519 {
+			key, _ = base64.RawURLEncoding.DecodeString(vm.PublicKeyJwk.X)
+		}
+	default:
+		return nil, fmt.Errorf(`verification method %s is not a key agreement key (%s)`, vm.Id, vm.Type)
+	}
+
+	if len(key) != keyBytes {
+		return nil, fmt.Errorf(`invalid X25519 key of the verification method %s`, vm.Id)
+	}
+	return key, nil
+}
+
+func abbrType(typ string) string {
+	if typ == svcDIDCommMsg {
+		return `dm`
+	}
+	return typ
+}
+
+func multibase(codec, key []byte) string {
+	return string(multibaseBase58) + base58.Encode(append(append([]byte{}, codec...), key...))
+}
+
+// decodeMultibase returns the key and its multicodec prefix
+func decodeMultibase(val string) (key []byte, codec string) {
+	if len(val) == 0 || val[0] != multibaseBase58 {
+		return nil, ``
+	}
+
+	byts := base58.Decode(val[1:])
+	if len(byts) < 2 {
+		return nil, ``
+	}
+
+	return byts[2:], string(byts[:2])
+}
@@ -0,0 +1,172 @@
+package did
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/btcsuite/btcutil/base58"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// examples of the peer did method spec
+// (https://identity.foundation/peer-did-method-spec)
+const (
+	specPeerDID0 = `did:peer:0z6MkpTHR8VNsBxYAAWHut2Geadd9jSwuBV8xRoAnwWsdvktH`
+	specPeerDID2 = `did:peer:2.Ez6LSbysY2xFMRpGMhb7tFTLMpeuPRaqaWM1yECx2AtzE3KCc` +
+		`.Vz6MkqRYqQiSgvZQdnBytw86Qbs2ZWUkGv22od935YF4s8M7V.Vz6MkgoLTnTypo3tDRwCkZXSccTPHRLhF4ZnjhueYAFpEX6vg` +
+		`.SeyJ0IjoiZG0iLCJzIjoiaHR0cHM6Ly9leGFtcGxlLmNvbS9lbmRwb2ludCIsInIiOlsiZGlkOmV4YW1wbGU6c29tZW1lZGlhdG9yI3NvbWVrZXkiXSwiYSI6WyJkaWRjb21tL3YyIiwiZGlkY29tbS9haXAyO2Vudj1yZmM1ODciXX0`
+)
+
+func TestHandler_ResolvePeerDID0(t *testing.T) {
+	h := NewHandler()
+	doc, err := h.ResolvePeerDID(specPeerDID0)
+	if err != nil {
+		t.Fatalf(`resolving peer did failed - %v`, err)
+	}
+
+	key, err := h.VerificationKey(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if enc := base58.Encode(key); enc != `B12NYF8RrR3h41TDCTJojY59usg3mbtbjnFs7Eud1Y6u` {
+		t.Errorf(`unexpected inception key (%s)`, enc)
+	}
+
+	did, err := h.CreatePeerDID0(key)
+	if err != nil || did != specPeerDID0 {
+		t.Errorf(`expected %s from the inception key but got %s (err: %v)`, specPeerDID0, did, err)
+	}
+}
+
+func TestHandler_ResolvePeerDID2(t *testing.T) {
+	doc, err := NewHandler().ResolvePeerDID(specPeerDID2)
+	if err != nil {
+		t.Fatalf(`resolving peer did failed - %v`, err)
+	}
+
+	vms := []struct {
+		typ, key string
+	}{
+		{typX25519, `z6LSbysY2xFMRpGMhb7tFTLMpeuPRaqaWM1yECx2AtzE3KCc`},
+		{typEd25519, `z6MkqRYqQiSgvZQdnBytw86Qbs2ZWUkGv22od935YF4s8M7V`},
+		{typEd25519, `z6MkgoLTnTypo3tDRwCkZXSccTPHRLhF4ZnjhueYAFpEX6vg`},
+	}
+
+	if len(doc.VerificationMethod) != len(vms) {
+		t.Fatalf(`expected %d verification methods but got %d`, len(vms), len(doc.VerificationMethod))
+	}
+
+	for i, vm := range vms {
+		if doc.VerificationMethod[i].Type != vm.typ || doc.VerificationMethod[i].PublicKeyMultibase != vm.key {
+			t.Errorf(`unexpected verification method %d (%v)`, i, doc.VerificationMethod[i])
+		}
+	}
+
+	if !reflect.DeepEqual(doc.KeyAgreement, []string{`#key-1`}) || !reflect.DeepEqual(doc.Authentication, []string{`#key-2`, `#key-3`}) {
+		t.Errorf(`unexpected key purposes (key agreement: %v, authentication: %v)`, doc.KeyAgreement, doc.Authentication)
+	}
+
+	expected := messages.Service{
+		Id:              `#service`,
+		Type:            svcDIDCommMsg,
+		RecipientKeys:   []string{`#key-2`, `#key-3`},
+		RoutingKeys:     []string{`did:example:somemediator#somekey`},
+		ServiceEndpoint: `https://example.com/endpoint`,
+		Accept:          []string{`didcomm/v2`, `didcomm/aip2;env=rfc587`},
+	}
+
+	if len(doc.Service) != 1 || !reflect.DeepEqual(doc.Service[0], expected) {
+		t.Errorf(`expected service %v but got %v`, expected, doc.Service)
+	}
+}
+
+func TestHandler_ResolvePeerDID2_EndpointObject(t *testing.T) {
+	svc := `{"t":"dm","s":{"uri":"https://example.com/didcomm","r":["did:example:mediator#key-1"],"a":["didcomm/v2"]}}`
+	did := strings.Split(specPeerDID2, `.S`)[0] + `.S` + base64.RawURLEncoding.EncodeToString([]byte(svc))
+
+	doc, err := NewHandler().ResolvePeerDID(did)
+	if err != nil {
+		t.Fatalf(`resolving peer did failed - %v`, err)
+	}
+
+	s := doc.Service[0]
+	if s.ServiceEndpoint != `https://example.com/didcomm` || !reflect.DeepEqual(s.RoutingKeys, []string{`did:example:mediator#key-1`}) ||
+		!reflect.DeepEqual(s.Accept, []string{`didcomm/v2`}) {
+		t.Errorf(`unexpected service expanded from the endpoint object (%v)`, s)
+	}
+}
+
+func TestHandler_CreatePeerDID2(t *testing.T) {
+	h, key := NewHandler(), newEd25519Key(t)
+	doc := h.CreateDIDDoc([]models.Service{
+		{Id: `msg`, Type: svcDIDCommMsg, Endpoint: `tcp://127.0.0.1:6000`, PubKey: key, Accept: []string{`didcomm/aip1`}},
+		{Id: `join`, Type: `group-join`, Endpoint: `tcp://127.0.0.1:6001`, PubKey: key},
+	})
+
+	did, err := h.CreatePeerDID2(doc)
+	if err != nil {
+		t.Fatalf(`creating peer did failed - %v`, err)
+	}
+
+	elems := strings.Split(did, `.`)
+	if len(elems) != 5 || elems[1][0] != purposeAuthn || elems[2][0] != purposeKeyAgrmt {
+		t.Fatalf(`expected the verification and key agreement keys followed by services but got %s`, did)
+	}
+
+	svcByts, err := base64.RawURLEncoding.DecodeString(elems[3][1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var abbr map[string]interface{}
+	if err = json.Unmarshal(svcByts, &abbr); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{`t`, `s`, `a`} {
+		if _, ok := abbr[field]; !ok {
+			t.Errorf(`abbreviated service does not contain %s (%s)`, field, svcByts)
+		}
+	}
+
+	if abbr[`t`] != `dm` {
+		t.Errorf(`expected DIDCommMessaging to be abbreviated but got %v`, abbr[`t`])
+	}
+
+	if _, ok := abbr[`recipientKeys`]; ok {
+		t.Errorf(`abbreviated service should not contain recipient keys (%s)`, svcByts)
+	}
+
+	resolved, err := h.ResolvePeerDID(did)
+	if err != nil {
+		t.Fatalf(`resolving created peer did failed - %v`, err)
+	}
+
+	for i, s := range resolved.Service {
+		if s.Id != doc.Service[i].Id || s.Type != doc.Service[i].Type || s.ServiceEndpoint != doc.Service[i].ServiceEndpoint {
+			t.Errorf(`expected service %v but got %v`, doc.Service[i], s)
+		}
+
+		keys, err := h.ServiceKeys(resolved, s)
+		if err != nil || len(keys) != 1 || !bytes.Equal(keys[0], key) {
+			t.Errorf(`recipient keys of the service %s do not match (err: %v)`, s.Id, err)
+		}
+	}
+}
+
+func TestHandler_CreatePeerDID2_PartialKeys(t *testing.T) {
+	h := NewHandler()
+	doc := h.CreateDIDDoc([]models.Service{
+		{Id: `msg`, Type: svcDIDCommMsg, Endpoint: `tcp://127.0.0.1:6000`, PubKey: newEd25519Key(t)},
+		{Id: `join`, Type: `group-join`, Endpoint: `tcp://127.0.0.1:6001`, PubKey: newEd25519Key(t)},
+	})
+
+	if did, err := h.CreatePeerDID2(doc); err == nil {
+		t.Errorf(`services with distinct keys can not be encoded but got %s`, did)
+	}
+}
@@ -0,0 +1,57 @@
+package did
+
+import (
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResolverFunc allows a function to be registered as a DIDResolver
+type ResolverFunc func(did string) (messages.DIDDocument, error)
+
+func (f ResolverFunc) Resolve(did string) (messages.DIDDocument, error) {
+	return f(did)
+}
+
+// Registry resolves dids with the resolver registered for the method of the did
+type Registry struct {
+	resolvers map[string]services.DIDResolver
+	lock      *sync.RWMutex
+}
+
+// NewRegistry creates a registry with the resolvers of did:peer, did:key and did:web
+func NewRegistry(h *Handler) *Registry {
+	r := &Registry{resolvers: map[string]services.DIDResolver{}, lock: &sync.RWMutex{}}
+	r.Register(`peer`, ResolverFunc(h.ResolvePeerDID))
+	r.Register(`key`, ResolverFunc(h.ResolveKeyDID))
+	r.Register(`web`, NewWebResolver(&http.Client{Timeout: domain.ResolveTimeoutMs * time.Millisecond}))
+	return r
+}
+
+// Register adds a resolver for the method or replaces the existing one
+func (r *Registry) Register(method string, res services.DIDResolver) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.resolvers[method] = res
+}
+
+func (r *Registry) Resolve(did string) (messages.DIDDocument, error) {
+	parts := strings.SplitN(did, `:`, 3)
+	if len(parts) != 3 || parts[0] != `did` || parts[2] == `` {
+		return messages.DIDDocument{}, fmt.Errorf(`invalid did: %s`, did)
+	}
+
+	r.lock.RLock()
+	res, ok := r.resolvers[parts[1]]
+	r.lock.RUnlock()
+	if !ok {
+		return messages.DIDDocument{}, fmt.Errorf(`no resolver registered for the did method %s`, parts[1])
+	}
+
+	return res.Resolve(did)
+}
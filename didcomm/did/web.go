@@ -0,0 +1,111 @@
+package did
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/* Web DID method
+   see: https://w3c-ccg.github.io/did-method-web */
+
+const (
+	prefixWeb   = `did:web:`
+	wellKnown   = `/.well-known`
+	docFileName = `/did.json`
+	maxDocBytes = 1 << 20
+)
+
+// WebResolver fetches did docs of did:web over https
+type WebResolver struct {
+	client *http.Client
+}
+
+func NewWebResolver(client *http.Client) *WebResolver {
+	return &WebResolver{client: client}
+}
+
+// Resolve fetches the did doc and replaces the recipient keys of services which
+// refer to verification methods or did:key with base64 encoded Ed25519 keys
+func (w *WebResolver) Resolve(did string) (messages.DIDDocument, error) {
+	docUrl, err := webURL(did)
+	if err != nil {
+		return messages.DIDDocument{}, err
+	}
+
+	res, err := w.client.Get(docUrl)
+	if err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`fetching did doc failed - %v`, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return messages.DIDDocument{}, fmt.Errorf(`fetching did doc failed with status %d`, res.StatusCode)
+	}
+
+	byts, err := io.ReadAll(io.LimitReader(res.Body, maxDocBytes))
+	if err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`reading did doc failed - %v`, err)
+	}
+
+	var doc messages.DIDDocument
+	if err = json.Unmarshal(byts, &doc); err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`unmarshalling did doc failed - %v`, err)
+	}
+
+	if doc.Id != did {
+		return messages.DIDDocument{}, fmt.Errorf(`id of the did doc (%s) does not match the did`, doc.Id)
+	}
+
+	for i, s := range doc.Service {
+		keys, err := recipientKeys(doc, s.RecipientKeys)
+		if err != nil {
+			return messages.DIDDocument{}, fmt.Errorf(`invalid recipient key of the service %s - %v`, s.Id, err)
+		}
+		doc.Service[i].RecipientKeys = keys
+	}
+
+	return doc, nil
+}
+
+// webURL transforms the did to the https url of the did doc
+func webURL(did string) (string, error) {
+	if !strings.HasPrefix(did, prefixWeb) {
+		return ``, fmt.Errorf(`invalid web did: %s`, did)
+	}
+
+	var path []string
+	for _, p := range strings.Split(did[len(prefixWeb):], `:`) {
+		seg, err := url.PathUnescape(p)
+		if err != nil || seg == `` || strings.Contains(seg, `/`) {
+			return ``, fmt.Errorf(`invalid web did: %s`, did)
+		}
+		path = append(path, seg)
+	}
+
+	if len(path) == 1 {
+		return `https://` + path[0] + wellKnown + docFileName, nil
+	}
+
+	return `https://` + strings.Join(path, `/`) + docFileName, nil
+}
+
+// recipientKeys resolves the recipient keys to base64 encoded Ed25519 keys
+func recipientKeys(doc messages.DIDDocument, refs []string) ([]string, error) {
+	keys, err := serviceKeys(doc, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	var encKeys []string
+	for _, key := range keys {
+		encKeys = append(encKeys, base64.StdEncoding.EncodeToString(key))
+	}
+
+	return encKeys, nil
+}
@@ -0,0 +1,251 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// webHost serves did docs by path as a stand-in for a did:web host
+type webHost struct {
+	srv  *httptest.Server
+	docs map[string]messages.DIDDocument
+}
+
+func newWebHost(t *testing.T) *webHost {
+	h := &webHost{docs: map[string]messages.DIDDocument{}}
+	h.srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := h.docs[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			t.Errorf(`encoding did doc failed - %v`, err)
+		}
+	}))
+	t.Cleanup(h.srv.Close)
+	return h
+}
+
+// did returns the did:web of the host with the port percent-encoded
+func (h *webHost) did(path ...string) string {
+	host := strings.TrimPrefix(h.srv.URL, `https://`)
+	return strings.Join(append([]string{`did:web:` + strings.Replace(host, `:`, `%3A`, 1)}, path...), `:`)
+}
+
+func newEd25519Key(t *testing.T) []byte {
+	key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf(`generating key failed - %v`, err)
+	}
+	return key
+}
+
+func webDoc(did string, key []byte, rks []string) messages.DIDDocument {
+	return messages.DIDDocument{
+		Context: didContexts,
+		Id:      did,
+		VerificationMethod: []messages.VerificationMethod{
+			{Id: did + `#key-1`, Type: typEd25519, Controller: did, PublicKeyMultibase: multibase(codecEd25519, key)},
+		},
+		Authentication: []string{did + `#key-1`},
+		Service: []messages.Service{
+			{Id: `#service`, Type: svcDIDCommMsg, RecipientKeys: rks, ServiceEndpoint: `tcp://127.0.0.1:6000`},
+		},
+	}
+}
+
+func TestWebResolver_Resolve(t *testing.T) {
+	host := newWebHost(t)
+	key := newEd25519Key(t)
+	encKey := base64.StdEncoding.EncodeToString(key)
+
+	tests := []struct {
+		name string
+		path []string
+		url  string
+		rks  func(did string) []string
+	}{
+		{name: `well-known with relative key reference`, url: `/.well-known/did.json`, rks: func(string) []string { return []string{`#key-1`} }},
+		{name: `path with absolute key reference`, path: []string{`user`, `alice`}, url: `/user/alice/did.json`, rks: func(did string) []string { return []string{did + `#key-1`} }},
+		{name: `did:key reference`, path: []string{`bob`}, url: `/bob/did.json`, rks: func(string) []string { return []string{prefixKey + multibase(codecEd25519, key)} }},
+		{name: `authentication keys by default`, path: []string{`carol`}, url: `/carol/did.json`, rks: func(string) []string { return nil }},
+		{name: `base64 encoded key`, path: []string{`dave`}, url: `/dave/did.json`, rks: func(string) []string { return []string{encKey} }},
+	}
+
+	res := NewWebResolver(host.srv.Client())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			did := host.did(test.path...)
+			host.docs[test.url] = webDoc(did, key, test.rks(did))
+
+			doc, err := res.Resolve(did)
+			if err != nil {
+				t.Fatalf(`resolving %s failed - %v`, did, err)
+			}
+
+			if doc.Id != did || len(doc.Service) != 1 {
+				t.Fatalf(`unexpected did doc for %s - %v`, did, doc)
+			}
+
+			rks := doc.Service[0].RecipientKeys
+			if len(rks) != 1 || rks[0] != encKey {
+				t.Fatalf(`expected recipient key %s but got %v`, encKey, rks)
+			}
+		})
+	}
+}
+
+func TestWebResolver_ResolveInvalid(t *testing.T) {
+	host := newWebHost(t)
+	key := newEd25519Key(t)
+	host.docs[`/other/did.json`] = webDoc(host.did(`someone-else`), key, nil)
+	host.docs[`/unknown-ref/did.json`] = webDoc(host.did(`unknown-ref`), key, []string{`#key-2`})
+	host.docs[`/x-key/did.json`] = webDoc(host.did(`x-key`), key, []string{prefixKey + multibase(codecX25519, key)})
+
+	tests := []struct {
+		name string
+		did  string
+	}{
+		{name: `not found`, did: host.did(`missing`)},
+		{name: `mismatched id`, did: host.did(`other`)},
+		{name: `unknown key reference`, did: host.did(`unknown-ref`)},
+		{name: `non verification key`, did: host.did(`x-key`)},
+		{name: `not a web did`, did: `did:key:` + multibase(codecX25519, key)},
+		{name: `empty path segment`, did: host.did(``, `alice`)},
+	}
+
+	res := NewWebResolver(host.srv.Client())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := res.Resolve(test.did); err == nil {
+				t.Fatalf(`expected an error when resolving %s`, test.did)
+			}
+		})
+	}
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	host := newWebHost(t)
+	key := newEd25519Key(t)
+	webDid := host.did()
+	host.docs[`/.well-known/did.json`] = webDoc(webDid, key, nil)
+
+	h := NewHandler()
+	reg := NewRegistry(h)
+	reg.Register(`web`, NewWebResolver(host.srv.Client()))
+
+	keyDid, err := h.CreateKeyDID(domain.KeyEd25519, key)
+	if err != nil {
+		t.Fatalf(`creating key did failed - %v`, err)
+	}
+
+	peerDid, err := h.CreatePeerDID0(key)
+	if err != nil {
+		t.Fatalf(`creating peer did failed - %v`, err)
+	}
+
+	for _, did := range []string{webDid, keyDid, peerDid} {
+		doc, err := reg.Resolve(did)
+		if err != nil {
+			t.Fatalf(`resolving %s failed - %v`, did, err)
+		}
+
+		if doc.Id != did {
+			t.Fatalf(`expected did doc of %s but got %s`, did, doc.Id)
+		}
+	}
+
+	for _, did := range []string{`did:example:123`, `did:web`, `web:example.com`} {
+		if _, err = reg.Resolve(did); err == nil {
+			t.Fatalf(`expected an error when resolving %s`, did)
+		}
+	}
+}
+
+func TestRegistry_Dispatch(t *testing.T) {
+	var resolved string
+	stub := func(method string) ResolverFunc {
+		return func(did string) (messages.DIDDocument, error) {
+			resolved = method
+			return messages.DIDDocument{Id: did}, nil
+		}
+	}
+
+	reg := NewRegistry(NewHandler())
+	for _, method := range []string{`peer`, `key`, `web`, `example`} {
+		reg.Register(method, stub(method))
+	}
+
+	tests := []struct {
+		did    string
+		method string
+	}{
+		{`did:peer:2.Vz6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`, `peer`},
+		{`did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`, `key`},
+		{`did:web:example.com%3A8080:user`, `web`},
+		{`did:example:123:456`, `example`},
+		{`did:ion:EiClkZMDxPKqC9c-umQfTkR8vvZ9JPhl_xLDI9Nfk38w5w`, ``},
+		{`did:peer`, ``},
+		{`did:key:`, ``},
+		{`peer:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK`, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.did, func(t *testing.T) {
+			resolved = ``
+			doc, err := reg.Resolve(test.did)
+			if test.method == `` {
+				if err == nil {
+					t.Errorf(`expected an error when resolving %s but it was resolved by %s`, test.did, resolved)
+				}
+				return
+			}
+
+			if err != nil || doc.Id != test.did || resolved != test.method {
+				t.Errorf(`expected %s to be resolved by %s but got %s (err: %v)`, test.did, test.method, resolved, err)
+			}
+		})
+	}
+}
+
+func TestWebURL(t *testing.T) {
+	tests := []struct {
+		did string
+		url string
+	}{
+		{`did:web:w3c-ccg.github.io`, `https://w3c-ccg.github.io/.well-known/did.json`},
+		{`did:web:w3c-ccg.github.io:user:alice`, `https://w3c-ccg.github.io/user/alice/did.json`},
+		{`did:web:example.com%3A8080`, `https://example.com:8080/.well-known/did.json`},
+		{`did:web:example.com%3A8080:user`, `https://example.com:8080/user/did.json`},
+		{`did:web:example.com:user%2Falice`, ``},
+		{`did:web:example.com::alice`, ``},
+		{`did:web:`, ``},
+		{`did:key:example.com`, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.did, func(t *testing.T) {
+			u, err := webURL(test.did)
+			if test.url == `` {
+				if err == nil {
+					t.Errorf(`expected an error for %s but got %s`, test.did, u)
+				}
+				return
+			}
+
+			if err != nil || u != test.url {
+				t.Errorf(`expected %s but got %s (err: %v)`, test.url, u, err)
+			}
+		})
+	}
+}
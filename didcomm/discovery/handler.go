@@ -3,37 +3,34 @@ package discovery
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/models"
 	"github.com/YasiruR/didcomm-prober/domain/services"
-	"github.com/google/uuid"
 	"github.com/tryfix/log"
-	"strings"
 )
 
 type Discoverer struct {
 	queryChan chan models.Message
-	client    services.Client
+	probr     services.Agent
 	server    services.Server
-	// feature values of key '*' are applied for all peers while further
-	// restrictions are listed in the same map under corresponding label
-	features []models.Feature
-	log      log.Logger
+	groups    services.GroupAgent
+	policies  *policies
+	log       log.Logger
 }
 
+// NewDiscoverer discloses the features derived from the handlers of the server
+// to peers, restricted by the globs of the default disclosure policy
 func NewDiscoverer(c *container.Container) *Discoverer {
 	d := &Discoverer{
 		queryChan: make(chan models.Message),
-		client:    c.Client,
+		probr:     c.Prober,
 		server:    c.Server,
-		// features are hard-coded but should ideally be dynamic
-		features: []models.Feature{
-			{Id: `https://didcomm.org/out-of-band/1.0`, Roles: []string{`sender`, `receiver`}},
-			{Id: `https://didcomm.org/didexchange/1.0`, Roles: []string{`inviter`, `invitee`}},
-			{Id: `https://didcomm.org/pub-sub/1.0`, Roles: []string{`publisher`, `subscriber`}},
-		},
-		log: c.Log,
+		groups:    c.PubSub,
+		policies:  initPolicies(c.Cfg.Disclose),
+		log:       c.Log,
 	}
 
 	d.server.AddHandler(models.TypQuery, d.queryChan, false)
@@ -41,103 +38,91 @@ func NewDiscoverer(c *container.Container) *Discoverer {
 	return d
 }
 
+// listen replies the disclosures to the queries of connections via the
+// return route, packed similar to other messages of the connection
 func (d *Discoverer) listen() {
 	for {
 		msg := <-d.queryChan
-		var qm messages.QueryFeature
-		if err := json.Unmarshal(msg.Data, &qm); err != nil {
-			d.log.Error(fmt.Sprintf(`invalid message received as a discovery query (%s) - %v`, string(msg.Data), err))
-			continue
-		}
-
-		dm := d.Disclose(qm.Id, qm.Query)
-		byts, err := json.Marshal(dm)
+		res, err := d.process(msg)
 		if err != nil {
-			d.log.Error(fmt.Sprintf(`marshalling disclose response failed - %v`, err))
+			d.log.Error(fmt.Sprintf(`processing discovery query failed - %v`, err))
+			msg.Reply <- d.probr.ReportProblem(msg, domain.ProblemQueryNotProcessed, err)
 			continue
 		}
 
-		// sending response to the reply channel
-		msg.Reply <- byts
+		msg.Reply <- res
 	}
 }
 
-// Query creates the message as per RFC-0031 and requests from the
-// provided endpoint. As this may be used at any time by an agent,
-// didcomm support for messages is omitted.
-// see https://identity.foundation/didcomm-messaging/spec/#query-message-type
-func (d *Discoverer) Query(endpoint, query, comment string) (fs []models.Feature, err error) {
-	q := messages.QueryFeature{
-		Type:    messages.DiscoverFeatQuery,
-		Id:      uuid.New().String(),
-		Query:   query,
-		Comment: comment,
+func (d *Discoverer) process(msg models.Message) ([]byte, error) {
+	peer, hdr, body, err := d.probr.ReadRequest(msg)
+	if err != nil {
+		return nil, fmt.Errorf(`reading query failed - %w`, err)
 	}
 
-	byts, err := json.Marshal(q)
-	if err != nil {
-		return nil, fmt.Errorf(`marshalling query feature message failed - %v`, err)
+	if hdr.Type != messages.DiscoverFeatQueries {
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`invalid message type for queries (%s)`, hdr.Type))
 	}
 
-	res, err := d.client.Send(models.TypQuery, byts, endpoint)
-	if err != nil {
-		return nil, fmt.Errorf(`sending query message failed - %v`, err)
+	var q messages.Queries
+	if err = json.Unmarshal(body, &q); err != nil {
+		return nil, fmt.Errorf(`unmarshalling queries failed - %v`, err)
 	}
 
-	var dm messages.DiscloseFeature
-	if err = json.Unmarshal([]byte(res), &dm); err != nil {
-		return nil, fmt.Errorf(`unmarshalling disclose response failed - %v`, err)
+	if len(q.Queries) == 0 {
+		return nil, problem.WithThread(q.ThId(), fmt.Errorf(`queries message does not contain a query`))
 	}
 
-	return dm.Features, nil
+	// omitted error since disclosures only contain strings
+	byts, _ := json.Marshal(d.Disclose(peer, q.ThId(), q.Queries))
+	return d.probr.PackMessage(peer, byts)
 }
 
-func (d *Discoverer) Disclose(id, query string) messages.DiscloseFeature {
-	// filter wrt requester if required
-	return messages.DiscloseFeature{
-		Type: messages.DiscoverFeatDisclose,
-		Thread: struct {
-			ThId string `json:"@thid"`
-		}{ThId: id},
-		Features: d.processQuery(query),
+// Query creates the queries message as per RFC-0557 and sends it to the peer
+// via the connection, where the disclosures are expected via the return route
+// see https://github.com/hyperledger/aries-rfcs/tree/main/features/0557-discover-features-v2
+func (d *Discoverer) Query(peer string, queries ...messages.FeatureQuery) (fs []models.Feature, err error) {
+	q := messages.Queries{Message: messages.NewMessage(messages.DiscoverFeatQueries), Queries: queries}
+	q.Transport = &messages.TransportDecorator{ReturnRoute: messages.ReturnRouteAll}
+
+	var dm messages.Disclosures
+	if err = d.probr.Request(peer, models.TypQuery, q, &dm); err != nil {
+		return nil, fmt.Errorf(`querying features failed - %w`, err)
 	}
+
+	if dm.Type != messages.DiscoverFeatDisclose {
+		return nil, fmt.Errorf(`invalid message type for disclosures (%s)`, dm.Type)
+	}
+
+	return dm.Disclosures, nil
 }
 
-// processQuery only performs a soft validation against the query as this
-// is only for the demonstration. Proper regex checks should be implemented
-// for a production release such that all edge-cases are covered.
-func (d *Discoverer) processQuery(query string) []models.Feature {
-	switch query {
-	case ``:
-		return d.features
-	case `*`:
-		return d.features
-	default:
-		// not the most accurate validation (since the inner-check may result in false positives)
-		// eg: `abchttps://didcomm.org/`
-		if !strings.Contains(query, `https://didcomm.org/`) {
-			return nil
-		}
+// Disclose returns the features matching the queries which are permitted by
+// the disclosure policy of the peer to limit fingerprinting of the agent
+func (d *Discoverer) Disclose(peer, thId string, queries []messages.FeatureQuery) messages.Disclosures {
+	dm := messages.Disclosures{Message: messages.NewMessage(messages.DiscoverFeatDisclose), Disclosures: []models.Feature{}}
+	dm.SetThread(thId, ``)
 
-		// if the query has a wild card at the end
-		// this neglects the cases where wild card occurs in the middle
-		if string(query[len(query)-1]) == `*` {
-			var fs []models.Feature
-			for _, f := range d.features {
-				if strings.HasPrefix(f.Id, query[:len(query)-1]) {
-					fs = append(fs, f)
-				}
-			}
-			return fs
+	disclosed := map[string]bool{}
+	for _, f := range d.registry() {
+		if disclosed[f.Id] || !d.policies.allows(peer, f.Id) {
+			continue
 		}
 
-		// if the query is specific to a feature
-		for _, f := range d.features {
-			if f.Id == query {
-				return []models.Feature{f}
+		for _, q := range queries {
+			if f.Type == q.FeatureType && match(q.Match, f.Id) {
+				dm.Disclosures = append(dm.Disclosures, f)
+				disclosed[f.Id] = true
+				break
 			}
 		}
 	}
 
-	return nil
+	return dm
+}
+
+// SetPolicy restricts the features disclosed to the peer, or to all peers
+// without a policy if the peer is '*', to the ones matching any of the globs
+func (d *Discoverer) SetPolicy(peer string, globs ...string) {
+	d.policies.set(peer, globs)
 }
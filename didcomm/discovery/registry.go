@@ -0,0 +1,166 @@
+package discovery
+
+import (
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// role of the agent in a protocol when it handles a message type, where an
+// empty role only indicates that the protocol is supported
+type role struct {
+	protocol string
+	name     string
+}
+
+// handlerRoles maps the message types with handlers on the server to the
+// protocols and the roles which the agent plays by receiving them
+var handlerRoles = map[models.MsgType]role{
+	models.TypConnReq:        {`https://didcomm.org/didexchange/1.0`, `responder`},
+	models.TypConnRes:        {`https://didcomm.org/didexchange/1.0`, `requester`},
+	models.TypConnComplete:   {`https://didcomm.org/didexchange/1.0`, `responder`},
+	models.TypData:           {`https://didcomm.org/basicmessage/1.0`, `receiver`},
+	models.TypQuery:          {`https://didcomm.org/discover-features/2.0`, `responder`},
+	models.TypDIDRotate:      {`https://didcomm.org/did-rotate/1.0`, `observing_party`},
+	models.TypDIDRotateAck:   {`https://didcomm.org/did-rotate/1.0`, `rotating_party`},
+	models.TypTrustPing:      {`https://didcomm.org/trust_ping/1.0`, `receiver`},
+	models.TypTrustPingRes:   {`https://didcomm.org/trust_ping/1.0`, `sender`},
+	models.TypForward:        {`https://didcomm.org/routing/1.0`, `mediator`},
+	models.TypPickup:         {`https://didcomm.org/messagepickup/2.0`, `mediator`},
+	models.TypMediation:      {`https://didcomm.org/coordinate-mediation/1.0`, `mediator`},
+	models.TypHandshakeReuse: {`https://didcomm.org/out-of-band/1.1`, `sender`},
+	// pub-sub roles depend on the groups of the agent
+	models.TypSubscribe: {`https://didcomm.org/pub-sub/1.0`, ``},
+	models.TypGroupJoin: {`https://didcomm.org/pub-sub/1.0`, ``},
+}
+
+// senderRoles are the roles which do not require a handler since the agent
+// only sends messages or receives responses via the return route
+var senderRoles = []role{
+	{`https://didcomm.org/out-of-band/1.1`, `receiver`},
+	{`https://didcomm.org/basicmessage/1.0`, `sender`},
+	{`https://didcomm.org/report-problem/1.0`, `notifier`},
+	{`https://didcomm.org/report-problem/1.0`, `notified`},
+	{`https://didcomm.org/discover-features/2.0`, `requester`},
+	{`https://didcomm.org/routing/1.0`, `sender`},
+	{`https://didcomm.org/messagepickup/2.0`, `recipient`},
+	{`https://didcomm.org/coordinate-mediation/1.0`, `recipient`},
+}
+
+var goalCodes = []string{`aries.rel.build`}
+
+// registry derives the features from the handlers registered on the server
+// and the roles of the agent in groups such that it reflects the current state
+func (d *Discoverer) registry() []models.Feature {
+	roles := map[string]map[string]bool{} // key: protocol
+	add := func(r role) {
+		if roles[r.protocol] == nil {
+			roles[r.protocol] = map[string]bool{}
+		}
+		if r.name != `` {
+			roles[r.protocol][r.name] = true
+		}
+	}
+
+	for _, r := range senderRoles {
+		add(r)
+	}
+
+	for _, mt := range d.server.Handlers() {
+		r, ok := handlerRoles[mt]
+		if !ok {
+			continue
+		}
+
+		add(r)
+		if r.protocol == `https://didcomm.org/pub-sub/1.0` && d.groups != nil {
+			for _, name := range d.groups.Roles() {
+				add(role{r.protocol, name})
+			}
+		}
+	}
+
+	var fs []models.Feature
+	for protocol, names := range roles {
+		f := models.Feature{Type: models.FeatureProtocol, Id: protocol}
+		for name := range names {
+			f.Roles = append(f.Roles, name)
+		}
+		sort.Strings(f.Roles)
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Id < fs[j].Id })
+
+	for _, g := range goalCodes {
+		fs = append(fs, models.Feature{Type: models.FeatureGoalCode, Id: g})
+	}
+
+	return fs
+}
+
+// policies restrict the features disclosed to a peer to the ones matching
+// its globs, where the globs of '*' apply to peers without a policy
+type policies struct {
+	globs map[string][]string // key: label of the peer
+	lock  *sync.RWMutex
+}
+
+// initPolicies discloses all features by default if no globs are given
+func initPolicies(defaults []string) *policies {
+	if defaults == nil {
+		defaults = []string{`*`}
+	}
+
+	return &policies{
+		globs: map[string][]string{`*`: defaults},
+		lock:  &sync.RWMutex{},
+	}
+}
+
+func (p *policies) set(peer string, globs []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.globs[peer] = globs
+}
+
+func (p *policies) allows(peer, featureId string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	globs, ok := p.globs[peer]
+	if !ok {
+		globs = p.globs[`*`]
+	}
+
+	for _, g := range globs {
+		if match(g, featureId) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the value matches the glob where '*' matches any
+// sequence of characters, including separators such as '/' in protocol ids
+func match(glob, val string) bool {
+	parts := strings.Split(glob, `*`)
+	if len(parts) == 1 {
+		return glob == val
+	}
+
+	if !strings.HasPrefix(val, parts[0]) {
+		return false
+	}
+	val = val[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(val, part)
+		if i < 0 {
+			return false
+		}
+		val = val[i+len(part):]
+	}
+
+	return len(val) >= len(last) && strings.HasSuffix(val, last)
+}
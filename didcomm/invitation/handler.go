@@ -5,27 +5,51 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
-	"github.com/google/uuid"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"regexp"
 )
 
+// any minor version of out-of-band 1.x is accepted as per the semver rules of
+// DIDComm (RFC-0003), including the legacy did:sov prefix of message types
+var oobInvType = regexp.MustCompile(`^(https://didcomm\.org/|did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/)out-of-band/1\.\d+/invitation$`)
+
 type OOBService struct {
 	invEndpoint string
+	did         services.DIDUtils
+	resolver    services.DIDResolver
 }
 
-func NewOOBService(cfg *container.Config) *OOBService {
-	return &OOBService{invEndpoint: cfg.Hostname}
+func NewOOBService(cfg *container.Config, did services.DIDUtils, resolver services.DIDResolver) *OOBService {
+	return &OOBService{invEndpoint: cfg.Hostname, did: did, resolver: resolver}
 }
 
-func (o *OOBService) CreateInv(label, did string, didDoc messages.DIDDocument) (url string, err error) {
-	inv := messages.Invitation{
-		Id:       uuid.New().String(),
-		Type:     messages.OOBInvitationV1,
-		From:     did,
-		Label:    label,
-		Services: didDoc.Service, // a separate service to reach back for exchange
-		//Services: createDIDDoc(didEndpoint, `did-communication`, encodedKey).Service, // a separate service to reach back for exchange
+// CreateInv adds the services of the did doc of the inviter to the invitation
+// and encodes it in the url
+func (o *OOBService) CreateInv(inv messages.Invitation, didDoc messages.DIDDocument) (url string, err error) {
+	for _, s := range didDoc.Service {
+		// key references of the did doc are replaced by did:key since
+		// the invitee does not have the did doc
+		keys, err := o.did.ServiceKeys(didDoc, s)
+		if err != nil {
+			return ``, fmt.Errorf(`getting recipient keys of the service %s failed - %v`, s.Id, err)
+		}
+
+		s.RecipientKeys = nil
+		for _, key := range keys {
+			keyDid, err := o.did.CreateKeyDID(domain.KeyEd25519, key)
+			if err != nil {
+				return ``, fmt.Errorf(`creating did:key of the recipient key failed - %v`, err)
+			}
+			s.RecipientKeys = append(s.RecipientKeys, keyDid)
+		}
+
+		// a separate service to reach back for exchange
+		inv.Services = append(inv.Services, s)
+		// envelopes accepted by the exchange service are advertised for the connection request
+		inv.Accept = append(inv.Accept, s.Accept...)
 	}
 
 	byts, err := json.Marshal(inv)
@@ -48,20 +72,52 @@ func (o *OOBService) ParseInv(encInv string) (inv messages.Invitation, endpoint
 		return messages.Invitation{}, "", nil, fmt.Errorf(`received response is not a valid invitation - %v`, err)
 	}
 
-	if len(inv.Services) == 0 {
+	if !oobInvType.MatchString(inv.Type) {
+		return messages.Invitation{}, ``, nil, fmt.Errorf(`invalid message type for invitation (%s)`, inv.Type)
+	}
+
+	if inv.Expired() {
+		return messages.Invitation{}, ``, nil, fmt.Errorf(`invitation has expired`)
+	}
+
+	if len(inv.HandshakeProtocols) == 0 && len(inv.Requests) == 0 {
+		return messages.Invitation{}, ``, nil, fmt.Errorf(`invitation contains neither handshake protocols nor requests`)
+	}
+
+	// services are resolved from the public did of the inviter if not inlined
+	svcs := inv.Services
+	if len(svcs) == 0 && inv.From != `` {
+		doc, err := o.resolver.Resolve(inv.From)
+		if err != nil {
+			return messages.Invitation{}, ``, nil, fmt.Errorf(`resolving inviter did failed - %v`, err)
+		}
+		svcs = doc.Service
+	}
+
+	if len(svcs) == 0 {
 		return messages.Invitation{}, ``, nil, fmt.Errorf(`no service found in invitation [%v]`, inv)
 	}
 
-	for _, s := range inv.Services {
+	for _, s := range svcs {
 		if len(s.RecipientKeys) == 0 {
+			// key is derived from the did of the inviter (eg: did:key)
+			if inv.From != `` && s.ServiceEndpoint != `` {
+				return inv, s.ServiceEndpoint, nil, nil
+			}
 			continue
 		}
 
-		pubKey, err = base64.StdEncoding.DecodeString(s.RecipientKeys[0])
+		// connection request is encrypted to a single invitation key
+		if len(s.RecipientKeys) > 1 {
+			return messages.Invitation{}, ``, nil, fmt.Errorf(`service %s contains %d recipient keys while only a single key is supported`, s.Id, len(s.RecipientKeys))
+		}
+
+		// recipient keys are either did:key or base64 encoded keys
+		keys, err := o.did.ServiceKeys(messages.DIDDocument{}, s)
 		if err != nil {
 			return messages.Invitation{}, ``, nil, fmt.Errorf(`decoding recipient key failed - %v`, err)
 		}
-		return inv, s.ServiceEndpoint, pubKey, nil
+		return inv, s.ServiceEndpoint, keys[0], nil
 	}
 
 	return messages.Invitation{}, ``, nil, fmt.Errorf(`no recipient key found for a service - %v`, inv)
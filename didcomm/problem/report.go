@@ -0,0 +1,74 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+)
+
+/* Problem reports (Aries RFC-0035) sent as replies to failed messages */
+
+const impactMessage = `message`
+
+// threadErr binds an error to the thread of the message which caused it
+type threadErr struct {
+	thId string
+	err  error
+}
+
+func (t *threadErr) Error() string {
+	return t.err.Error()
+}
+
+func (t *threadErr) Unwrap() error {
+	return t.err
+}
+
+// WithThread attaches the thread id to the error such that the problem
+// report created for the error refers to the message
+func WithThread(thId string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &threadErr{thId: thId, err: err}
+}
+
+// Report creates a problem report for the error
+func Report(code string, err error) messages.ProblemReport {
+	pr := messages.ProblemReport{Message: messages.NewMessage(messages.ProblemReportV1), Impact: impactMessage}
+	pr.Description.Code = code
+	pr.Description.En = err.Error()
+
+	var te *threadErr
+	if errors.As(err, &te) {
+		pr.SetThread(te.thId, ``)
+	}
+
+	return pr
+}
+
+// Marshal returns the problem report as a plaintext reply and should only be
+// used if the sender of the message can not be identified
+func Marshal(code string, err error) []byte {
+	// omitted error since the report only contains strings
+	byts, _ := json.Marshal(Report(code, err))
+	return byts
+}
+
+// Parse returns the problem as an error if the data is a problem report
+// and nil otherwise
+func Parse(data []byte) error {
+	var pr messages.ProblemReport
+	if err := json.Unmarshal(data, &pr); err != nil || pr.Type != messages.ProblemReportV1 {
+		return nil
+	}
+
+	// thread is omitted if the message which caused the problem is unknown
+	var thId string
+	if pr.Thread != nil {
+		thId = pr.Thread.ThId
+	}
+
+	return &domain.ProblemError{Code: pr.Description.Code, Explanation: pr.Description.En, ThId: thId}
+}
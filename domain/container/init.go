@@ -2,6 +2,7 @@ package container
 
 import (
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/models"
 	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/tryfix/log"
@@ -15,6 +16,12 @@ type Args struct {
 	Mocker   bool
 	MockPort int
 	Verbose  bool
+	Envelope domain.Envelope // preferred envelope profile for new connections
+	Wallet   string          // path of the encrypted wallet file, keys are kept in memory if empty
+	WalletPw string
+	Store    string   // path of the database file to persist connections and groups, kept in memory if empty
+	Mediator bool     // enables relaying forward messages to the routes of recipients
+	Disclose []string // globs of the features disclosed to peers without a disclosure policy
 }
 
 type Config struct {
@@ -28,11 +35,16 @@ type Config struct {
 type Container struct {
 	Cfg          *Config
 	KeyManager   services.KeyManager
-	Packer       services.Packer
+	ConnStore    services.ConnectionStore
+	GroupStore   services.GroupStore
+	Packers      map[domain.Envelope]services.Packer
+	Signer       services.Signer
 	DidAgent     services.DIDUtils
+	Resolver     services.DIDResolver
 	OOB          services.OutOfBand
 	Connector    services.Connector
 	Prober       services.Agent
+	Mediator     services.Mediator // nil unless the mediator role is enabled
 	Client       services.Client
 	Server       services.Server
 	ConnDoneChan chan models.Connection
@@ -55,6 +67,10 @@ func (c *Container) Stop() error {
 		return fmt.Errorf(`client shutdown failed - %v`, err)
 	}
 
+	if err := c.ConnStore.Close(); err != nil {
+		return fmt.Errorf(`closing connection store failed - %v`, err)
+	}
+
 	c.Log.Info(`graceful shutdown of agent completed successfully`)
 	os.Exit(0)
 	return nil
@@ -61,9 +61,90 @@ func (g GroupMode) Valid() bool {
 	return false
 }
 
+/* States of a connection in the DID exchange protocol (Aries RFC-0023) */
+
+type ConnState string
+
+const (
+	ConnInvited   ConnState = `invited`   // invitation accepted but the request is not sent yet
+	ConnRequested ConnState = `requested` // request sent or received
+	ConnResponded ConnState = `responded` // response sent or received
+	ConnCompleted ConnState = `completed` // complete message sent or received
+	ConnAbandoned ConnState = `abandoned` // exchange failed and can not proceed
+)
+
+/* Envelope profiles of packed messages as media types */
+
+type Envelope string
+
+const (
+	EnvelopeRFC19 Envelope = `didcomm/aip2;env=rfc19`
+	EnvelopeV2    Envelope = `didcomm/v2`
+)
+
+func (e Envelope) Valid() bool {
+	switch e {
+	case EnvelopeRFC19:
+		return true
+	case EnvelopeV2:
+		return true
+	}
+	return false
+}
+
 // Retry parameters
 const (
 	RetryCount        = 10
 	RetryIntervalMs   = 50
 	InternalTimeoutMs = 1000
 )
+
+// RotationGracePeriodMs is the duration in which messages encrypted
+// to the previous keys of a connection are accepted after rotation
+const RotationGracePeriodMs = 5 * 60 * 1000
+
+// SendTimeoutMs is the duration to wait for the reply of a recipient
+// before the message is considered undelivered
+const SendTimeoutMs = 10000
+
+// HandlerTimeoutMs is the duration a synchronous handler is awaited before a
+// problem report is replied, which is shorter than SendTimeoutMs such that the
+// sender receives the report instead of timing out
+const HandlerTimeoutMs = 5000
+
+// RelayTimeoutMs is the duration a mediator waits for the recipient before the
+// relayed message is queued, which is longer than HandlerTimeoutMs such that
+// problem reports of the recipient are received
+const RelayTimeoutMs = HandlerTimeoutMs + 1000
+
+// RelayReplyTimeoutMs is the duration a mediator waits for the reply to a
+// forwarded request with the return route, which is shorter than
+// HandlerTimeoutMs such that the reply is returned before the handler of the
+// mediator times out
+const RelayReplyTimeoutMs = HandlerTimeoutMs - 1000
+
+// MaxQueuedMsgs is the number of messages a mediator queues per connection
+// for recipients which are offline
+const MaxQueuedMsgs = 1000
+
+// PickupBatchSize is the number of queued messages requested per delivery
+const PickupBatchSize = 10
+
+// KeylistPageSize is the number of keys requested per keylist query
+const KeylistPageSize = 100
+
+// PingTimeoutMs is the duration to wait for the response of a trust ping
+const PingTimeoutMs = 5000
+
+// ResolveTimeoutMs is the duration to wait for the did doc of a did:web
+// such that invitations are not blocked by unresponsive hosts
+const ResolveTimeoutMs = 5000
+
+/* Key types of did:key identifiers */
+
+type KeyType string
+
+const (
+	KeyX25519  KeyType = `X25519`
+	KeyEd25519 KeyType = `Ed25519`
+)
@@ -0,0 +1,34 @@
+package domain
+
+import "fmt"
+
+/* Codes of problem reports (Aries RFC-0035) */
+
+const (
+	ProblemMsgNotUnderstood  = `message_not_understood`
+	ProblemMsgProcessing     = `message_processing_error`
+	ProblemReqNotAccepted    = `request_not_accepted`
+	ProblemResNotAccepted    = `response_not_accepted`
+	ProblemCompNotAccepted   = `complete_not_accepted`
+	ProblemJoinNotAccepted   = `join_not_accepted`
+	ProblemSubNotAccepted    = `subscribe_not_accepted`
+	ProblemQueryNotProcessed = `query_not_processed`
+	ProblemTransport         = `transport_error`
+	ProblemNoRoute           = `no_route`
+	ProblemReuseNotAccepted  = `handshake_reuse_not_accepted`
+)
+
+// ProblemError is returned when the recipient of a message replied with a
+// problem report since it failed to process the message
+type ProblemError struct {
+	Code        string
+	Explanation string
+	ThId        string // id of the message which caused the problem if known
+}
+
+func (p *ProblemError) Error() string {
+	if p.ThId == `` {
+		return fmt.Sprintf(`problem reported by the recipient (%s) - %s`, p.Code, p.Explanation)
+	}
+	return fmt.Sprintf(`problem reported by the recipient for %s (%s) - %s`, p.ThId, p.Code, p.Explanation)
+}
@@ -1,10 +1,14 @@
 package messages
 
+// AuthCryptMsg is the encrypted envelope of both Aries RFC-0019 and DIDComm v2.
+// Recipients are only included in the top level of DIDComm v2 messages (JWE
+// general JSON serialization) whereas RFC-0019 includes them in Payload.
 type AuthCryptMsg struct {
-	Protected  string `json:"protected"`
-	Iv         string `json:"iv"`
-	Ciphertext string `json:"ciphertext"`
-	Tag        string `json:"tag"`
+	Protected  string      `json:"protected"`
+	Recipients []Recipient `json:"recipients,omitempty"`
+	Iv         string      `json:"iv"`
+	Ciphertext string      `json:"ciphertext"`
+	Tag        string      `json:"tag"`
 }
 
 type Payload struct {
@@ -21,6 +25,46 @@ type Recipient struct {
 
 type Header struct {
 	Kid    string `json:"kid"`
-	Iv     string `json:"iv"`
-	Sender string `json:"sender"`
+	Iv     string `json:"iv,omitempty"`
+	Sender string `json:"sender,omitempty"`
+}
+
+// JWEHeader is the protected header of a DIDComm v2 encrypted message
+// see: https://identity.foundation/didcomm-messaging/spec/#didcomm-encrypted-messages
+type JWEHeader struct {
+	Typ  string `json:"typ"`
+	Alg  string `json:"alg"`
+	Enc  string `json:"enc"`
+	Skid string `json:"skid,omitempty"`
+	Apu  string `json:"apu,omitempty"`
+	Apv  string `json:"apv"`
+	Epk  JWK    `json:"epk"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// JWS is a DIDComm v2 signed message in JWS general JSON serialization
+// see: https://identity.foundation/didcomm-messaging/spec/#didcomm-signed-messages
+type JWS struct {
+	Payload    string      `json:"payload"`
+	Signatures []Signature `json:"signatures"`
+}
+
+type Signature struct {
+	Protected string    `json:"protected"`
+	Signature string    `json:"signature"`
+	Header    SigHeader `json:"header"`
+}
+
+type SigHeader struct {
+	Kid string `json:"kid"`
+}
+
+type JWSHeader struct {
+	Typ string `json:"typ"`
+	Alg string `json:"alg"`
 }
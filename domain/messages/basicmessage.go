@@ -0,0 +1,11 @@
+package messages
+
+// BasicMessage reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0095-basic-message#reference
+type BasicMessage struct {
+	Message
+	L10n struct {
+		Locale string `json:"locale"`
+	} `json:"~l10n"`
+	SentTime string `json:"sent_time"`
+	Content  string `json:"content"`
+}
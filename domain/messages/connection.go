@@ -1,24 +1,21 @@
 package messages
 
-// Invitation reference: https://identity.foundation/didcomm-messaging/spec/#invitation
-type Invitation struct {
-	Id    string `json:"id"`
-	Type  string `json:"type"`
-	From  string `json:"from"`
-	Label string `json:"label"` // from aries rfc-0160
-	Body  struct {
-		GoalCode string   `json:"goal_code"`
-		Goal     string   `json:"goal"`
-		Accept   []string `json:"accept"`
-	} `json:"body"`
-	Attachments interface{}
-	Services    []Service `json:"services"` // deprecated in v2 but used for simplicity
+type DIDDocument struct {
+	Context            []string             `json:"@context"`
+	Id                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	KeyAgreement       []string             `json:"keyAgreement,omitempty"`
+	Service            []Service            `json:"service"`
 }
 
-type DIDDocument struct {
-	Context []string  `json:"@context"`
-	Id      string    `json:"id"`
-	Service []Service `json:"service"`
+type VerificationMethod struct {
+	Id                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+	PublicKeyBase58    string `json:"publicKeyBase58,omitempty"`
+	PublicKeyJwk       *JWK   `json:"publicKeyJwk,omitempty"`
 }
 
 type Service struct {
@@ -31,14 +28,10 @@ type Service struct {
 }
 
 // ConnReq reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#request-message-example
+// Parent thread should contain the id of the corresponding invitation
+// (https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#request-message-attributes)
 type ConnReq struct {
-	Id     string `json:"@id"`
-	Type   string `json:"@type"`
-	Thread struct {
-		ThId string `json:"thid"`
-		// should contain the id of the corresponding invitation (https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#request-message-attributes)
-		PThId string `json:"pthid"`
-	} `json:"~thread"`
+	Message
 	Label        string `json:"label"`
 	GoalCode     string `json:"goal_code"`
 	Goal         string `json:"goal"`
@@ -53,13 +46,10 @@ type ConnReq struct {
 }
 
 // ConnRes reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#response-message-example
+// Thread must be a reference to the request message
+// (https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#response-message-attributes)
 type ConnRes struct {
-	Id     string `json:"@id"`
-	Type   string `json:"@type"`
-	Thread struct {
-		// must be a reference to the request message (https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#response-message-attributes)
-		ThId string `json:"thid"`
-	} `json:"~thread"`
+	Message
 	DID          string `json:"did"`
 	DIDDocAttach struct {
 		Id       string `json:"@id"`
@@ -69,3 +59,30 @@ type ConnRes struct {
 		}
 	} `json:"did_doc~attach"`
 }
+
+// ConnComplete reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0023-did-exchange#3-exchange-complete
+type ConnComplete struct {
+	Message
+}
+
+// Rotate reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0794-did-rotate#rotate
+// New DID doc is attached similar to DID exchange messages for DIDs which can
+// not be resolved. Rotate message is packed with the keys of the previous DID
+// and hence the attachment is not encrypted separately.
+type Rotate struct {
+	Message
+	ToDID        string `json:"to_did"`
+	DIDDocAttach struct {
+		Id       string `json:"@id"`
+		MimeType string `json:"mime-type"`
+		Data     struct {
+			Base64 string `json:"base64"`
+		}
+	} `json:"did_doc~attach"`
+}
+
+// RotateAck reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0794-did-rotate#ack
+type RotateAck struct {
+	Message
+	Status string `json:"status"`
+}
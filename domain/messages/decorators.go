@@ -0,0 +1,174 @@
+package messages
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+/* Common attributes and decorators of plaintext messages
+   see: https://github.com/hyperledger/aries-rfcs/tree/main/concepts/0011-decorators */
+
+// TimeFormat is the ISO 8601 format of the timestamps in messages (Aries RFC-0074)
+const TimeFormat = time.RFC3339
+
+// timeFormats are the ISO 8601 variants accepted in timestamps of other agents
+// (eg: 2019-01-23 18:25Z as in Aries RFC-0032)
+var timeFormats = []string{
+	time.RFC3339Nano,
+	`2006-01-02T15:04Z07:00`,
+	`2006-01-02 15:04:05.999999999Z07:00`,
+	`2006-01-02 15:04Z07:00`,
+}
+
+// maxThreads is the number of threads of which the sender order is tracked
+const maxThreads = 10000
+
+// Return routes of the transport decorator
+const (
+	ReturnRouteNone   = `none`
+	ReturnRouteAll    = `all`
+	ReturnRouteThread = `thread`
+)
+
+// Message is embedded in plaintext messages of all protocols such that
+// threading and expiry are handled uniformly
+type Message struct {
+	Id        string              `json:"@id"`
+	Type      string              `json:"@type"`
+	Thread    *Thread             `json:"~thread,omitempty"`
+	Timing    *Timing             `json:"~timing,omitempty"`
+	Transport *TransportDecorator `json:"~transport,omitempty"`
+}
+
+// Thread reference: https://github.com/hyperledger/aries-rfcs/tree/main/concepts/0008-message-id-and-threading#thread-object
+type Thread struct {
+	ThId  string `json:"thid,omitempty"`
+	PThId string `json:"pthid,omitempty"`
+	// position of the message among the messages of the sender in the thread
+	SenderOrder int `json:"sender_order,omitempty"`
+}
+
+// Timing reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0032-message-timing
+type Timing struct {
+	OutTime     string `json:"out_time,omitempty"`
+	ExpiresTime string `json:"expires_time,omitempty"`
+}
+
+// TransportDecorator reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0092-transport-return-route
+type TransportDecorator struct {
+	ReturnRoute       string `json:"return_route,omitempty"`
+	ReturnRouteThread string `json:"return_route_thread,omitempty"`
+}
+
+// NewMessage creates a message of the type with a new id and the sent time
+func NewMessage(typ string) Message {
+	return Message{
+		Id:     uuid.New().String(),
+		Type:   typ,
+		Timing: &Timing{OutTime: time.Now().UTC().Format(TimeFormat)},
+	}
+}
+
+// Reply creates a message of the type in the thread of the message
+func (m Message) Reply(typ string) Message {
+	r := NewMessage(typ)
+	r.SetThread(m.ThId(), m.PThId())
+	return r
+}
+
+// ThId returns the thread id which is the id of the message if it starts a thread
+func (m Message) ThId() string {
+	if m.Thread == nil || m.Thread.ThId == `` {
+		return m.Id
+	}
+	return m.Thread.ThId
+}
+
+func (m Message) PThId() string {
+	if m.Thread == nil {
+		return ``
+	}
+	return m.Thread.PThId
+}
+
+// SetThread places the message in the thread of thId under the parent thread
+// of pthId along with the number of messages previously sent in the thread
+func (m *Message) SetThread(thId, pthId string) {
+	m.Thread = &Thread{ThId: thId, PThId: pthId, SenderOrder: senderOrders.next(thId)}
+}
+
+// ExpiresIn sets the time after which the message should not be processed
+func (m *Message) ExpiresIn(d time.Duration) {
+	if m.Timing == nil {
+		m.Timing = &Timing{}
+	}
+	m.Timing.ExpiresTime = time.Now().Add(d).UTC().Format(TimeFormat)
+}
+
+// Expired returns true if the expiry time of the message has elapsed, while
+// expiry times which can not be parsed are ignored
+func (m Message) Expired() bool {
+	if m.Timing == nil || m.Timing.ExpiresTime == `` {
+		return false
+	}
+
+	expiry, err := ParseTime(m.Timing.ExpiresTime)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().After(expiry)
+}
+
+// ParseTime parses a timestamp in any of the accepted ISO 8601 formats
+func ParseTime(s string) (t time.Time, err error) {
+	for _, f := range timeFormats {
+		if t, err = time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// threadOrders counts the messages created by the agent in each thread such
+// that the sender order is set. Threads are removed in the order they were
+// started once the limit is reached.
+type threadOrders struct {
+	orders map[string]int
+	thIds  []string
+	lock   *sync.Mutex
+}
+
+var senderOrders = &threadOrders{orders: map[string]int{}, lock: &sync.Mutex{}}
+
+// next returns the sender order of the next message in the thread
+func (t *threadOrders) next(thId string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	order, ok := t.orders[thId]
+	if !ok {
+		if len(t.thIds) == maxThreads {
+			delete(t.orders, t.thIds[0])
+			t.thIds = t.thIds[1:]
+		}
+		t.thIds = append(t.thIds, thId)
+	}
+
+	t.orders[thId] = order + 1
+	return order
+}
+
+// Attachment reference: https://github.com/hyperledger/aries-rfcs/tree/main/concepts/0017-attachments
+type Attachment struct {
+	Id       string         `json:"@id"`
+	MimeType string         `json:"mime-type,omitempty"`
+	Data     AttachmentData `json:"data"`
+}
+
+type AttachmentData struct {
+	Json   json.RawMessage `json:"json,omitempty"`
+	Base64 string          `json:"base64,omitempty"`
+}
@@ -0,0 +1,52 @@
+package messages
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessage_Expired(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour).UTC(), time.Now().Add(time.Hour).UTC()
+	tests := []struct {
+		name    string
+		expiry  string
+		expired bool
+	}{
+		{`no expiry`, ``, false},
+		{`rfc3339 elapsed`, past.Format(time.RFC3339), true},
+		{`rfc3339 pending`, future.Format(time.RFC3339), false},
+		{`fractional seconds`, past.Format(time.RFC3339Nano), true},
+		{`rfc0032 format`, `2019-01-23 18:25Z`, true},
+		{`space separated with offset`, future.Format(`2006-01-02 15:04:05-07:00`), false},
+		{`minutes only`, past.Format(`2006-01-02T15:04Z07:00`), true},
+		{`invalid`, `tomorrow`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMessage(TrustPingV1)
+			if test.expiry != `` {
+				m.Timing.ExpiresTime = test.expiry
+			}
+
+			if m.Expired() != test.expired {
+				t.Errorf(`expected expired to be %t for %s`, test.expired, test.expiry)
+			}
+		})
+	}
+}
+
+func TestMessage_SenderOrder(t *testing.T) {
+	req := NewMessage(TrustPingV1)
+	req.SetThread(req.Id, ``)
+
+	first, second := req.Reply(TrustPingV1), req.Reply(TrustPingV1)
+	if req.Thread.SenderOrder != 0 || first.Thread.SenderOrder != 1 || second.Thread.SenderOrder != 2 {
+		t.Errorf(`unexpected sender orders (%d, %d, %d)`, req.Thread.SenderOrder, first.Thread.SenderOrder, second.Thread.SenderOrder)
+	}
+
+	other := NewMessage(TrustPingV1).Reply(TrustPingV1)
+	if other.Thread.SenderOrder != 0 {
+		t.Errorf(`expected the first message of a new thread to have sender order 0 but got %d`, other.Thread.SenderOrder)
+	}
+}
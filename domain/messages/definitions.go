@@ -1,15 +1,37 @@
 package messages
 
 const (
-	OOBInvitationV1      = `https://didcomm.org/out-of-band/1.0/invitation`
+	OOBInvitationV11     = `https://didcomm.org/out-of-band/1.1/invitation`
+	HandshakeReuseV11    = `https://didcomm.org/out-of-band/1.1/handshake-reuse`
+	ReuseAcceptedV11     = `https://didcomm.org/out-of-band/1.1/handshake-reuse-accepted`
 	DIDExchangeReqV1     = `https://didcomm.org/didexchange/1.0/request`
 	DIDExchangeResV1     = `https://didcomm.org/didexchange/1.0/response`
 	DIDExchangeCompV1    = `https://didcomm.org/didexchange/1.0/complete`
-	DiscoverFeatQuery    = `https://didcomm.org/discover-features/1.0/query`
-	DiscoverFeatDisclose = `https://didcomm.org/discover-features/1.0/disclose`
+	DiscoverFeatQueries  = `https://didcomm.org/discover-features/2.0/queries`
+	DiscoverFeatDisclose = `https://didcomm.org/discover-features/2.0/disclosures`
 	SubscribeV1          = `https://didcomm.org/pub-sub/1.0/subscribe`
 	JoinRequestV1        = `https://didcomm.org/pub-sub/1.0/join-request`
 	JoinResponseV1       = `https://didcomm.org/pub-sub/1.0/join-response`
+	SubscribeResV1       = `https://didcomm.org/pub-sub/1.0/subscribe-response`
 	MemberStatusV1       = `https://didcomm.org/pub-sub/1.0/status`
 	HelloProtocolV1      = `https://didcomm.org/pub-sub/1.0/hello`
+	DIDRotateV1          = `https://didcomm.org/did-rotate/1.0/rotate`
+	DIDRotateAckV1       = `https://didcomm.org/did-rotate/1.0/ack`
+	ProblemReportV1      = `https://didcomm.org/report-problem/1.0/problem-report`
+	TrustPingV1          = `https://didcomm.org/trust_ping/1.0/ping`
+	TrustPingResV1       = `https://didcomm.org/trust_ping/1.0/ping_response`
+	BasicMessageV1       = `https://didcomm.org/basicmessage/1.0/message`
+	ForwardV1            = `https://didcomm.org/routing/1.0/forward`
+	PickupStatusReqV2    = `https://didcomm.org/messagepickup/2.0/status-request`
+	PickupStatusV2       = `https://didcomm.org/messagepickup/2.0/status`
+	PickupDeliveryReqV2  = `https://didcomm.org/messagepickup/2.0/delivery-request`
+	PickupDeliveryV2     = `https://didcomm.org/messagepickup/2.0/delivery`
+	PickupReceivedV2     = `https://didcomm.org/messagepickup/2.0/messages-received`
+	MediateRequestV1     = `https://didcomm.org/coordinate-mediation/1.0/mediate-request`
+	MediateGrantV1       = `https://didcomm.org/coordinate-mediation/1.0/mediate-grant`
+	MediateDenyV1        = `https://didcomm.org/coordinate-mediation/1.0/mediate-deny`
+	KeylistUpdateV1      = `https://didcomm.org/coordinate-mediation/1.0/keylist-update`
+	KeylistUpdateResV1   = `https://didcomm.org/coordinate-mediation/1.0/keylist-update-response`
+	KeylistQueryV1       = `https://didcomm.org/coordinate-mediation/1.0/keylist-query`
+	KeylistV1            = `https://didcomm.org/coordinate-mediation/1.0/keylist`
 )
@@ -2,17 +2,21 @@ package messages
 
 import "github.com/YasiruR/didcomm-prober/domain/models"
 
-type QueryFeature struct {
-	Type    string `json:"@type"`
-	Id      string `json:"@id"`
-	Query   string `json:"query"`
-	Comment string `json:"comment"`
+// Queries reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0557-discover-features-v2#queries-message-type
+type Queries struct {
+	Message
+	Queries []FeatureQuery `json:"queries"`
 }
 
-type DiscloseFeature struct {
-	Type   string `json:"@type"`
-	Thread struct {
-		ThId string `json:"@thid"`
-	} `json:"~thread"`
-	Features []models.Feature `json:"features"`
+// FeatureQuery matches the ids of the features of the type where
+// a trailing wildcard matches any suffix
+type FeatureQuery struct {
+	FeatureType string `json:"feature-type"`
+	Match       string `json:"match"`
+}
+
+// Disclosures reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0557-discover-features-v2#disclosures-message-type
+type Disclosures struct {
+	Message
+	Disclosures []models.Feature `json:"disclosures"`
 }
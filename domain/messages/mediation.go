@@ -0,0 +1,80 @@
+package messages
+
+/* Coordinate mediation reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0211-route-coordination */
+
+// Actions of keylist updates
+const (
+	KeylistAdd    = `add`
+	KeylistRemove = `remove`
+)
+
+// Results of keylist updates
+const (
+	KeylistSuccess     = `success`
+	KeylistNoChange    = `no_change`
+	KeylistClientError = `client_error`
+	KeylistServerError = `server_error`
+)
+
+type MediateRequest struct {
+	Message
+}
+
+// MediateGrant contains the endpoint and the routing keys which the
+// recipient should include in its did docs as did:key identifiers
+type MediateGrant struct {
+	Message
+	Endpoint    string   `json:"endpoint"`
+	RoutingKeys []string `json:"routing_keys"`
+}
+
+type MediateDeny struct {
+	Message
+}
+
+type KeylistUpdate struct {
+	Message
+	Updates []KeylistUpdateRule `json:"updates"`
+}
+
+type KeylistUpdateRule struct {
+	RecipientKey string `json:"recipient_key"`
+	Action       string `json:"action"`
+}
+
+type KeylistUpdateResponse struct {
+	Message
+	Updated []KeylistUpdated `json:"updated"`
+}
+
+type KeylistUpdated struct {
+	RecipientKey string `json:"recipient_key"`
+	Action       string `json:"action"`
+	Result       string `json:"result"`
+}
+
+type KeylistQuery struct {
+	Message
+	Paginate *Paginate `json:"paginate,omitempty"`
+}
+
+type Paginate struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type Keylist struct {
+	Message
+	Keys       []KeylistKey `json:"keys"`
+	Pagination *Pagination  `json:"pagination,omitempty"`
+}
+
+type KeylistKey struct {
+	RecipientKey string `json:"recipient_key"`
+}
+
+type Pagination struct {
+	Count          int `json:"count"`
+	Offset         int `json:"offset"`
+	RemainingCount int `json:"remaining_count"`
+}
@@ -0,0 +1,34 @@
+package messages
+
+/* Out-of-band 1.1 reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0434-outofband */
+
+// DIDExchangeV1 is the handshake protocol supported for invitations
+const DIDExchangeV1 = `https://didcomm.org/didexchange/1.0`
+
+// Invitation does not require a connection to be established if handshake
+// protocols are omitted, in which case the attached requests are processed
+// without a connection
+type Invitation struct {
+	Message
+	Label              string       `json:"label,omitempty"`
+	GoalCode           string       `json:"goal_code,omitempty"`
+	Goal               string       `json:"goal,omitempty"`
+	Accept             []string     `json:"accept,omitempty"`
+	HandshakeProtocols []string     `json:"handshake_protocols,omitempty"`
+	Requests           []Attachment `json:"requests~attach,omitempty"`
+	Services           []Service    `json:"services"`
+	// From is the did of the inviter which is the same for all of its
+	// invitations and resolves to the services if they are not inlined
+	// (not defined in rfc-0434)
+	From string `json:"from,omitempty"`
+}
+
+// HandshakeReuse is sent via an existing connection in the thread of its id
+// and the parent thread of the invitation
+type HandshakeReuse struct {
+	Message
+}
+
+type HandshakeReuseAccepted struct {
+	Message
+}
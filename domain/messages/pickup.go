@@ -0,0 +1,53 @@
+package messages
+
+import "encoding/json"
+
+/* Message pickup reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0685-pickup-v2 */
+
+// PickupStatusRequest queries the messages queued for the recipient
+// key or for all keys of the connection if the key is omitted
+type PickupStatusRequest struct {
+	Message
+	RecipientKey string `json:"recipient_key,omitempty"`
+}
+
+type PickupStatus struct {
+	Message
+	RecipientKey         string `json:"recipient_key,omitempty"`
+	MessageCount         int    `json:"message_count"`
+	LongestWaitedSeconds int    `json:"longest_waited_seconds,omitempty"`
+	NewestReceivedTime   string `json:"newest_received_time,omitempty"`
+	OldestReceivedTime   string `json:"oldest_received_time,omitempty"`
+	TotalBytes           int    `json:"total_bytes,omitempty"`
+	LiveDelivery         bool   `json:"live_delivery"`
+}
+
+type PickupDeliveryRequest struct {
+	Message
+	Limit        int    `json:"limit"`
+	RecipientKey string `json:"recipient_key,omitempty"`
+}
+
+// PickupDelivery attaches the queued messages in the order they were received
+type PickupDelivery struct {
+	Message
+	RecipientKey string             `json:"recipient_key,omitempty"`
+	Attachments  []QueuedAttachment `json:"~attach"`
+}
+
+// QueuedAttachment contains the packed message as json while MsgType is
+// included since the transport selects the handler by the type of the message
+type QueuedAttachment struct {
+	Id      string `json:"@id"`
+	MsgType string `json:"~msg_type"`
+	Data    struct {
+		Json json.RawMessage `json:"json"`
+	} `json:"data"`
+}
+
+// PickupReceived acknowledges the delivered messages by their attachment ids
+// such that the mediator removes them from the queue
+type PickupReceived struct {
+	Message
+	MessageIdList []string `json:"message_id_list"`
+}
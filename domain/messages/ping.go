@@ -0,0 +1,14 @@
+package messages
+
+// Ping reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0048-trust-ping#messages
+type Ping struct {
+	Message
+	Comment           string `json:"comment,omitempty"`
+	ResponseRequested bool   `json:"response_requested"`
+}
+
+// PingResponse reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0048-trust-ping#messages
+type PingResponse struct {
+	Message
+	Comment string `json:"comment,omitempty"`
+}
@@ -0,0 +1,11 @@
+package messages
+
+// ProblemReport reference: https://github.com/hyperledger/aries-rfcs/tree/main/features/0035-report-problem
+type ProblemReport struct {
+	Message
+	Description struct {
+		Code string `json:"code"`
+		En   string `json:"en"`
+	} `json:"description"`
+	Impact string `json:"impact,omitempty"`
+}
@@ -5,44 +5,42 @@ import (
 )
 
 type Subscribe struct {
-	Id        string        `json:"@id"`
-	Type      string        `json:"@type"`
-	Subscribe bool          `json:"subscribe"`
-	PubKey    string        `json:"pubKey"` // base58 encoding of public key
-	Topic     string        `json:"topic"`
-	Member    models.Member `json:"member"`
-	Transport Transport     `json:"transport"`
+	Message
+	Subscribe    bool          `json:"subscribe"`
+	PubKey       string        `json:"pubKey"` // base58 encoding of public key
+	Topic        string        `json:"topic"`
+	Member       models.Member `json:"member"`
+	ZmqTransport Transport     `json:"transport"` // named apart from the transport decorator of the message
 }
 
 type ResSubscribe struct {
-	Publisher bool      `json:"publisher"`
-	Transport Transport `json:"transport"`
-	Checksum  string    `json:"checksum"`
+	Message
+	Publisher    bool      `json:"publisher"`
+	ZmqTransport Transport `json:"transport"`
+	Checksum     string    `json:"checksum"`
 }
 
+// Transport contains the zmq keys of a member
 type Transport struct {
 	ServrPubKey  string `json:"servr_pub_key"`
 	ClientPubKey string `json:"client_pub_key"`
 }
 
 type Status struct {
-	Id       string            `json:"@id"`
-	Type     string            `json:"@type"`
+	Message
 	Topic    string            `json:"topic"` // might be a redundant info in general mq systems
 	AuthMsgs map[string]string `json:"auth_msgs"`
 }
 
 type ReqGroupJoin struct {
-	Id           string `json:"@id"`
-	Type         string `json:"@type"`
+	Message
 	Label        string `json:"label"`
 	Topic        string `json:"topic"`
 	RequesterInv string `json:"requesterInv"`
 }
 
 type ResGroupJoin struct {
-	Id      string             `json:"@id"`
-	Type    string             `json:"@type"`
+	Message
 	Params  models.GroupParams `json:"params"`
 	Members []models.Member    `json:"members"` // includes acceptor
 }
@@ -0,0 +1,13 @@
+package messages
+
+import "encoding/json"
+
+// Forward reference: https://github.com/hyperledger/aries-rfcs/tree/main/concepts/0094-cross-domain-messaging#corresponding-code
+// MsgType is only included in the innermost forward since the transport selects
+// the handler of the recipient by the type of the message
+type Forward struct {
+	Message
+	To      string          `json:"to"` // base58 encoded recipient key
+	Msg     json.RawMessage `json:"msg"`
+	MsgType string          `json:"~msg_type,omitempty"`
+}
@@ -1,17 +1,94 @@
 package models
 
-import "github.com/YasiruR/didcomm-prober/domain"
+import (
+	"github.com/YasiruR/didcomm-prober/domain"
+	"time"
+)
 
 type Peer struct {
 	Active       bool // currently all stored peers are active since no disconnect is implemented
 	DID          string
 	ExchangeThId string // thread id used in did-exchange (to correlate any message to the peer)
+	InvId        string // id of the invitation which is the parent thread of the exchange
+	InvDID       string // did of the inviter in the invitation (eg: a public did) used for handshake reuse
+	State        domain.ConnState
 	Services     []Service
+	Envelope     domain.Envelope // negotiated envelope profile for the connection
 }
 
+// Mediation is the mediator granted to the agent whose endpoint and routing
+// keys are included in the message services of subsequent did docs
+type Mediation struct {
+	Mediator    string // label of the connection with the mediator
+	Endpoint    string
+	RoutingKeys [][]byte
+}
+
+// Route of a recipient key registered by a mediated connection
+type Route struct {
+	Peer     string
+	Endpoint string // messages are queued for pickup if empty
+}
+
+// QueuedMsg is a message relayed by the mediator to a recipient which was not reachable
+type QueuedMsg struct {
+	Id       string
+	RecKey   string // base58 encoded
+	Type     MsgType
+	Data     []byte
+	Received time.Time
+}
+
+// InvRecord is an invitation created by the agent where zero values of
+// Expiry and MaxUses imply that the invitation neither expires nor is
+// limited in the number of connections
+type InvRecord struct {
+	Id      string
+	Created time.Time
+	Expiry  time.Time
+	MaxUses int
+	Uses    int      // number of accepted connection requests
+	Threads []string // exchange threads which were counted as uses
+	Goal    string
+	// Handshake is false for connectionless invitations which only carry requests
+	Handshake bool
+}
+
+func (r InvRecord) Expired() bool {
+	return !r.Expiry.IsZero() && time.Now().After(r.Expiry)
+}
+
+func (r InvRecord) Exhausted() bool {
+	return r.MaxUses > 0 && r.Uses >= r.MaxUses
+}
+
+// InvOptions restrict the validity of an invitation
+type InvOptions struct {
+	Expiry  time.Duration // does not expire if zero
+	MaxUses int           // unlimited if zero
+	Goal    string
+}
+
+// PingStats contains the round-trip times of trust pings sent to a peer
+type PingStats struct {
+	Sent     int           `json:"sent"`
+	Received int           `json:"received"`
+	Last     time.Duration `json:"last"`
+	Min      time.Duration `json:"min"`
+	Max      time.Duration `json:"max"`
+	Avg      time.Duration `json:"avg"`
+}
+
+// Types of features which can be discovered
+const (
+	FeatureProtocol = `protocol`
+	FeatureGoalCode = `goal-code`
+)
+
 type Feature struct {
+	Type  string   `json:"feature-type"`
 	Id    string   `json:"id"`
-	Roles []string `json:"roles"`
+	Roles []string `json:"roles,omitempty"`
 }
 
 type Service struct {
@@ -19,6 +96,10 @@ type Service struct {
 	Type     string
 	Endpoint string
 	PubKey   []byte
+	Accept   []string // media types of envelopes accepted by the service
+	// RoutingKeys are the keys of the mediators where the first one belongs
+	// to the mediator closest to the recipient
+	RoutingKeys [][]byte
 }
 
 type Member struct {
@@ -27,12 +108,15 @@ type Member struct {
 	Label       string `json:"label"` // todo check if DID can be used
 	Inv         string `json:"inv"`
 	PubEndpoint string `json:"pubEndpoint"`
+	SigKey      string `json:"sigKey,omitempty"`   // base58 encoded verification key of group messages
+	GroupKey    string `json:"groupKey,omitempty"` // base58 encoded sender key of the envelopes of group messages
 }
 
 type GroupParams struct {
 	OrderEnabled   bool             `json:"ordered"`
 	JoinConsistent bool             `json:"consistent_join"`
 	Mode           domain.GroupMode `json:"mode"`
+	Signed         bool             `json:"signed"` // group messages are signed by publishers
 }
 
 type Group struct {
@@ -13,6 +13,15 @@ const (
 	TypGroupMsg
 	TypStatusAck
 	TypTerminate
+	TypDIDRotate
+	TypDIDRotateAck
+	TypConnComplete
+	TypTrustPing
+	TypTrustPingRes
+	TypForward
+	TypPickup
+	TypMediation
+	TypHandshakeReuse
 )
 
 func (m MsgType) String() string {
@@ -37,11 +46,39 @@ func (m MsgType) String() string {
 		return `hello-ack`
 	case TypTerminate:
 		return `internal-terminate-message`
+	case TypDIDRotate:
+		return `did-rotate`
+	case TypDIDRotateAck:
+		return `did-rotate-ack`
+	case TypConnComplete:
+		return `connection-complete`
+	case TypTrustPing:
+		return `trust-ping`
+	case TypTrustPingRes:
+		return `trust-ping-response`
+	case TypForward:
+		return `forward`
+	case TypPickup:
+		return `pickup`
+	case TypMediation:
+		return `coordinate-mediation`
+	case TypHandshakeReuse:
+		return `handshake-reuse`
 	default:
 		return `undefined`
 	}
 }
 
+// MsgTypeByName returns the message type of the name given by String
+func MsgTypeByName(name string) (MsgType, bool) {
+	for mt := TypConnReq; mt.String() != `undefined`; mt++ {
+		if mt.String() == name {
+			return mt, true
+		}
+	}
+	return 0, false
+}
+
 type Message struct {
 	Type  MsgType
 	Data  []byte
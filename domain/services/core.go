@@ -1,42 +1,138 @@
 package services
 
 import (
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"time"
 )
 
 /* core services */
 
 type Agent interface {
+	// Invite creates an invitation which neither expires nor is limited in uses
 	Invite() (url string, err error)
+	// InviteWith creates an invitation which is valid until it expires or
+	// has been used for the maximum number of connections
+	InviteWith(opts models.InvOptions) (url string, err error)
+	// InviteConnectionless creates an invitation with the messages attached as
+	// requests which are processed by the invitee without a connection
+	InviteConnectionless(requests ...interface{}) (url string, err error)
 	SyncAccept(encodedInv string) error
 	Accept(encodedInv string) (sender string, err error)
 	SendMessage(mt models.MsgType, to, text string) error
+	// SendAnonMessage anoncrypts the message so that the recipient
+	// can not identify the sender
+	SendAnonMessage(mt models.MsgType, to, text string) error
 	ReadMessage(msg models.Message) (sender, text string, err error)
+	// ReadGroupMessage reads a group message whose sender key is
+	// authenticated by authFunc instead of the connection key
+	ReadGroupMessage(msg models.Message, authFunc func(sender string, sendPubKey []byte) error) (sender, text string, err error)
 	Peer(label string) (models.Peer, error)
 	Service(name, peer string) (*models.Service, error)
 	// SyncService is a blocking function which does not return until
 	// either of the service information or timeout is received
 	SyncService(name, peer string, timeoutMs int64) (*models.Service, error)
-	// ValidConn checks if a peer has been connected by the given exchange ID
+	// ValidConn checks if the exchange with the given ID has been completed
 	ValidConn(exchId string) (pr models.Peer, ok bool)
+	// Rotate replaces the keys and DID of the connection with the peer
+	// while previous keys are retained for a grace period
+	Rotate(peer string) error
+	// ReportProblem creates the problem report replied to a message which failed
+	// with the given code, packed if the message was received via a connection
+	ReportProblem(msg models.Message, code string, err error) []byte
+	// Ping sends a trust ping to the peer and returns the round-trip
+	// time once the ping response is received
+	Ping(peer string) (rtt time.Duration, err error)
+	// PingStats returns the round-trip times of the trust pings to the peer
+	PingStats(peer string) (stats models.PingStats, ok bool)
+	// QueuedMessages returns the number of messages queued by the mediator
+	// while the recipient was offline
+	QueuedMessages(mediator string) (int, error)
+	// Pickup processes the messages queued by the mediator and returns
+	// the number of messages picked up
+	Pickup(mediator string) (n int, err error)
+	// RequestMediation enrolls with the peer as the mediator whose endpoint and
+	// routing keys are included in the did docs of subsequent connections
+	RequestMediation(mediator string) error
+	// MediatedKeys returns the recipient keys registered with the mediator
+	MediatedKeys(mediator string) ([]string, error)
+	// Request sends the message to the peer, given by the label or the did of the
+	// connection, and unmarshalls the response returned via the return route into res
+	Request(peer string, mt models.MsgType, req, res interface{}) error
+	// ReadRequest reads a message of a connection which expects the response via
+	// the return route, where the response should be packed with PackMessage
+	ReadRequest(msg models.Message) (peer string, hdr messages.Message, body []byte, err error)
+	// PackMessage authcrypts the message to the connection with the peer
+	PackMessage(peer string, msg []byte) ([]byte, error)
+	// Invitations returns the records of the invitations created by the agent
+	Invitations() ([]models.InvRecord, error)
+	// RevokeInvitation rejects subsequent connection requests of the invitation
+	RevokeInvitation(id string) error
+}
+
+// Mediator relays forward messages (Aries RFC-0094) to the recipients
+// registered against their keys
+type Mediator interface {
+	// RoutingKey is the key which forward messages are anoncrypted to
+	RoutingKey() []byte
+	// AddRoute registers the endpoint of a recipient key for the connection
+	// with the recipient, where messages are queued for pickup if the
+	// endpoint is empty or the recipient is offline
+	AddRoute(peer string, recKey []byte, endpoint string) error
+	RemoveRoute(recKey []byte) error
 }
 
 type DIDUtils interface {
 	CreateDIDDoc(svcs []models.Service) messages.DIDDocument
+	// AssignDID sets the did as the id and the controller of the verification methods
+	AssignDID(doc messages.DIDDocument, did string) messages.DIDDocument
+	// ServiceKeys returns the Ed25519 recipient keys of a service in the did doc
+	ServiceKeys(doc messages.DIDDocument, svc messages.Service) ([][]byte, error)
+	// RoutingKeys returns the Ed25519 routing keys of a service in the order of wrapping
+	RoutingKeys(doc messages.DIDDocument, svc messages.Service) ([][]byte, error)
 	CreatePeerDID(doc messages.DIDDocument) (did string, err error)
+	// CreatePeerDID0 creates a did:peer:0 from an Ed25519 inception key
+	CreatePeerDID0(pubKey []byte) (did string, err error)
+	// CreatePeerDID2 encodes the keys and services of the doc in the did
+	CreatePeerDID2(doc messages.DIDDocument) (did string, err error)
+	// ResolvePeerDID resolves did:peer:0 and did:peer:2
+	ResolvePeerDID(did string) (messages.DIDDocument, error)
 	ValidatePeerDID(did string) error
+	// CreateKeyDID creates a did:key from an Ed25519 or X25519 public key
+	CreateKeyDID(typ domain.KeyType, pubKey []byte) (did string, err error)
+	ResolveKeyDID(did string) (messages.DIDDocument, error)
+	// VerificationKey returns the Ed25519 authentication key of a resolved did doc
+	VerificationKey(doc messages.DIDDocument) ([]byte, error)
+}
+
+// DIDResolver resolves a did to its did doc where the recipient keys of
+// services are base64 encoded as in the did docs created by the agent
+type DIDResolver interface {
+	Resolve(did string) (messages.DIDDocument, error)
 }
 
 type Connector interface {
 	CreateConnReq(label, pthid, did string, encDidDoc messages.AuthCryptMsg) (messages.ConnReq, error)
-	ParseConnReq(data []byte) (label, exchThId, peerDid string, encDocBytes []byte, err error)
+	// ParseConnReq returns the id of the invitation which the request responds to
+	ParseConnReq(data []byte) (label, exchThId, invId, peerDid string, encDocBytes []byte, err error)
 	CreateConnRes(pthId, did string, encDidDoc messages.AuthCryptMsg) (messages.ConnRes, error)
-	ParseConnRes(data []byte) (exchThId string, encDocBytes []byte, err error)
+	ParseConnRes(data []byte) (exchThId, peerDid string, encDocBytes []byte, err error)
+	// CreateConnComplete acknowledges the response of the exchange (thId) initiated by the invitation (pthId)
+	CreateConnComplete(thId, pthId string) messages.ConnComplete
+	ParseConnComplete(data []byte) (thId, pthId string, err error)
+	CreateRotate(did string, didDoc messages.DIDDocument) (messages.Rotate, error)
+	ParseRotate(data []byte) (id, toDid string, docBytes []byte, err error)
+	CreateRotateAck(thId string) messages.RotateAck
 }
 
 type OutOfBand interface {
-	CreateInv(label, did string, didDoc messages.DIDDocument) (url string, err error)
+	// CreateInv encodes the invitation in a url along with the services of
+	// the did doc, where the invitation is processed without a connection if
+	// no handshake protocols are given
+	CreateInv(inv messages.Invitation, didDoc messages.DIDDocument) (url string, err error)
+	// ParseInv returns a nil key if the services of the invitation do not contain
+	// recipient keys, in which case the key should be derived from the inviter did
 	ParseInv(encInv string) (inv messages.Invitation, endpoint string, pubKey []byte, err error)
 }
 
@@ -44,13 +140,18 @@ type OutOfBand interface {
 // should only be understood as a reluctance to provide information.
 // eg: The missing roles in a response does not say, "I support no roles in this protocol."
 // It says, "I support the protocol but I'm providing no detail about specific roles."
-// see: https://github.com/hyperledger/aries-rfcs/tree/main/features/0031-discover-features#sparse-responses
+// see: https://github.com/hyperledger/aries-rfcs/tree/main/features/0557-discover-features-v2#sparse-responses
 //
 // Agent may use best practices to avoid fingerprinting.
-// see: https://github.com/hyperledger/aries-rfcs/tree/main/features/0031-discover-features#privacy-considerations
+// see: https://github.com/hyperledger/aries-rfcs/tree/main/features/0557-discover-features-v2#privacy-considerations
 type Discoverer interface {
-	Query(endpoint, query, comment string) (fs []models.Feature, err error)
-	Disclose(id, query string) messages.DiscloseFeature
+	// Query requests the features matching the queries from the peer, given by the
+	// label or the did of the connection, over the encrypted connection
+	Query(peer string, queries ...messages.FeatureQuery) (fs []models.Feature, err error)
+	Disclose(peer, thId string, queries []messages.FeatureQuery) messages.Disclosures
+	// SetPolicy restricts the features disclosed to the peer, or to all peers
+	// without a policy if the peer is '*', to the ones matching any of the globs
+	SetPolicy(peer string, globs ...string)
 }
 
 /* message queue functions */
@@ -58,10 +159,12 @@ type Discoverer interface {
 type GroupAgent interface {
 	Create(topic string, publisher bool, gp models.GroupParams) error
 	Join(topic, acceptor string, publisher bool) error
-	// Send returns the number of bytes transmitted as DIDComm messages per each member
+	// Send returns the number of bytes transmitted as DIDComm messages per each publish
 	Send(topic, msg string) (n []int, err error)
 	Leave(topic string) error
 	Info(topic string) (models.GroupParams, []models.Member)
+	// Roles returns the pub-sub roles of the agent in the groups it has joined
+	Roles() []string
 	// RegisterAck and UnregisterAck are used for registering a
 	// callback for group messages of a member
 	RegisterAck(label string, ackChan chan string)
@@ -2,13 +2,30 @@ package services
 
 import (
 	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"time"
 )
 
 /* dependencies */
 
 type Packer interface {
-	Pack(input []byte, recPubKey, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error)
-	Unpack(data, recPubKey, recPrvKey []byte) (output []byte, err error)
+	// Pack encrypts the message once and includes a recipient entry per key
+	Pack(input []byte, recPubKeys [][]byte, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error)
+	// PackAnon encrypts the message without revealing the sender (anoncrypt)
+	PackAnon(input []byte, recPubKeys [][]byte) (messages.AuthCryptMsg, error)
+	// Unpack selects the recipient entry by the key id of recPubKey and returns
+	// a nil sender public key if the message is anoncrypted
+	Unpack(data, recPubKey, recPrvKey []byte) (output, sendPubKey []byte, err error)
+	// RecipientKeys decodes the public keys of the recipients from their key ids
+	RecipientKeys(data []byte) ([][]byte, error)
+}
+
+// Signer creates and verifies signed messages (JWS) which can be
+// further packed to provide non-repudiation
+type Signer interface {
+	Sign(payload, pubKey, prvKey []byte) ([]byte, error)
+	// Verify returns the signed payload along with the verification key
+	Verify(data []byte) (payload, signerKey []byte, err error)
 }
 
 type Encryptor interface {
@@ -20,12 +37,86 @@ type Encryptor interface {
 	DecryptDetached(cipher, mac, protectedVal, nonce, key []byte) (msg []byte, err error)
 }
 
+// ConnectionStore persists the connections with peers and the own DIDs
+// created for each connection, both keyed by the label of the peer
+type ConnectionStore interface {
+	AddPeer(label string, pr models.Peer) error
+	Peer(label string) (models.Peer, error)
+	Peers() (map[string]models.Peer, error)
+	AddDID(label, did string, doc messages.DIDDocument) error
+	DID(label string) (did string, doc messages.DIDDocument, err error)
+	// AddInvRecord adds or replaces the record of an invitation created by the agent
+	AddInvRecord(rec models.InvRecord) error
+	InvRecord(id string) (models.InvRecord, error)
+	InvRecords() (map[string]models.InvRecord, error)
+	DeleteInvRecord(id string) error
+	// SaveMediation replaces the mediation granted to the agent
+	SaveMediation(m models.Mediation) error
+	Mediation() (models.Mediation, error)
+	// AddMediated records a connection which was granted mediation by the agent
+	AddMediated(label string) error
+	Mediated(label string) (bool, error)
+	// AddRoute adds or replaces the route of a base58 encoded recipient key
+	AddRoute(key string, rt models.Route) error
+	Route(key string) (models.Route, error)
+	Routes() (map[string]models.Route, error)
+	DeleteRoute(key string) error
+	// SaveQueue replaces the messages queued for the connection by the mediator
+	SaveQueue(label string, msgs []models.QueuedMsg) error
+	Queues() (map[string][]models.QueuedMsg, error)
+	Close() error
+}
+
+// GroupStore persists the state of the groups which the agent is a member of
+// such that it can rejoin them after a restart
+type GroupStore interface {
+	SaveGroup(topic string, g models.Group) error
+	Groups() (map[string]models.Group, error)
+	SaveSubscribers(topic string, subs map[string][]byte) error
+	Subscribers() (map[string]map[string][]byte, error)
+	SaveInvitation(topic, inv string) error
+	Invitations() (map[string]string, error)
+	// DeleteGroup removes all records of the topic
+	DeleteGroup(topic string) error
+}
+
 type KeyManager interface {
 	GenerateKeys(peer string) error
+	// RotateKeys generates a new key-pair for the peer and retains the
+	// previous key-pair until the grace period elapses
+	RotateKeys(peer string, grace time.Duration) error
+	// RestoreKeys reverts a rotation by reinstating the retired key-pair
+	// of the public key as the key-pair of the peer
+	RestoreKeys(peer string, pubKey []byte) error
+	// Peer also considers the retained keys of the peer
 	Peer(pubKey []byte) (name string, err error)
 	PublicKey(peer string) ([]byte, error)
 	PrivateKey(peer string) ([]byte, error)
-	GenerateInvKeys() error
-	InvPublicKey() []byte
-	InvPrivateKey() []byte
+	PrivateKeyByPubKey(pubKey []byte) ([]byte, error)
+	// GenerateInvKeys creates the key-pair of the invitation
+	GenerateInvKeys(invId string) error
+	InvPublicKey(invId string) ([]byte, error)
+	InvPrivateKey(invId string) ([]byte, error)
+	// Invitation returns the id of the invitation of the public key
+	Invitation(pubKey []byte) (invId string, err error)
+	RemoveInvKeys(invId string) error
+	// GenerateInviterKeys creates the long-lived key-pair which identifies
+	// the agent in its invitations if it does not exist already
+	GenerateInviterKeys() error
+	InviterPublicKey() []byte
+	// GenerateRoutingKeys creates the key-pair of the mediator role
+	// if it does not exist already
+	GenerateRoutingKeys() error
+	RoutingPublicKey() []byte
+	RoutingPrivateKey() []byte
+	// GenerateGroupKeys creates the sender key-pair used to pack group
+	// messages of the topic if it does not exist already
+	GenerateGroupKeys(topic string) error
+	GroupPublicKey(topic string) ([]byte, error)
+	GroupPrivateKey(topic string) ([]byte, error)
+	// GenerateSigningKeys creates an Ed25519 key-pair for the topic to
+	// sign group messages if it does not exist already
+	GenerateSigningKeys(topic string) error
+	SigningPublicKey(topic string) ([]byte, error)
+	SigningPrivateKey(topic string) ([]byte, error)
 }
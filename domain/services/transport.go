@@ -1,7 +1,9 @@
 package services
 
 import (
+	"errors"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"time"
 )
 
 /* client-server interfaces */
@@ -13,8 +15,12 @@ type Transporter interface {
 
 type Client interface {
 	// Send transmits the message but marshalling should be independent of the
-	// transport layer to support multiple encoding mechanisms
+	// transport layer to support multiple encoding mechanisms. Errors are marked
+	// by NotSent if the message has not left the agent.
 	Send(typ models.MsgType, data []byte, endpoint string) (res string, err error)
+	// SendWithTimeout waits for the reply for the given duration instead of
+	// the default timeout
+	SendWithTimeout(typ models.MsgType, data []byte, endpoint string, timeout time.Duration) (res string, err error)
 	Close() error
 }
 
@@ -25,6 +31,37 @@ type Server interface {
 	// Handlers with synchronous responses can be added by setting async
 	// flag to false and handling reply channel in models.Message
 	AddHandler(mt models.MsgType, notifier chan models.Message, async bool)
-	RemoveHandler(msgType string)
+	RemoveHandler(mt models.MsgType)
+	// Handlers returns the message types which currently have handlers
+	Handlers() []models.MsgType
 	Stop() error
 }
+
+// notSentErr indicates that the message has not left the agent and hence
+// has definitely not been delivered to the recipient
+type notSentErr struct {
+	err error
+}
+
+func (n *notSentErr) Error() string {
+	return n.err.Error()
+}
+
+func (n *notSentErr) Unwrap() error {
+	return n.err
+}
+
+// NotSent marks the error of a message which has not left the agent
+func NotSent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &notSentErr{err: err}
+}
+
+// Undelivered reports whether the error was caused before the message left
+// the agent, whereas the recipient may have processed the message otherwise
+func Undelivered(err error) bool {
+	var ns *notSentErr
+	return errors.As(err, &ns)
+}
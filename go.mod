@@ -10,6 +10,7 @@ require (
 	github.com/klauspost/compress v1.15.14
 	github.com/pebbe/zmq4 v1.2.9
 	github.com/tryfix/log v1.2.1
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
 )
 
@@ -1,33 +1,43 @@
 package prober
 
 import (
-	"encoding/base64"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
 	"github.com/YasiruR/didcomm-prober/domain/models"
 	"github.com/YasiruR/didcomm-prober/domain/services"
-	"github.com/btcsuite/btcutil/base58"
 	"github.com/google/uuid"
 	"github.com/tryfix/log"
+	"strings"
 	"sync"
 	"time"
 )
 
 type streams struct {
 	connReq, connRes, data chan models.Message
+	rotate, rotateAck      chan models.Message
+	connComp               chan models.Message
+	ping, pingRes          chan models.Message
+	forward, pickup        chan models.Message
+	mediate, reuse         chan models.Message
 }
 
 type Prober struct {
 	label           string
+	inviterDID      string // identifies the agent across its invitations
 	invEndpoint     string
 	exchEndpoint    string
 	grpJoinEndpoint string
 	ks              services.KeyManager
-	packer          services.Packer
+	packers         map[domain.Envelope]services.Packer
+	prefEnv         domain.Envelope
 	did             services.DIDUtils
+	resolver        services.DIDResolver
 	conn            services.Connector
 	oob             services.OutOfBand
 	peers           *peers
@@ -36,6 +46,11 @@ type Prober struct {
 	log             log.Logger
 	client          services.Client
 	syncCons        *sync.Map
+	pings           *pings
+	queue           *queue
+	mediation       *mediation
+	received        *received
+	invs            *invitations
 }
 
 func NewProber(c *container.Container) (p *Prober, err error) {
@@ -44,34 +59,106 @@ func NewProber(c *container.Container) (p *Prober, err error) {
 		exchEndpoint:    c.Cfg.InvEndpoint,
 		grpJoinEndpoint: c.Cfg.InvEndpoint,
 		ks:              c.KeyManager,
-		packer:          c.Packer,
+		packers:         c.Packers,
+		prefEnv:         c.Cfg.Envelope,
 		log:             c.Log,
 		did:             c.DidAgent,
+		resolver:        c.Resolver,
 		conn:            c.Connector,
 		oob:             c.OOB,
 		outChan:         c.OutChan,
 		label:           c.Cfg.Args.Name,
-		peers:           initPeerStore(c.Log),
-		didStore:        initDIDStore(),
+		didStore:        initDIDStore(c.ConnStore),
 		client:          c.Client,
 		syncCons:        &sync.Map{},
+		pings:           initPings(),
+		mediation:       initMediation(c.ConnStore),
+		received:        initReceived(),
+		invs:            initInvitations(c.ConnStore, c.KeyManager),
 	}
 
-	p.initHandlers(c.Server)
+	// records of expired invitations and of those whose keys were not restored are removed
+	if n, err := p.invs.prune(); err != nil {
+		p.log.Error(err)
+	} else if n != 0 {
+		p.log.Info(fmt.Sprintf(`removed %d expired or unusable invitation(s)`, n))
+	}
+
+	if p.queue, err = initQueue(c.ConnStore); err != nil {
+		return nil, err
+	}
+
+	if p.peers, err = initPeerStore(c.ConnStore, c.Log); err != nil {
+		return nil, err
+	}
+
+	prs := p.peers.all()
+
+	if len(prs) != 0 {
+		p.log.Info(fmt.Sprintf(`restored %d connection(s) from the store`, len(prs)))
+	}
+
+	// connections stored prior to exchange states were established once the services were known
+	for label, pr := range prs {
+		if pr.State == `` && len(pr.Services) != 0 {
+			pr.State = domain.ConnCompleted
+			if err = p.peers.add(label, pr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = p.ks.GenerateInviterKeys(); err != nil {
+		return nil, fmt.Errorf(`generating inviter keys failed - %v`, err)
+	}
+
+	if p.inviterDID, err = p.did.CreatePeerDID0(p.ks.InviterPublicKey()); err != nil {
+		return nil, fmt.Errorf(`creating inviter did failed - %v`, err)
+	}
+
+	if c.Cfg.Mediator {
+		if err = p.ks.GenerateRoutingKeys(); err != nil {
+			return nil, fmt.Errorf(`generating routing keys failed - %v`, err)
+		}
+	}
+
+	p.initHandlers(c.Server, c.Cfg.Mediator)
 	return p, nil
 }
 
-func (p *Prober) initHandlers(serv services.Server) {
+func (p *Prober) initHandlers(serv services.Server, mediator bool) {
 	// initializing message incoming streams for prober
 	s := &streams{
-		connReq: make(chan models.Message),
-		connRes: make(chan models.Message),
-		data:    make(chan models.Message),
+		connReq:   make(chan models.Message),
+		connRes:   make(chan models.Message),
+		data:      make(chan models.Message),
+		rotate:    make(chan models.Message),
+		rotateAck: make(chan models.Message),
+		connComp:  make(chan models.Message),
+		ping:      make(chan models.Message),
+		pingRes:   make(chan models.Message),
+		forward:   make(chan models.Message),
+		pickup:    make(chan models.Message),
+		mediate:   make(chan models.Message),
+		reuse:     make(chan models.Message),
 	}
 
-	serv.AddHandler(models.TypConnReq, s.connReq, true)
-	serv.AddHandler(models.TypConnRes, s.connRes, true)
-	serv.AddHandler(models.TypData, s.data, true)
+	// handlers are synchronous such that failures are replied with problem reports
+	// and hence should not block on messages sent to peers (bounded by the server)
+	serv.AddHandler(models.TypConnReq, s.connReq, false)
+	serv.AddHandler(models.TypConnRes, s.connRes, false)
+	serv.AddHandler(models.TypConnComplete, s.connComp, false)
+	serv.AddHandler(models.TypData, s.data, false)
+	serv.AddHandler(models.TypDIDRotate, s.rotate, false)
+	serv.AddHandler(models.TypDIDRotateAck, s.rotateAck, false)
+	serv.AddHandler(models.TypTrustPing, s.ping, false)
+	serv.AddHandler(models.TypTrustPingRes, s.pingRes, false)
+	serv.AddHandler(models.TypHandshakeReuse, s.reuse, false)
+	if mediator {
+		serv.AddHandler(models.TypForward, s.forward, false)
+		serv.AddHandler(models.TypPickup, s.pickup, false)
+		serv.AddHandler(models.TypMediation, s.mediate, false)
+	}
 	go p.listen(s)
 }
 
@@ -79,186 +166,407 @@ func (p *Prober) listen(s *streams) {
 	for {
 		select {
 		case m := <-s.connReq:
-			if err := p.processConnReq(m); err != nil {
-				p.log.Error(err)
-			}
+			p.reply(m, domain.ProblemReqNotAccepted, p.processConnReq(m))
 		case m := <-s.connRes:
-			if err := p.processConnRes(m); err != nil {
-				p.log.Error(err)
-			}
+			p.reply(m, domain.ProblemResNotAccepted, p.processConnRes(m))
+		case m := <-s.connComp:
+			p.reply(m, domain.ProblemCompNotAccepted, p.processConnComplete(m))
 		case m := <-s.data:
-			if _, _, err := p.ReadMessage(m); err != nil {
-				p.log.Error(err)
-			}
+			_, _, err := p.ReadMessage(m)
+			p.reply(m, domain.ProblemMsgProcessing, err)
+		case m := <-s.rotate:
+			p.reply(m, domain.ProblemMsgProcessing, p.processRotate(m))
+		case m := <-s.rotateAck:
+			p.reply(m, domain.ProblemMsgProcessing, p.processRotateAck(m))
+		case m := <-s.ping:
+			p.reply(m, domain.ProblemMsgProcessing, p.processPing(m))
+		case m := <-s.pingRes:
+			p.reply(m, domain.ProblemMsgProcessing, p.processPingResponse(m))
+		case m := <-s.forward:
+			// relays of requests with return route await the reply of the recipient
+			go p.respond(m, domain.ProblemNoRoute, p.processForward)
+		case m := <-s.pickup:
+			p.respond(m, domain.ProblemMsgProcessing, p.processPickup)
+		case m := <-s.mediate:
+			p.respond(m, domain.ProblemMsgProcessing, p.processMediation)
+		case m := <-s.reuse:
+			p.respond(m, domain.ProblemReuseNotAccepted, p.processReuse)
 		}
 	}
 }
 
+// Invite creates an invitation to establish connections via did-exchange
+// which neither expires nor is limited in the number of connections
 func (p *Prober) Invite() (url string, err error) {
-	if err = p.ks.GenerateInvKeys(); err != nil {
+	return p.InviteWith(models.InvOptions{})
+}
+
+// InviteWith creates an invitation to establish connections via did-exchange
+// which is valid until it expires or is used the maximum number of times
+func (p *Prober) InviteWith(opts models.InvOptions) (url string, err error) {
+	return p.invite([]string{messages.DIDExchangeV1}, nil, opts)
+}
+
+// invite creates a key-pair and a record for each invitation
+func (p *Prober) invite(handshakes []string, requests []messages.Attachment, opts models.InvOptions) (url string, err error) {
+	inv := messages.Invitation{
+		Message:            messages.NewMessage(messages.OOBInvitationV11),
+		Label:              p.label,
+		Goal:               opts.Goal,
+		HandshakeProtocols: handshakes,
+		Requests:           requests,
+	}
+
+	if len(handshakes) != 0 {
+		inv.GoalCode = goalRelBuild
+	}
+
+	rec := models.InvRecord{Id: inv.Id, Created: time.Now(), MaxUses: opts.MaxUses, Goal: opts.Goal, Handshake: len(handshakes) != 0}
+	if opts.Expiry > 0 {
+		rec.Expiry = rec.Created.Add(opts.Expiry)
+		inv.ExpiresIn(opts.Expiry)
+	}
+
+	// expired invitations are removed as new ones are created
+	if _, err = p.invs.prune(); err != nil {
+		p.log.Error(err)
+	}
+
+	if err = p.ks.GenerateInvKeys(inv.Id); err != nil {
 		return ``, fmt.Errorf(`generating invitation keys failed - %v`, err)
 	}
 
+	// omitted error since the keys were generated
+	invPubKey, _ := p.ks.InvPublicKey(inv.Id)
+
 	// creates a did doc for connection request with a separate endpoint and public key
 	invDidDoc := p.did.CreateDIDDoc([]models.Service{
-		{Id: uuid.New().String(), Type: domain.ServcDIDExchange, Endpoint: p.invEndpoint, PubKey: p.ks.InvPublicKey()},
+		{Id: uuid.New().String(), Type: domain.ServcDIDExchange, Endpoint: p.invEndpoint, PubKey: invPubKey, Accept: p.accepts()},
 	})
 
-	// but uses did created from default did doc as it serves as the identifier in invitation
-	url, err = p.oob.CreateInv(p.label, ``, invDidDoc) // todo null did
+	// did of the inviter is shared by all invitations such that invitees
+	// which are already connected reuse the connection
+	inv.From = p.inviterDID
+	url, err = p.oob.CreateInv(inv, p.did.AssignDID(invDidDoc, inv.From))
 	if err != nil {
 		return ``, fmt.Errorf(`creating invitation failed - %v`, err)
 	}
 
+	if err = p.invs.add(rec); err != nil {
+		return ``, err
+	}
+
 	return url, nil
 }
 
+// SyncAccept accepts the invitation and waits until the exchange with the
+// inviter is either completed or abandoned
 func (p *Prober) SyncAccept(encodedInv string) error {
-	inviter, err := p.Accept(encodedInv)
+	// registered prior to the request since the exchange may complete before the request returns
+	syncChan := make(chan bool, 1)
+	inviter, requested, err := p.accept(encodedInv, syncChan)
 	if err != nil {
-		return fmt.Errorf(`accepting invitation failed - %v`, err)
+		return fmt.Errorf(`accepting invitation failed - %w`, err)
 	}
 
-	// todo set a timeout for waiting
-	syncChan := make(chan bool)
-	p.syncCons.Store(inviter, syncChan)
-	<-syncChan
+	// connection is either reused or not requested by the invitation
+	if !requested {
+		return nil
+	}
 
-	return nil
+	select {
+	case completed := <-syncChan:
+		if !completed {
+			return fmt.Errorf(`connection exchange with %s was abandoned`, inviter)
+		}
+		return nil
+	case <-time.After(domain.SendTimeoutMs * time.Millisecond):
+		p.syncCons.Delete(inviter)
+		return fmt.Errorf(`connection exchange with %s was not completed within %dms`, inviter, domain.SendTimeoutMs)
+	}
 }
 
-// Accept creates a connection request and sends it to the invitation endpoint
+// Accept processes the requests attached to the invitation and either reuses
+// the connection with the inviter or sends a connection request
 func (p *Prober) Accept(encodedInv string) (sender string, err error) {
+	sender, _, err = p.accept(encodedInv, nil)
+	return sender, err
+}
+
+// accept returns true if a new connection has been requested with the inviter,
+// in which case the outcome of the exchange is sent to syncChan if provided
+func (p *Prober) accept(encodedInv string, syncChan chan bool) (sender string, requested bool, err error) {
 	inv, invEndpoint, peerInvPubKey, err := p.oob.ParseInv(encodedInv)
 	if err != nil {
-		return ``, fmt.Errorf(`parsing invitation failed - %v`, err)
+		return ``, false, fmt.Errorf(`parsing invitation failed - %v`, err)
+	}
+
+	peerInvPubKey, err = p.invKey(inv.From, peerInvPubKey)
+	if err != nil {
+		return ``, false, fmt.Errorf(`invalid invitation key - %v`, err)
+	}
+
+	env := p.envelope(inv.Accept)
+	if err = p.processInvRequests(inv, env, invEndpoint, peerInvPubKey); err != nil {
+		return ``, false, fmt.Errorf(`processing requests of the invitation failed - %v`, err)
+	}
+
+	if len(inv.HandshakeProtocols) == 0 {
+		return inv.Label, false, nil
+	}
+
+	if !supportsHandshake(inv.HandshakeProtocols) {
+		return ``, false, fmt.Errorf(`none of the handshake protocols is supported (%v)`, inv.HandshakeProtocols)
+	}
+
+	if p.reuse(inv) {
+		return inv.Label, false, nil
+	}
+
+	if syncChan != nil {
+		p.syncCons.Store(inv.Label, syncChan)
+	}
+
+	if err = p.requestConn(inv, env, invEndpoint, peerInvPubKey); err != nil {
+		p.syncCons.Delete(inv.Label)
+		return ``, false, err
+	}
+
+	return inv.Label, true, nil
+}
+
+// requestConn creates a connection request and sends it to the invitation endpoint
+func (p *Prober) requestConn(inv messages.Invitation, env domain.Envelope, invEndpoint string, peerInvPubKey []byte) error {
+	pr := models.Peer{DID: inv.From, InvId: inv.Id, InvDID: inv.From, Envelope: env, State: domain.ConnInvited}
+	if err := p.peers.add(inv.Label, pr); err != nil {
+		return err
 	}
 
 	// set up prerequisites for a connection (diddoc, did, keys)
 	pubKey, prvKey, err := p.setConnPrereqs(inv.Label)
 	if err != nil {
-		return ``, fmt.Errorf(`setting up prerequisites for connection with %s failed - %v`, inv.Label, err)
+		return fmt.Errorf(`setting up prerequisites for connection with %s failed - %v`, inv.Label, err)
 	}
 
 	did, doc, err := p.didStore.get(inv.Label)
 	if err != nil {
-		return ``, fmt.Errorf(`fetching dids failed - %v`, err)
+		return fmt.Errorf(`fetching dids failed - %v`, err)
 	}
 
 	// marshals did doc to proceed with packing process
 	docBytes, err := json.Marshal(doc)
 	if err != nil {
-		return ``, fmt.Errorf(`marshalling did doc failed - %v`, err)
+		return fmt.Errorf(`marshalling did doc failed - %v`, err)
 	}
 
 	// encrypts did doc with peer invitation public key and default own key pair
-	encDoc, err := p.packer.Pack(docBytes, peerInvPubKey, pubKey, prvKey)
+	encDoc, err := p.pack(env, docBytes, peerInvPubKey, pubKey, prvKey)
 	if err != nil {
-		return ``, fmt.Errorf(`encrypting did doc failed - %v`, err)
+		return fmt.Errorf(`encrypting did doc failed - %v`, err)
 	}
 
 	// todo check how concurrent conn requests go along (since same invitation and hence pthid)
 	// creates connection request
 	connReq, err := p.conn.CreateConnReq(p.label, inv.Id, did, encDoc)
 	if err != nil {
-		return ``, fmt.Errorf(`creating connection request failed - %v`, err)
+		return fmt.Errorf(`creating connection request failed - %v`, err)
 	}
 
 	// marshals connection request
 	connReqBytes, err := json.Marshal(connReq)
 	if err != nil {
-		return ``, fmt.Errorf(`marshalling connection request failed - %v`, err)
+		return fmt.Errorf(`marshalling connection request failed - %v`, err)
+	}
+
+	// stored prior to sending since the response may arrive before the request returns
+	pr.ExchangeThId, pr.State = connReq.ThId(), domain.ConnRequested
+	if err = p.peers.add(inv.Label, pr); err != nil {
+		return err
+	}
+
+	if err = p.dispatch(models.TypConnReq, connReqBytes, invEndpoint); err != nil {
+		p.abandon(inv.Label, pr)
+		return fmt.Errorf(`sending connection request failed - %w`, err)
+	}
+
+	return nil
+}
+
+// invKey derives the invitation key from the did:key of the inviter and
+// checks that it matches the recipient key of the service if provided
+func (p *Prober) invKey(from string, svcKey []byte) ([]byte, error) {
+	if !strings.HasPrefix(from, `did:key:`) {
+		if svcKey == nil {
+			return nil, errors.New(`no recipient key found in the invitation`)
+		}
+		return svcKey, nil
+	}
+
+	doc, err := p.did.ResolveKeyDID(from)
+	if err != nil {
+		return nil, fmt.Errorf(`resolving inviter did failed - %v`, err)
 	}
 
-	if _, err = p.client.Send(models.TypConnReq, connReqBytes, invEndpoint); err != nil {
-		return ``, fmt.Errorf(`sending connection request failed - %v`, err)
+	key, err := p.did.VerificationKey(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if svcKey != nil && !bytes.Equal(key, svcKey) {
+		return nil, fmt.Errorf(`recipient key does not match the inviter did (%s)`, from)
 	}
 
-	p.peers.add(inv.Label, models.Peer{DID: inv.From, ExchangeThId: connReq.Thread.ThId})
-	return inv.Label, nil
+	return key, nil
 }
 
 // processConnReq parses the connection request, creates a connection response and sends it to did endpoint
 func (p *Prober) processConnReq(msg models.Message) error {
-	peerLabel, exchId, peerDid, peerEncDocBytes, err := p.conn.ParseConnReq(msg.Data)
+	peerLabel, exchId, invId, peerDid, peerEncDocBytes, err := p.conn.ParseConnReq(msg.Data)
 	if err != nil {
 		return fmt.Errorf(`parsing connection request failed - %v`, err)
 	}
 
-	// decrypts peer did doc which is encrypted with invitation keys
-	svcs, err := p.getPeerInfo(peerEncDocBytes, p.ks.InvPublicKey(), p.ks.InvPrivateKey())
+	if err = p.did.ValidatePeerDID(peerDid); err != nil {
+		return problem.WithThread(exchId, fmt.Errorf(`invalid did of %s - %v`, peerLabel, err))
+	}
+
+	// requests of expired, exhausted or revoked invitations are rejected early
+	if err = p.invs.valid(invId, exchId); err != nil {
+		return problem.WithThread(exchId, fmt.Errorf(`invitation of the request by %s is not valid - %v`, peerLabel, err))
+	}
+
+	// keys of persisted invitations are lost after a restart unless the wallet is enabled
+	invPubKey, err := p.ks.InvPublicKey(invId)
 	if err != nil {
-		return fmt.Errorf(`getting peer data failed - %v`, err)
+		return problem.WithThread(exchId, fmt.Errorf(`getting invitation keys failed - %v`, err))
 	}
+	invPrvKey, _ := p.ks.InvPrivateKey(invId)
 
-	prMsgEndpnt, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, svcs)
+	// did doc attachment must be encrypted to the invitation referred by the parent thread
+	if len(peerEncDocBytes) != 0 {
+		if _, recKey, _, err := p.keysByMsg(peerEncDocBytes); err != nil || !bytes.Equal(recKey, invPubKey) {
+			return problem.WithThread(exchId, fmt.Errorf(`request by %s is not encrypted to the key of invitation %s`, peerLabel, invId))
+		}
+	}
+
+	// falls back to the peer did doc encrypted with invitation keys
+	svcs, err := p.peerServices(peerDid, peerEncDocBytes, invPubKey, invPrvKey)
 	if err != nil {
-		return fmt.Errorf(`getting message endpoint failed - %v`, err)
+		return problem.WithThread(exchId, fmt.Errorf(`getting peer data failed - %v`, err))
+	}
+
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, svcs)
+	if err != nil {
+		return problem.WithThread(exchId, fmt.Errorf(`getting message endpoint failed - %v`, err))
+	}
+
+	env := p.envelope(p.acceptByServc(domain.ServcMessage, svcs))
+	pr := models.Peer{DID: peerDid, Services: svcs, ExchangeThId: exchId, Envelope: env, State: domain.ConnResponded}
+
+	// use is reserved before the request is acknowledged such that only one
+	// of the concurrent requests for the last use of an invitation succeeds
+	if err = p.invs.use(invId, exchId); err != nil {
+		return problem.WithThread(exchId, fmt.Errorf(`invitation of the request by %s is not valid - %v`, peerLabel, err))
+	}
+
+	// response is created and sent once the request is acknowledged since the
+	// requester completes the exchange while processing the response, and the
+	// key registration with a mediator should not block the server
+	go p.respondConnReq(peerLabel, invId, pr, prMsgPubKy)
+	return nil
+}
+
+// respondConnReq sets up the connection and sends the response where the
+// use of the invitation is reverted if the response could not be sent
+func (p *Prober) respondConnReq(peer, invId string, pr models.Peer, peerPubKey []byte) {
+	connResBytes, err := p.createConnRes(peer, pr, peerPubKey)
+	if err != nil {
+		p.releaseInv(invId, pr.ExchangeThId)
+		p.log.Error(fmt.Sprintf(`responding to the connection request of %s failed - %v`, peer, err))
+		return
+	}
+
+	// the requester may send the complete message as soon as the response is received
+	if err = p.peers.add(peer, pr); err != nil {
+		p.releaseInv(invId, pr.ExchangeThId)
+		p.log.Error(err)
+		return
+	}
+
+	if err = p.forward(pr.Envelope, models.TypConnRes, connResBytes, pr.Services); err != nil {
+		p.abandon(peer, pr)
+		p.releaseInv(invId, pr.ExchangeThId)
+		p.log.Error(fmt.Sprintf(`sending connection response to %s failed - %v`, peer, err))
+		return
 	}
 
+	p.log.Trace(fmt.Sprintf(`connection response sent to %s`, peer))
+}
+
+func (p *Prober) createConnRes(peer string, pr models.Peer, peerPubKey []byte) ([]byte, error) {
 	// set up prerequisites for a connection (diddoc, did, keys)
-	pubKey, prvKey, err := p.setConnPrereqs(peerLabel)
+	pubKey, prvKey, err := p.setConnPrereqs(peer)
 	if err != nil {
-		return fmt.Errorf(`setting up prerequisites for connection with %s failed - %v`, peerLabel, err)
+		return nil, fmt.Errorf(`setting up prerequisites for connection with %s failed - %v`, peer, err)
 	}
 
-	did, doc, err := p.didStore.get(peerLabel)
+	did, doc, err := p.didStore.get(peer)
 	if err != nil {
-		return fmt.Errorf(`fetching did-doc failed - %v`, err)
+		return nil, fmt.Errorf(`fetching did-doc failed - %v`, err)
 	}
 
 	// marshals own did doc to proceed with packing process
 	docBytes, err := json.Marshal(doc)
 	if err != nil {
-		return fmt.Errorf(`marshalling did doc failed - %v`, err)
+		return nil, fmt.Errorf(`marshalling did doc failed - %v`, err)
 	}
 
 	// encrypts did doc with peer invitation public key and default own key pair
-	encDidDoc, err := p.packer.Pack(docBytes, prMsgPubKy, pubKey, prvKey)
+	encDidDoc, err := p.pack(pr.Envelope, docBytes, peerPubKey, pubKey, prvKey)
 	if err != nil {
-		return fmt.Errorf(`encrypting did doc failed - %v`, err)
+		return nil, fmt.Errorf(`encrypting did doc failed - %v`, err)
 	}
 
-	connRes, err := p.conn.CreateConnRes(exchId, did, encDidDoc)
+	connRes, err := p.conn.CreateConnRes(pr.ExchangeThId, did, encDidDoc)
 	if err != nil {
-		return fmt.Errorf(`creating connection response failed - %v`, err)
+		return nil, fmt.Errorf(`creating connection response failed - %v`, err)
 	}
 
 	connResBytes, err := json.Marshal(connRes)
 	if err != nil {
-		return fmt.Errorf(`marshalling connection response failed - %v`, err)
+		return nil, fmt.Errorf(`marshalling connection response failed - %v`, err)
 	}
 
-	if _, err = p.client.Send(models.TypConnRes, connResBytes, prMsgEndpnt); err != nil {
-		return fmt.Errorf(`sending connection response failed - %v`, err)
-	}
-
-	p.peers.add(peerLabel, models.Peer{DID: peerDid, Services: svcs, ExchangeThId: exchId})
-	p.outChan <- `Connection established with ` + peerLabel
+	return connResBytes, nil
+}
 
-	return nil
+func (p *Prober) releaseInv(invId, exchId string) {
+	if err := p.invs.release(invId, exchId); err != nil {
+		p.log.Error(fmt.Sprintf(`reverting use of invitation %s failed - %v`, invId, err))
+	}
 }
 
 func (p *Prober) processConnRes(msg models.Message) error {
-	pthId, peerEncDocBytes, err := p.conn.ParseConnRes(msg.Data)
+	pthId, peerDid, peerEncDocBytes, err := p.conn.ParseConnRes(msg.Data)
 	if err != nil {
 		return fmt.Errorf(`parsing connection request failed - %v`, err)
 	}
 
-	// todo send complete message
-
-	var retryCount int
-retry:
+	// peer is stored prior to sending the request and hence is not awaited
 	name, pr, ok := p.peers.peerByExchId(pthId)
 	if !ok {
-		if retryCount != domain.RetryCount {
-			retryCount++
-			time.Sleep(domain.RetryIntervalMs * time.Millisecond)
-			goto retry
-		}
 		return fmt.Errorf(`peer does not exist for exchange id %s`, pthId)
 	}
 
+	if pr.State != domain.ConnRequested {
+		return problem.WithThread(pthId, fmt.Errorf(`unexpected connection response from %s in %s state`, name, pr.State))
+	}
+
+	if err = p.did.ValidatePeerDID(peerDid); err != nil {
+		p.abandon(name, pr)
+		return problem.WithThread(pthId, fmt.Errorf(`invalid did in connection response - %v`, err))
+	}
+
 	ownPubKey, err := p.ks.PublicKey(name)
 	if err != nil {
 		return fmt.Errorf(`getting public key for connection with %s failed - %v`, name, err)
@@ -269,28 +577,163 @@ retry:
 		return fmt.Errorf(`getting private key for connection with %s failed - %v`, name, err)
 	}
 
-	// decrypts peer did doc which is encrypted with default keys
-	svcs, err := p.getPeerInfo(peerEncDocBytes, ownPubKey, ownPrvKey)
+	// falls back to the peer did doc encrypted with default keys
+	svcs, err := p.peerServices(peerDid, peerEncDocBytes, ownPubKey, ownPrvKey)
 	if err != nil {
-		return fmt.Errorf(`getting peer data failed - %v`, err)
+		p.abandon(name, pr)
+		return problem.WithThread(pthId, fmt.Errorf(`getting peer data failed - %v`, err))
 	}
 
-	p.peers.add(name, models.Peer{DID: pr.DID, Services: svcs, ExchangeThId: pthId})
-	val, ok := p.syncCons.Load(name)
-	if ok {
-		syncChan, ok := val.(chan bool)
-		if !ok {
-			return fmt.Errorf(`incompatible type for sync channel (%v)`, val)
-		}
-		syncChan <- true
+	// did of the connection replaces the did in invitation
+	pr.DID, pr.Services, pr.State = peerDid, svcs, domain.ConnResponded
+	pr.Envelope = p.envelope(p.acceptByServc(domain.ServcMessage, svcs))
+	if err = p.peers.add(name, pr); err != nil {
+		return err
 	}
 
+	if err = p.sendComplete(name, pr); err != nil {
+		p.abandon(name, pr)
+		return problem.WithThread(pthId, fmt.Errorf(`completing exchange with %s failed - %v`, name, err))
+	}
+
+	pr.State = domain.ConnCompleted
+	if err = p.peers.add(name, pr); err != nil {
+		return err
+	}
+
+	p.notifySync(name, true)
 	p.outChan <- `Connection established with ` + name
 	return nil
 }
 
+// sendComplete acknowledges the response with a complete message packed
+// with the keys of the connection
+func (p *Prober) sendComplete(name string, pr models.Peer) error {
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return fmt.Errorf(`getting message endpoint failed - %v`, err)
+	}
+
+	ownPubKey, err := p.ks.PublicKey(name)
+	if err != nil {
+		return fmt.Errorf(`getting public key for connection with %s failed - %v`, name, err)
+	}
+
+	ownPrvKey, err := p.ks.PrivateKey(name)
+	if err != nil {
+		return fmt.Errorf(`getting private key for connection with %s failed - %v`, name, err)
+	}
+
+	compBytes, err := json.Marshal(p.conn.CreateConnComplete(pr.ExchangeThId, pr.InvId))
+	if err != nil {
+		return fmt.Errorf(`marshalling complete message failed - %v`, err)
+	}
+
+	msg, err := p.pack(pr.Envelope, compBytes, prMsgPubKy, ownPubKey, ownPrvKey)
+	if err != nil {
+		return fmt.Errorf(`packing complete message failed - %v`, err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf(`marshalling didcomm message failed - %v`, err)
+	}
+
+	if err = p.forward(pr.Envelope, models.TypConnComplete, data, pr.Services); err != nil {
+		return fmt.Errorf(`sending complete message failed - %w`, err)
+	}
+
+	return nil
+}
+
+// processConnComplete completes the exchange if the complete message is
+// authenticated with the key of the requester and refers to the exchange
+func (p *Prober) processConnComplete(msg models.Message) error {
+	peerName, ownPubKey, ownPrvKey, err := p.keysByMsg(msg.Data)
+	if err != nil {
+		return fmt.Errorf(`getting peer info failed - %v`, err)
+	}
+
+	body, sendPubKey, err := p.unpack(msg.Data, ownPubKey, ownPrvKey)
+	if err != nil {
+		return fmt.Errorf(`unpacking complete message failed - %v`, err)
+	}
+
+	pr, err := p.peers.peerByLabel(peerName)
+	if err != nil {
+		return fmt.Errorf(`no didcomm connection found for %s - %v`, peerName, err)
+	}
+
+	// reports refer to the exchange even if the message can not be parsed
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return problem.WithThread(pr.ExchangeThId, fmt.Errorf(`getting message service of %s failed - %v`, peerName, err))
+	}
+
+	if sendPubKey == nil || !bytes.Equal(sendPubKey, prMsgPubKy) {
+		return problem.WithThread(pr.ExchangeThId, fmt.Errorf(`complete message is not authenticated with the key of %s`, peerName))
+	}
+
+	thId, _, err := p.conn.ParseConnComplete(body)
+	if err != nil {
+		return problem.WithThread(pr.ExchangeThId, fmt.Errorf(`parsing complete message failed - %v`, err))
+	}
+
+	if thId != pr.ExchangeThId {
+		return problem.WithThread(thId, fmt.Errorf(`complete message of %s does not belong to the exchange (%s)`, peerName, thId))
+	}
+
+	if pr.State != domain.ConnResponded {
+		return problem.WithThread(thId, fmt.Errorf(`unexpected complete message from %s in %s state`, peerName, pr.State))
+	}
+
+	pr.State = domain.ConnCompleted
+	if err = p.peers.add(peerName, pr); err != nil {
+		return err
+	}
+
+	p.outChan <- `Connection established with ` + peerName
+	return nil
+}
+
+// abandon marks the exchange with the peer as failed
+func (p *Prober) abandon(name string, pr models.Peer) {
+	pr.State = domain.ConnAbandoned
+	if err := p.peers.add(name, pr); err != nil {
+		p.log.Error(err)
+	}
+	p.notifySync(name, false)
+}
+
+// notifySync releases the caller of SyncAccept awaiting the exchange with
+// the peer, if any, where the channel is buffered and used only once
+func (p *Prober) notifySync(name string, completed bool) {
+	val, ok := p.syncCons.LoadAndDelete(name)
+	if !ok {
+		return
+	}
+
+	syncChan, ok := val.(chan bool)
+	if !ok {
+		p.log.Error(fmt.Sprintf(`incompatible type for sync channel (%v)`, val))
+		return
+	}
+	syncChan <- completed
+}
+
+// peerServices derives the services from the did of the peer if it can be
+// resolved (eg: did:peer:2) and otherwise from the encrypted did doc attachment
+func (p *Prober) peerServices(did string, encDocBytes, recPubKey, recPrvKey []byte) ([]models.Service, error) {
+	doc, err := p.resolver.Resolve(did)
+	if err == nil {
+		return p.servicesByDoc(doc)
+	}
+
+	return p.getPeerInfo(encDocBytes, recPubKey, recPrvKey)
+}
+
 func (p *Prober) getPeerInfo(encDocBytes, recPubKey, recPrvKey []byte) (svcs []models.Service, err error) {
-	peerDocBytes, err := p.packer.Unpack(encDocBytes, recPubKey, recPrvKey)
+	peerDocBytes, _, err := p.unpack(encDocBytes, recPubKey, recPrvKey)
 	if err != nil {
 		return nil, fmt.Errorf(`decrypting did doc failed - %v`, err)
 	}
@@ -301,31 +744,52 @@ func (p *Prober) getPeerInfo(encDocBytes, recPubKey, recPrvKey []byte) (svcs []m
 		return nil, fmt.Errorf(`unmarshalling decrypted did doc failed - %v`, err)
 	}
 
+	return p.servicesByDoc(peerDidDoc)
+}
+
+// servicesByDoc parses the services of a did doc along with their keys
+func (p *Prober) servicesByDoc(peerDidDoc messages.DIDDocument) (svcs []models.Service, err error) {
 	if len(peerDidDoc.Service) == 0 {
 		return nil, fmt.Errorf(`did doc does not contain a service`)
 	}
 
 	for _, s := range peerDidDoc.Service {
-		if len(s.RecipientKeys) == 0 {
+		// keys may be references to verification methods or base64 encoded as in earlier did docs
+		keys, err := p.did.ServiceKeys(peerDidDoc, s)
+		if err != nil {
+			p.log.Error(fmt.Sprintf(`decoding recipient keys failed for service (%s) - %v`, s.Type, err))
+			continue
+		}
+
+		if len(keys) == 0 {
 			p.log.Error(fmt.Sprintf(`did doc does not contain recipient keys for the service (%s)`, s.Type))
 			continue
 		}
 
-		for _, rk := range s.RecipientKeys {
-			peerPubKey, err := base64.StdEncoding.DecodeString(rk) // assumes the first eligible key-pair works fine for POC
-			if err != nil {
-				p.log.Error(fmt.Sprintf(`decoding recipient key failed for service (%s) - %v`, s.Type, err))
-				continue
-			}
-			svcs = append(svcs, models.Service{Id: s.Id, Type: s.Type, Endpoint: s.ServiceEndpoint, PubKey: peerPubKey})
-			break
+		routingKeys, err := p.did.RoutingKeys(peerDidDoc, s)
+		if err != nil {
+			p.log.Error(fmt.Sprintf(`decoding routing keys failed for service (%s) - %v`, s.Type, err))
+			continue
 		}
+
+		// assumes the first eligible key-pair works fine for POC
+		svcs = append(svcs, models.Service{Id: s.Id, Type: s.Type, Endpoint: s.ServiceEndpoint, PubKey: keys[0], Accept: s.Accept, RoutingKeys: routingKeys})
 	}
 
 	return svcs, nil
 }
 
 func (p *Prober) SendMessage(mt models.MsgType, to, text string) error {
+	return p.send(mt, to, text, false)
+}
+
+// SendAnonMessage does not include the sender key in the envelope and hence
+// the message can not be traced back to the connection by the recipient
+func (p *Prober) SendAnonMessage(mt models.MsgType, to, text string) error {
+	return p.send(mt, to, text, true)
+}
+
+func (p *Prober) send(mt models.MsgType, to, text string, anon bool) error {
 	peer, err := p.peers.peerByLabel(to)
 	if err != nil {
 		return fmt.Errorf(`no didcomm connection found for the recipient %s - %v`, to, err)
@@ -341,12 +805,25 @@ func (p *Prober) SendMessage(mt models.MsgType, to, text string) error {
 		return fmt.Errorf(`getting private key for connection with %s failed - %v`, to, err)
 	}
 
-	prMsgEndpnt, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, peer.Services)
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, peer.Services)
 	if err != nil {
 		return fmt.Errorf(`getting message endpoint failed - %v`, err)
 	}
 
-	msg, err := p.packer.Pack([]byte(text), prMsgPubKy, ownPubKey, ownPrvKey)
+	body := []byte(text)
+	if mt == models.TypData {
+		if body, err = basicMessage(text); err != nil {
+			return err
+		}
+	}
+
+	var msg messages.AuthCryptMsg
+	if anon {
+		msg, err = p.packAnon(peer.Envelope, body, prMsgPubKy)
+	} else {
+		msg, err = p.pack(peer.Envelope, body, prMsgPubKy, ownPubKey, ownPrvKey)
+	}
+
 	if err != nil {
 		return fmt.Errorf(`packing message failed - %v`, err)
 	}
@@ -356,8 +833,8 @@ func (p *Prober) SendMessage(mt models.MsgType, to, text string) error {
 		return fmt.Errorf(`marshalling didcomm message failed - %v`, err)
 	}
 
-	if _, err = p.client.Send(mt, data, prMsgEndpnt); err != nil {
-		return fmt.Errorf(`sending didcomm message failed - %v`, err)
+	if err = p.forward(peer.Envelope, mt, data, peer.Services); err != nil {
+		return fmt.Errorf(`sending didcomm message failed - %w`, err)
 	}
 
 	if mt == models.TypData {
@@ -369,37 +846,100 @@ func (p *Prober) SendMessage(mt models.MsgType, to, text string) error {
 	return nil
 }
 
+// ReadMessage returns an empty sender if the message is anoncrypted
 func (p *Prober) ReadMessage(msg models.Message) (sender, text string, err error) {
-	peerName, err := p.peerByMsg(msg.Data)
+	return p.read(msg, p.authenticate)
+}
+
+// ReadGroupMessage reads a group message which is packed with the key pair of
+// the topic instead of the connection. Sender key is authenticated by authFunc
+// against the key advertised by the publisher in the group.
+func (p *Prober) ReadGroupMessage(msg models.Message, authFunc func(sender string, sendPubKey []byte) error) (sender, text string, err error) {
+	msg.Type = models.TypGroupMsg
+	return p.read(msg, authFunc)
+}
+
+// read unpacks the message and authenticates the sender key by authFunc
+// if the message is authcrypted
+func (p *Prober) read(msg models.Message, authFunc func(peerName string, sendPubKey []byte) error) (sender, text string, err error) {
+	peerName, ownPubKey, ownPrvKey, err := p.keysByMsg(msg.Data)
 	if err != nil {
 		//p.log.Debug(fmt.Sprintf(`getting peer info failed - %v`, err))
 		return ``, ``, fmt.Errorf(`getting peer info failed - %v`, err)
 	}
 
-	ownPubKey, err := p.ks.PublicKey(peerName)
+	textBytes, sendPubKey, err := p.unpack(msg.Data, ownPubKey, ownPrvKey)
 	if err != nil {
-		return ``, ``, fmt.Errorf(`getting public key for connection with %s failed - %v`, peerName, err)
+		return ``, ``, fmt.Errorf(`unpacking message failed - %v`, err)
 	}
 
-	ownPrvKey, err := p.ks.PrivateKey(peerName)
-	if err != nil {
-		return ``, ``, fmt.Errorf(`getting private key for connection with %s failed - %v`, peerName, err)
+	// replies to synchronous messages may be problem reports
+	if err = problem.Parse(textBytes); err != nil {
+		return peerName, ``, err
 	}
 
-	textBytes, err := p.packer.Unpack(msg.Data, ownPubKey, ownPrvKey)
-	if err != nil {
-		return ``, ``, fmt.Errorf(`unpacking message failed - %v`, err)
+	// header is omitted for bodies which are not plaintext messages (eg: raw text)
+	var hdr messages.Message
+	if json.Unmarshal(textBytes, &hdr) == nil && hdr.Expired() {
+		return peerName, ``, problem.WithThread(hdr.ThId(), fmt.Errorf(`'%s' message has expired`, hdr.Type))
+	}
+
+	// sender can not be identified even if the message was received via a connection key
+	if sendPubKey == nil {
+		peerName = ``
 	}
 
+	// publishers can not be identified by anoncrypted group messages
+	if peerName == `` && msg.Type == models.TypGroupMsg {
+		return ``, ``, fmt.Errorf(`group message is not authcrypted by the publisher`)
+	}
+
+	if peerName != `` {
+		if err = authFunc(peerName, sendPubKey); err != nil {
+			return ``, ``, err
+		}
+	}
+
+	// messages may be delivered both directly and via the queue of a mediator
+	if hdr.Id != `` && !p.received.first(hdr.Id) {
+		return peerName, ``, errDuplicate
+	}
+
+	// direct messages are basic messages unless sent by peers which send raw text
 	if msg.Type == models.TypData {
-		p.outChan <- `Message received: '` + string(textBytes) + `'`
-	} else {
-		p.log.Trace(fmt.Sprintf(`message received for type '%s' by %s - %s`, msg.Type, peerName, string(textBytes)))
+		content, out := readBasicMessage(peerName, textBytes)
+		p.outChan <- out
+		return peerName, content, nil
+	}
+
+	if sendPubKey == nil {
+		p.log.Trace(fmt.Sprintf(`anonymous message received for type '%s' - %s`, msg.Type, string(textBytes)))
+		return ``, string(textBytes), nil
 	}
 
+	p.log.Trace(fmt.Sprintf(`message received for type '%s' by %s - %s`, msg.Type, peerName, string(textBytes)))
 	return peerName, string(textBytes), nil
 }
 
+// authenticate checks if the message is packed with the current key of the
+// message service of the peer
+func (p *Prober) authenticate(peerName string, sendPubKey []byte) error {
+	pr, err := p.peers.peerByLabel(peerName)
+	if err != nil {
+		return fmt.Errorf(`no connection found for %s - %v`, peerName, err)
+	}
+
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return fmt.Errorf(`fetching message service of %s failed - %v`, peerName, err)
+	}
+
+	if !bytes.Equal(sendPubKey, prMsgPubKy) {
+		return fmt.Errorf(`message is not authenticated with the current key of %s`, peerName)
+	}
+	return nil
+}
+
 func (p *Prober) setConnPrereqs(peer string) (pubKey, prvKey []byte, err error) {
 	if err = p.ks.GenerateKeys(peer); err != nil {
 		return nil, nil, fmt.Errorf(`generating keys failed - %v`, err)
@@ -409,44 +949,75 @@ func (p *Prober) setConnPrereqs(peer string) (pubKey, prvKey []byte, err error)
 	pubKey, _ = p.ks.PublicKey(peer)
 	prvKey, _ = p.ks.PrivateKey(peer)
 
-	// creating own did and did doc
+	if err = p.createDID(peer, pubKey); err != nil {
+		return nil, nil, err
+	}
+
+	return pubKey, prvKey, nil
+}
+
+// createDID creates own did and did doc for the connection with peer. If a
+// mediator is granted, the key is registered with it and the message service
+// is routed through the mediator except for the connection with the mediator.
+func (p *Prober) createDID(peer string, pubKey []byte) error {
+	msgSvc := models.Service{Id: uuid.New().String(), Type: domain.ServcMessage, Endpoint: p.exchEndpoint, PubKey: pubKey, Accept: p.accepts()}
+	if mediator, endpoint, routingKeys, ok := p.mediation.get(); ok && mediator != peer {
+		if err := p.updateKeylist(mediator, pubKey, messages.KeylistAdd); err != nil {
+			return fmt.Errorf(`registering key with the mediator %s failed - %v`, mediator, err)
+		}
+		msgSvc.Endpoint, msgSvc.RoutingKeys = endpoint, routingKeys
+	}
+
 	didDoc := p.did.CreateDIDDoc([]models.Service{
-		{Id: uuid.New().String(), Type: domain.ServcMessage, Endpoint: p.exchEndpoint, PubKey: pubKey},
-		{Id: uuid.New().String(), Type: domain.ServcGroupJoin, Endpoint: p.grpJoinEndpoint, PubKey: pubKey},
+		msgSvc,
+		{Id: uuid.New().String(), Type: domain.ServcGroupJoin, Endpoint: p.grpJoinEndpoint, PubKey: pubKey, Accept: p.accepts()},
 	})
-	did, err := p.did.CreatePeerDID(didDoc)
+	did, err := p.did.CreatePeerDID2(didDoc)
 	if err != nil {
-		return nil, nil, fmt.Errorf(`creating peer did failed - %v`, err)
+		return fmt.Errorf(`creating peer did failed - %v`, err)
 	}
 
-	p.didStore.add(peer, did, didDoc)
-	return pubKey, prvKey, nil
+	return p.didStore.add(peer, did, p.did.AssignDID(didDoc, did))
 }
 
-// can improve this since all this unmarshalling will be done again in unpack todo
-// recipient[0] is hardcoded for now
-func (p *Prober) peerByMsg(data []byte) (name string, err error) {
-	// unmarshal into authcrypt message
-	var msg messages.AuthCryptMsg
-	err = json.Unmarshal(data, &msg)
+// keysByMsg returns the own key pair which the message is encrypted to. Messages
+// encrypted to the invitation key (eg: anoncrypted messages from invitees) are
+// not bound to a connection and hence the peer name is empty.
+func (p *Prober) keysByMsg(data []byte) (peerName string, pubKey, prvKey []byte, err error) {
+	recKeys, _, err := p.parseEnvelope(data)
 	if err != nil {
-		return ``, fmt.Errorf(`unmarshalling authcrypt message failed - %v`, err)
+		return ``, nil, nil, err
 	}
 
-	// decode protected payload
-	var payload messages.Payload
-	decodedVal, err := base64.StdEncoding.DecodeString(msg.Protected)
-	if err != nil {
-		return ``, fmt.Errorf(`decoding protected value with base64 failed - %v`, err)
+	if len(recKeys) == 0 {
+		return ``, nil, nil, fmt.Errorf(`no recipients found`)
 	}
 
-	err = json.Unmarshal(decodedVal, &payload)
-	if err != nil {
-		return ``, fmt.Errorf(`unmarshalling protected payload failed - %v`, err)
+	// group messages include a recipient per subscriber and hence the
+	// first key owned by the agent is selected
+	for _, recKey := range recKeys {
+		if invId, err := p.ks.Invitation(recKey); err == nil {
+			// omitted error since the invitation keys exist
+			prvKey, _ = p.ks.InvPrivateKey(invId)
+			return ``, recKey, prvKey, nil
+		}
+
+		if peerName, err = p.ks.Peer(recKey); err == nil {
+			pubKey = recKey
+			break
+		}
+	}
+
+	if peerName == `` {
+		return ``, nil, nil, fmt.Errorf(`none of the recipient keys belongs to the agent`)
+	}
+
+	// message may have been encrypted with the keys prior to a rotation
+	if prvKey, err = p.ks.PrivateKeyByPubKey(pubKey); err != nil {
+		return ``, nil, nil, fmt.Errorf(`getting private key for connection with %s failed - %v`, peerName, err)
 	}
 
-	decodedPubKey := base58.Decode(payload.Recipients[0].Header.Kid)
-	return p.ks.Peer(decodedPubKey)
+	return peerName, pubKey, prvKey, nil
 }
 
 func (p *Prober) infoByServc(filter string, svcs []models.Service) (endpoint string, pubKey []byte, err error) {
@@ -459,6 +1030,15 @@ func (p *Prober) infoByServc(filter string, svcs []models.Service) (endpoint str
 	return ``, nil, fmt.Errorf(`services does not contain %s`, filter)
 }
 
+func (p *Prober) acceptByServc(filter string, svcs []models.Service) []string {
+	for _, s := range svcs {
+		if s.Type == filter {
+			return s.Accept
+		}
+	}
+	return nil
+}
+
 // Peer returns the connected models.Peer queried by label
 func (p *Prober) Peer(label string) (models.Peer, error) {
 	pr, err := p.peers.peerByLabel(label)
@@ -482,7 +1062,7 @@ func (p *Prober) Service(name, peer string) (*models.Service, error) {
 		}
 	}
 
-	if srvc.Type == `` {
+	if srvc == nil {
 		return nil, fmt.Errorf(`requested service (%s) is not found for peer (%s)`, name, peer)
 	}
 
@@ -490,30 +1070,34 @@ func (p *Prober) Service(name, peer string) (*models.Service, error) {
 }
 
 func (p *Prober) SyncService(name, peer string, timeoutMs int64) (*models.Service, error) {
-	c := make(chan *models.Service)
-	tickr := time.NewTicker(time.Duration(timeoutMs) * time.Millisecond)
+	// buffered so that the poller does not block once the wait has timed out
+	c, done := make(chan *models.Service, 1), make(chan struct{})
+	defer close(done)
 
-	go func(c chan *models.Service) {
+	go func() {
 		for {
-			tmpSvc, err := p.Service(name, peer)
-			if err == nil {
-				c <- tmpSvc
-				break
+			if svc, err := p.Service(name, peer); err == nil {
+				c <- svc
+				return
 			}
-		}
-	}(c)
 
-	for {
-		select {
-		case svc := <-c:
-			return svc, nil
-		case <-tickr.C:
-			return nil, fmt.Errorf(`timedout waiting for the service info (service=%s, peer=%s)`, name, peer)
+			select {
+			case <-done:
+				return
+			case <-time.After(domain.RetryIntervalMs * time.Millisecond):
+			}
 		}
+	}()
+
+	select {
+	case svc := <-c:
+		return svc, nil
+	case <-time.After(time.Duration(timeoutMs) * time.Millisecond):
+		return nil, fmt.Errorf(`timedout waiting for the service info (service=%s, peer=%s)`, name, peer)
 	}
 }
 
 func (p *Prober) ValidConn(exchId string) (pr models.Peer, ok bool) {
 	_, pr, ok = p.peers.peerByExchId(exchId)
-	return pr, ok
+	return pr, ok && pr.State == domain.ConnCompleted
 }
@@ -0,0 +1,45 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"time"
+)
+
+// locale of the messages typed in the CLI
+const basicMsgLocale = `en`
+
+// basicMessage wraps the text in a basic message (Aries RFC-0095)
+func basicMessage(text string) ([]byte, error) {
+	bm := messages.BasicMessage{
+		Message:  messages.NewMessage(messages.BasicMessageV1),
+		SentTime: time.Now().UTC().Format(messages.TimeFormat),
+		Content:  text,
+	}
+	bm.L10n.Locale = basicMsgLocale
+
+	byts, err := json.Marshal(bm)
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling basic message failed - %v`, err)
+	}
+
+	return byts, nil
+}
+
+// readBasicMessage returns the content of the basic message along with the
+// output describing it, while the body of peers which send raw text is
+// returned as it is
+func readBasicMessage(sender string, body []byte) (content, out string) {
+	prefix := `Anonymous message received`
+	if sender != `` {
+		prefix = `Message received from ` + sender
+	}
+
+	var bm messages.BasicMessage
+	if err := json.Unmarshal(body, &bm); err != nil || bm.Type != messages.BasicMessageV1 {
+		return string(body), fmt.Sprintf(`%s: '%s'`, prefix, string(body))
+	}
+
+	return bm.Content, fmt.Sprintf(`%s at %s (id: %s): '%s'`, prefix, bm.SentTime, bm.Id, bm.Content)
+}
@@ -3,42 +3,24 @@ package prober
 import (
 	"fmt"
 	"github.com/YasiruR/didcomm-prober/domain/messages"
-	"sync"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 )
 
 type didStore struct {
-	didMap    map[string]string
-	didDocMap map[string]messages.DIDDocument
-	*sync.RWMutex
+	db services.ConnectionStore
 }
 
-func initDIDStore() *didStore {
-	return &didStore{
-		didMap:    map[string]string{},
-		didDocMap: map[string]messages.DIDDocument{},
-		RWMutex:   &sync.RWMutex{},
-	}
+func initDIDStore(db services.ConnectionStore) *didStore {
+	return &didStore{db: db}
 }
 
-func (d *didStore) add(label string, did string, doc messages.DIDDocument) {
-	d.Lock()
-	defer d.Unlock()
-	d.didMap[label] = did
-	d.didDocMap[label] = doc
+func (d *didStore) add(label string, did string, doc messages.DIDDocument) error {
+	if err := d.db.AddDID(label, did, doc); err != nil {
+		return fmt.Errorf(`storing did of the connection with %s failed - %v`, label, err)
+	}
+	return nil
 }
 
 func (d *didStore) get(label string) (did string, doc messages.DIDDocument, err error) {
-	d.RLock()
-	defer d.RUnlock()
-	did, ok := d.didMap[label]
-	if !ok {
-		return ``, messages.DIDDocument{}, fmt.Errorf(`did does not exist for %s`, label)
-	}
-
-	didDoc, ok := d.didDocMap[label]
-	if !ok {
-		return ``, messages.DIDDocument{}, fmt.Errorf(`did-doc does not exist for %s`, label)
-	}
-
-	return did, didDoc, nil
+	return d.db.DID(label)
 }
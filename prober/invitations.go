@@ -0,0 +1,204 @@
+package prober
+
+import (
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"sort"
+	"sync"
+	"time"
+)
+
+// invitations keeps the records of the invitations created by the agent
+// where a use is counted for each exchange thread which was responded
+type invitations struct {
+	db   services.ConnectionStore
+	ks   services.KeyManager
+	lock *sync.Mutex
+}
+
+func initInvitations(db services.ConnectionStore, ks services.KeyManager) *invitations {
+	return &invitations{db: db, ks: ks, lock: &sync.Mutex{}}
+}
+
+func (i *invitations) add(rec models.InvRecord) error {
+	if err := i.db.AddInvRecord(rec); err != nil {
+		return fmt.Errorf(`storing invitation %s failed - %v`, rec.Id, err)
+	}
+	return nil
+}
+
+// active returns the invitation if it has not expired, irrespective of
+// the number of uses
+func (i *invitations) active(id string) (models.InvRecord, error) {
+	rec, err := i.db.InvRecord(id)
+	if err != nil {
+		return models.InvRecord{}, fmt.Errorf(`no invitation found for %s`, id)
+	}
+
+	if rec.Expired() {
+		return models.InvRecord{}, fmt.Errorf(`invitation %s expired at %s`, id, rec.Expiry.Format(time.RFC3339))
+	}
+
+	return rec, nil
+}
+
+// valid checks if a connection request of the exchange thread can be
+// accepted for the invitation without counting a use
+func (i *invitations) valid(id, thId string) error {
+	rec, err := i.active(id)
+	if err != nil {
+		return err
+	}
+
+	if !rec.Handshake {
+		return fmt.Errorf(`invitation %s is connectionless`, id)
+	}
+
+	if rec.Exhausted() && !counted(rec, thId) {
+		return fmt.Errorf(`invitation %s has already been used %d time(s)`, id, rec.Uses)
+	}
+
+	return nil
+}
+
+// use increments the number of uses of the invitation once per exchange
+// thread unless it has expired or has already been used the maximum
+// number of times
+func (i *invitations) use(id, thId string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	rec, err := i.active(id)
+	if err != nil {
+		return err
+	}
+
+	if !rec.Handshake {
+		return fmt.Errorf(`invitation %s is connectionless`, id)
+	}
+
+	if counted(rec, thId) {
+		return nil
+	}
+
+	if rec.Exhausted() {
+		return fmt.Errorf(`invitation %s has already been used %d time(s)`, id, rec.Uses)
+	}
+
+	rec.Uses++
+	rec.Threads = append(rec.Threads, thId)
+	return i.add(rec)
+}
+
+// release reverts the use of the exchange thread if the response
+// could not be sent
+func (i *invitations) release(id, thId string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	rec, err := i.db.InvRecord(id)
+	if err != nil {
+		return fmt.Errorf(`no invitation found for %s`, id)
+	}
+
+	for j, th := range rec.Threads {
+		if th == thId {
+			rec.Uses--
+			rec.Threads = append(rec.Threads[:j], rec.Threads[j+1:]...)
+			return i.add(rec)
+		}
+	}
+
+	return nil
+}
+
+func counted(rec models.InvRecord, thId string) bool {
+	for _, th := range rec.Threads {
+		if th == thId {
+			return true
+		}
+	}
+	return false
+}
+
+// prune removes the expired invitations along with their keys, and the
+// invitations whose keys were lost since the agent was restarted without
+// a wallet. Number of removed invitations is returned.
+func (i *invitations) prune() (int, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	recs, err := i.db.InvRecords()
+	if err != nil {
+		return 0, fmt.Errorf(`fetching invitations failed - %v`, err)
+	}
+
+	var n int
+	for id, rec := range recs {
+		if _, err = i.ks.InvPublicKey(id); err == nil && !rec.Expired() {
+			continue
+		}
+
+		if err = i.remove(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// list returns the usable invitations in the order of creation
+func (i *invitations) list() ([]models.InvRecord, error) {
+	if _, err := i.prune(); err != nil {
+		return nil, err
+	}
+
+	recs, err := i.db.InvRecords()
+	if err != nil {
+		return nil, fmt.Errorf(`fetching invitations failed - %v`, err)
+	}
+
+	var invs []models.InvRecord
+	for _, rec := range recs {
+		invs = append(invs, rec)
+	}
+	sort.Slice(invs, func(i, j int) bool { return invs[i].Created.Before(invs[j].Created) })
+	return invs, nil
+}
+
+func (i *invitations) revoke(id string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.remove(id)
+}
+
+// remove deletes the record and the keys of the invitation
+func (i *invitations) remove(id string) error {
+	if _, err := i.db.InvRecord(id); err != nil {
+		return fmt.Errorf(`no invitation found for %s`, id)
+	}
+
+	if err := i.db.DeleteInvRecord(id); err != nil {
+		return fmt.Errorf(`deleting invitation %s failed - %v`, id, err)
+	}
+
+	if err := i.ks.RemoveInvKeys(id); err != nil {
+		return fmt.Errorf(`removing keys of invitation %s failed - %v`, id, err)
+	}
+	return nil
+}
+
+// Invitations returns the records of the invitations which have not been revoked
+func (p *Prober) Invitations() ([]models.InvRecord, error) {
+	return p.invs.list()
+}
+
+// RevokeInvitation removes the record and the keys of the invitation such
+// that subsequent connection requests are rejected
+func (p *Prober) RevokeInvitation(id string) error {
+	if err := p.invs.revoke(id); err != nil {
+		return err
+	}
+
+	p.log.Debug(fmt.Sprintf(`revoked invitation %s`, id))
+	return nil
+}
@@ -0,0 +1,178 @@
+package prober
+
+import (
+	"github.com/YasiruR/didcomm-prober/crypto"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/store"
+	"testing"
+	"time"
+)
+
+func TestInvRecord(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name               string
+		rec                models.InvRecord
+		expired, exhausted bool
+	}{
+		{`unlimited`, models.InvRecord{Uses: 10}, false, false},
+		{`not expired`, models.InvRecord{Expiry: now.Add(time.Minute)}, false, false},
+		{`expired`, models.InvRecord{Expiry: now.Add(-time.Minute)}, true, false},
+		{`uses left`, models.InvRecord{MaxUses: 2, Uses: 1}, false, false},
+		{`exhausted`, models.InvRecord{MaxUses: 2, Uses: 2}, false, true},
+		{`expired and exhausted`, models.InvRecord{Expiry: now.Add(-time.Minute), MaxUses: 1, Uses: 1}, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.rec.Expired() != test.expired {
+				t.Errorf(`expected expired to be %t`, test.expired)
+			}
+
+			if test.rec.Exhausted() != test.exhausted {
+				t.Errorf(`expected exhausted to be %t`, test.exhausted)
+			}
+		})
+	}
+}
+
+func newTestInvitations(t *testing.T, recs ...models.InvRecord) *invitations {
+	ks := crypto.NewKeyManager()
+	invs := initInvitations(store.NewMemory(), ks)
+	for _, rec := range recs {
+		if err := ks.GenerateInvKeys(rec.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := invs.add(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return invs
+}
+
+func TestInvitations_Use(t *testing.T) {
+	tests := []struct {
+		name  string
+		rec   models.InvRecord
+		thIds []string
+		valid []bool
+		uses  int
+	}{
+		{`unlimited`, models.InvRecord{Id: `inv`, Handshake: true}, []string{`th1`, `th2`, `th3`}, []bool{true, true, true}, 3},
+		{`single use`, models.InvRecord{Id: `inv`, Handshake: true, MaxUses: 1}, []string{`th1`, `th2`}, []bool{true, false}, 1},
+		{`same thread`, models.InvRecord{Id: `inv`, Handshake: true, MaxUses: 1}, []string{`th1`, `th1`}, []bool{true, true}, 1},
+		{`expired`, models.InvRecord{Id: `inv`, Handshake: true, Expiry: time.Now().Add(-time.Second)}, []string{`th1`}, []bool{false}, 0},
+		{`connectionless`, models.InvRecord{Id: `inv`}, []string{`th1`}, []bool{false}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			invs := newTestInvitations(t, test.rec)
+			for i, thId := range test.thIds {
+				if err := invs.valid(test.rec.Id, thId); (err == nil) != test.valid[i] {
+					t.Errorf(`expected validity of request %d to be %t (err: %v)`, i, test.valid[i], err)
+				}
+
+				if err := invs.use(test.rec.Id, thId); (err == nil) != test.valid[i] {
+					t.Errorf(`expected use of request %d to be %t (err: %v)`, i, test.valid[i], err)
+				}
+			}
+
+			rec, err := invs.db.InvRecord(test.rec.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Uses != test.uses || len(rec.Threads) != test.uses {
+				t.Errorf(`expected %d use(s) but got %d (threads: %v)`, test.uses, rec.Uses, rec.Threads)
+			}
+		})
+	}
+}
+
+func TestInvitations_Release(t *testing.T) {
+	invs := newTestInvitations(t, models.InvRecord{Id: `inv`, Handshake: true, MaxUses: 1})
+	if err := invs.use(`inv`, `th1`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := invs.use(`inv`, `th2`); err == nil {
+		t.Fatal(`use of an exhausted invitation should fail`)
+	}
+
+	if err := invs.release(`inv`, `th1`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := invs.use(`inv`, `th2`); err != nil {
+		t.Errorf(`use after releasing a thread should succeed - %v`, err)
+	}
+}
+
+func TestInvitations_Revoke(t *testing.T) {
+	invs := newTestInvitations(t, models.InvRecord{Id: `inv`, Handshake: true})
+	if err := invs.revoke(`inv`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := invs.ks.InvPublicKey(`inv`); err == nil {
+		t.Error(`keys of a revoked invitation should be removed`)
+	}
+
+	if err := invs.valid(`inv`, `th1`); err == nil {
+		t.Error(`requests for a revoked invitation should be rejected`)
+	}
+
+	if err := invs.revoke(`inv`); err == nil {
+		t.Error(`revoking an unknown invitation should fail`)
+	}
+}
+
+func TestInvitations_Prune(t *testing.T) {
+	invs := newTestInvitations(t,
+		models.InvRecord{Id: `active`, Handshake: true, Created: time.Now()},
+		models.InvRecord{Id: `expired`, Handshake: true, Expiry: time.Now().Add(-time.Second)},
+	)
+
+	// record restored from the store without the keys as if there was no wallet
+	if err := invs.add(models.InvRecord{Id: `restored`, Handshake: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := invs.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != 1 || recs[0].Id != `active` {
+		t.Fatalf(`expected only the active invitation but got %v`, recs)
+	}
+
+	if _, err = invs.ks.InvPublicKey(`expired`); err == nil {
+		t.Error(`keys of an expired invitation should be removed`)
+	}
+}
+
+// TestInvitations_ConcurrentUse checks that only one of the concurrent
+// requests reserves the last use of an invitation
+func TestInvitations_ConcurrentUse(t *testing.T) {
+	invs := newTestInvitations(t, models.InvRecord{Id: `inv`, Handshake: true, MaxUses: 1})
+	errs := make(chan error)
+	for _, thId := range []string{`th1`, `th2`, `th3`} {
+		go func(thId string) {
+			errs <- invs.use(`inv`, thId)
+		}(thId)
+	}
+
+	var reserved int
+	for i := 0; i < 3; i++ {
+		if <-errs == nil {
+			reserved++
+		}
+	}
+
+	if reserved != 1 {
+		t.Errorf(`expected a single request to reserve the invitation but got %d`, reserved)
+	}
+}
@@ -0,0 +1,372 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/btcsuite/btcutil/base58"
+	"sort"
+	"time"
+)
+
+/* Coordinate mediation (Aries RFC-0211) where responses are returned via the return route */
+
+// mediation keeps the mediator granted to the agent, whose endpoint and routing
+// keys are included in the message services of subsequent did docs, along with
+// the connections mediated by the agent and their routes. State is persisted in
+// the connection store such that it is restored after a restart.
+type mediation struct {
+	db services.ConnectionStore
+}
+
+func initMediation(db services.ConnectionStore) *mediation {
+	return &mediation{db: db}
+}
+
+func (m *mediation) set(mediator, endpoint string, routingKeys [][]byte) error {
+	if err := m.db.SaveMediation(models.Mediation{Mediator: mediator, Endpoint: endpoint, RoutingKeys: routingKeys}); err != nil {
+		return fmt.Errorf(`storing mediation failed - %v`, err)
+	}
+	return nil
+}
+
+func (m *mediation) get() (mediator, endpoint string, routingKeys [][]byte, ok bool) {
+	med, err := m.db.Mediation()
+	if err != nil {
+		return ``, ``, nil, false
+	}
+	return med.Mediator, med.Endpoint, med.RoutingKeys, med.Mediator != ``
+}
+
+func (m *mediation) grant(peer string) error {
+	if err := m.db.AddMediated(peer); err != nil {
+		return fmt.Errorf(`storing mediated connection failed - %v`, err)
+	}
+	return nil
+}
+
+func (m *mediation) granted(peer string) bool {
+	ok, err := m.db.Mediated(peer)
+	return err == nil && ok
+}
+
+func (m *mediation) addRoute(recKey []byte, rt models.Route) error {
+	if err := m.db.AddRoute(base58.Encode(recKey), rt); err != nil {
+		return fmt.Errorf(`storing route failed - %v`, err)
+	}
+	return nil
+}
+
+func (m *mediation) removeRoute(recKey []byte) error {
+	if err := m.db.DeleteRoute(base58.Encode(recKey)); err != nil {
+		return fmt.Errorf(`deleting route failed - %v`, err)
+	}
+	return nil
+}
+
+// route returns the route of the base58 encoded recipient key
+func (m *mediation) route(recKey string) (models.Route, bool) {
+	rt, err := m.db.Route(recKey)
+	return rt, err == nil
+}
+
+// keys returns the base58 encoded recipient keys routed to the peer
+func (m *mediation) keys(peer string) ([]string, error) {
+	rts, err := m.db.Routes()
+	if err != nil {
+		return nil, fmt.Errorf(`fetching routes failed - %v`, err)
+	}
+
+	var keys []string
+	for key, rt := range rts {
+		if rt.Peer == peer {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// RequestMediation enrolls with the peer as the mediator such that the message
+// services of subsequent connections are routed through it
+func (p *Prober) RequestMediation(mediator string) error {
+	req := messages.MediateRequest{Message: returnRouteMessage(messages.MediateRequestV1)}
+	var grant messages.MediateGrant
+	if err := p.Request(mediator, models.TypMediation, req, &grant); err != nil {
+		return fmt.Errorf(`requesting mediation failed - %w`, err)
+	}
+
+	if grant.Type == messages.MediateDenyV1 {
+		return fmt.Errorf(`mediation denied by %s`, mediator)
+	}
+
+	if grant.Type != messages.MediateGrantV1 {
+		return fmt.Errorf(`invalid message type for mediation grant (%s)`, grant.Type)
+	}
+
+	var routingKeys [][]byte
+	for _, k := range grant.RoutingKeys {
+		key, err := p.keyByDID(k)
+		if err != nil {
+			return fmt.Errorf(`invalid routing key in mediation grant - %v`, err)
+		}
+		routingKeys = append(routingKeys, key)
+	}
+
+	if err := p.mediation.set(mediator, grant.Endpoint, routingKeys); err != nil {
+		return err
+	}
+	p.outChan <- fmt.Sprintf(`Mediation granted by %s (endpoint: %s)`, mediator, grant.Endpoint)
+	return nil
+}
+
+// MediatedKeys returns the recipient keys registered with the mediator
+func (p *Prober) MediatedKeys(mediator string) ([]string, error) {
+	var keys []string
+	for {
+		req := messages.KeylistQuery{
+			Message:  returnRouteMessage(messages.KeylistQueryV1),
+			Paginate: &messages.Paginate{Limit: domain.KeylistPageSize, Offset: len(keys)},
+		}
+
+		var kl messages.Keylist
+		if err := p.Request(mediator, models.TypMediation, req, &kl); err != nil {
+			return nil, fmt.Errorf(`querying keylist failed - %w`, err)
+		}
+
+		if kl.Type != messages.KeylistV1 {
+			return nil, fmt.Errorf(`invalid message type for keylist (%s)`, kl.Type)
+		}
+
+		for _, k := range kl.Keys {
+			keys = append(keys, k.RecipientKey)
+		}
+
+		if kl.Pagination == nil || kl.Pagination.RemainingCount == 0 || len(kl.Keys) == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// updateKeylist adds or removes the recipient key of a connection at the mediator
+func (p *Prober) updateKeylist(mediator string, key []byte, action string) error {
+	recKey, err := p.did.CreateKeyDID(domain.KeyEd25519, key)
+	if err != nil {
+		return fmt.Errorf(`creating did:key of the recipient key failed - %v`, err)
+	}
+
+	req := messages.KeylistUpdate{
+		Message: returnRouteMessage(messages.KeylistUpdateV1),
+		Updates: []messages.KeylistUpdateRule{{RecipientKey: recKey, Action: action}},
+	}
+
+	var res messages.KeylistUpdateResponse
+	if err = p.Request(mediator, models.TypMediation, req, &res); err != nil {
+		return fmt.Errorf(`updating keylist failed - %w`, err)
+	}
+
+	if res.Type != messages.KeylistUpdateResV1 {
+		return fmt.Errorf(`invalid message type for keylist update response (%s)`, res.Type)
+	}
+
+	for _, u := range res.Updated {
+		if u.RecipientKey != recKey {
+			continue
+		}
+
+		if u.Result != messages.KeylistSuccess && u.Result != messages.KeylistNoChange {
+			return fmt.Errorf(`mediator could not %s the recipient key (%s)`, action, u.Result)
+		}
+		return nil
+	}
+
+	return fmt.Errorf(`keylist update response does not contain the recipient key`)
+}
+
+// retireMediatedKey removes the previous key of the connection from the
+// mediator once the grace period of the rotation elapses
+func (p *Prober) retireMediatedKey(peer string, key []byte) {
+	mediator, _, _, ok := p.mediation.get()
+	if !ok || mediator == peer {
+		return
+	}
+
+	time.AfterFunc(domain.RotationGracePeriodMs*time.Millisecond, func() {
+		if err := p.updateKeylist(mediator, key, messages.KeylistRemove); err != nil {
+			p.log.Error(fmt.Sprintf(`removing the retired key of %s from the mediator failed - %v`, peer, err))
+		}
+	})
+}
+
+// processMediation returns the packed response to the coordinate mediation
+// request which should be replied via the return route
+func (p *Prober) processMediation(msg models.Message) ([]byte, error) {
+	peerName, hdr, body, err := p.ReadRequest(msg)
+	if err != nil {
+		return nil, fmt.Errorf(`reading coordinate mediation request failed - %w`, err)
+	}
+
+	if hdr.Type != messages.MediateRequestV1 && !p.mediation.granted(peerName) {
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`mediation has not been granted to %s`, peerName))
+	}
+
+	var res interface{}
+	switch hdr.Type {
+	case messages.MediateRequestV1:
+		res = p.mediate(peerName, hdr)
+	case messages.KeylistUpdateV1:
+		var req messages.KeylistUpdate
+		if err = json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf(`unmarshalling keylist update failed - %v`, err)
+		}
+		if res, err = p.keylistUpdate(peerName, req); err != nil {
+			return nil, problem.WithThread(hdr.ThId(), err)
+		}
+	case messages.KeylistQueryV1:
+		var req messages.KeylistQuery
+		if err = json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf(`unmarshalling keylist query failed - %v`, err)
+		}
+		res = p.keylist(peerName, req)
+	default:
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`invalid message type for coordinate mediation (%s)`, hdr.Type))
+	}
+
+	// omitted error since responses only contain strings
+	byts, _ := json.Marshal(res)
+	return p.PackMessage(peerName, byts)
+}
+
+// mediate grants mediation to peers with completed connections
+func (p *Prober) mediate(peer string, req messages.Message) interface{} {
+	pr, err := p.peers.peerByLabel(peer)
+	if err != nil || pr.State != domain.ConnCompleted {
+		p.log.Debug(fmt.Sprintf(`mediation denied to %s since the connection is not completed`, peer))
+		return messages.MediateDeny{Message: req.Reply(messages.MediateDenyV1)}
+	}
+
+	if err = p.mediation.grant(peer); err != nil {
+		p.log.Error(err)
+		return messages.MediateDeny{Message: req.Reply(messages.MediateDenyV1)}
+	}
+
+	// omitted error since the routing key is a valid Ed25519 key
+	routingKey, _ := p.did.CreateKeyDID(domain.KeyEd25519, p.ks.RoutingPublicKey())
+	p.log.Info(fmt.Sprintf(`mediation granted to %s`, peer))
+
+	return messages.MediateGrant{
+		Message:     req.Reply(messages.MediateGrantV1),
+		Endpoint:    p.invEndpoint,
+		RoutingKeys: []string{routingKey},
+	}
+}
+
+// keylistUpdate routes the added keys to the message service of the connection
+// while keys registered by other connections are not modified
+func (p *Prober) keylistUpdate(peer string, req messages.KeylistUpdate) (messages.KeylistUpdateResponse, error) {
+	pr, err := p.peers.peerByLabel(peer)
+	if err != nil {
+		return messages.KeylistUpdateResponse{}, fmt.Errorf(`no didcomm connection found for %s - %v`, peer, err)
+	}
+
+	endpoint, _, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return messages.KeylistUpdateResponse{}, fmt.Errorf(`getting message endpoint failed - %v`, err)
+	}
+
+	res := messages.KeylistUpdateResponse{Message: req.Reply(messages.KeylistUpdateResV1)}
+	for _, u := range req.Updates {
+		res.Updated = append(res.Updated, messages.KeylistUpdated{
+			RecipientKey: u.RecipientKey,
+			Action:       u.Action,
+			Result:       p.updateRoute(peer, endpoint, u),
+		})
+	}
+
+	return res, nil
+}
+
+func (p *Prober) updateRoute(peer, endpoint string, u messages.KeylistUpdateRule) (result string) {
+	key, err := p.keyByDID(u.RecipientKey)
+	if err != nil {
+		return messages.KeylistClientError
+	}
+
+	rt, exists := p.mediation.route(base58.Encode(key))
+	if exists && rt.Peer != peer {
+		return messages.KeylistClientError
+	}
+
+	switch u.Action {
+	case messages.KeylistAdd:
+		if exists {
+			return messages.KeylistNoChange
+		}
+		err = p.AddRoute(peer, key, endpoint)
+	case messages.KeylistRemove:
+		if !exists {
+			return messages.KeylistNoChange
+		}
+		err = p.RemoveRoute(key)
+	default:
+		return messages.KeylistClientError
+	}
+
+	if err != nil {
+		p.log.Error(err)
+		return messages.KeylistServerError
+	}
+
+	return messages.KeylistSuccess
+}
+
+// keylist returns the keys registered by the connection sorted such that pages are consistent
+func (p *Prober) keylist(peer string, req messages.KeylistQuery) messages.Keylist {
+	routed, err := p.mediation.keys(peer)
+	if err != nil {
+		p.log.Error(err)
+	}
+
+	var keys []string
+	for _, key := range routed {
+		// omitted error since the stored keys are valid Ed25519 keys
+		did, _ := p.did.CreateKeyDID(domain.KeyEd25519, base58.Decode(key))
+		keys = append(keys, did)
+	}
+	sort.Strings(keys)
+
+	kl := messages.Keylist{Message: req.Reply(messages.KeylistV1), Keys: []messages.KeylistKey{}}
+	offset, limit := 0, len(keys)
+	if req.Paginate != nil {
+		offset, limit = req.Paginate.Offset, req.Paginate.Limit
+	}
+
+	if offset < 0 || offset > len(keys) {
+		offset = len(keys)
+	}
+
+	end := len(keys)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	for _, k := range keys[offset:end] {
+		kl.Keys = append(kl.Keys, messages.KeylistKey{RecipientKey: k})
+	}
+
+	kl.Pagination = &messages.Pagination{Count: len(kl.Keys), Offset: offset, RemainingCount: len(keys) - end}
+	return kl
+}
+
+// keyByDID decodes an Ed25519 key given as a did:key or a raw key as in
+// earlier versions of the protocol
+func (p *Prober) keyByDID(val string) ([]byte, error) {
+	keys, err := p.did.ServiceKeys(messages.DIDDocument{}, messages.Service{RecipientKeys: []string{val}})
+	if err != nil {
+		return nil, err
+	}
+	return keys[0], nil
+}
@@ -0,0 +1,159 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/btcsuite/btcutil/base58"
+	"time"
+)
+
+/* Mediator role which relays forward messages (Aries RFC-0094) */
+
+func (p *Prober) RoutingKey() []byte {
+	return p.ks.RoutingPublicKey()
+}
+
+// AddRoute relays the forward messages to the recipient key via the endpoint
+// while the messages are queued for the peer if the endpoint is empty or
+// the recipient is not reachable
+func (p *Prober) AddRoute(peer string, recKey []byte, endpoint string) error {
+	return p.mediation.addRoute(recKey, models.Route{Peer: peer, Endpoint: endpoint})
+}
+
+func (p *Prober) RemoveRoute(recKey []byte) error {
+	return p.mediation.removeRoute(recKey)
+}
+
+// processForward unwraps the forward message and relays the inner message to the
+// route of the recipient once the forward is acknowledged, such that the mediator
+// is not blocked by recipients which are slow or offline. Forward messages with
+// the return route are relayed synchronously and the reply of the recipient is
+// returned to the sender.
+func (p *Prober) processForward(msg models.Message) ([]byte, error) {
+	fwd, err := p.unwrap(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf(`unwrapping forward message failed - %w`, err)
+	}
+
+	rt, ok := p.route(fwd.To)
+	if !ok {
+		return nil, problem.WithThread(fwd.ThId(), fmt.Errorf(`no route found for the recipient key %s`, fwd.To))
+	}
+
+	// inner messages which are not the payload are forward messages to the next mediator
+	mt := models.TypForward
+	if fwd.MsgType != `` {
+		if mt, ok = models.MsgTypeByName(fwd.MsgType); !ok {
+			return nil, problem.WithThread(fwd.ThId(), fmt.Errorf(`invalid message type of the forwarded message (%s)`, fwd.MsgType))
+		}
+	}
+
+	if fwd.Transport != nil && fwd.Transport.ReturnRoute == messages.ReturnRouteAll {
+		if rt.Endpoint == `` {
+			return nil, problem.WithThread(fwd.ThId(), fmt.Errorf(`recipient of %s does not have an endpoint to return the reply`, fwd.To))
+		}
+
+		reply, err := p.client.SendWithTimeout(mt, fwd.Msg, rt.Endpoint, domain.RelayReplyTimeoutMs*time.Millisecond)
+		if err != nil {
+			return nil, problem.WithThread(fwd.ThId(), fmt.Errorf(`relaying '%s' message to %s failed - %v`, mt, rt.Endpoint, err))
+		}
+		return []byte(reply), nil
+	}
+
+	if rt.Endpoint == `` {
+		if err = p.queue.add(rt.Peer, fwd.To, mt, fwd.Msg); err != nil {
+			return nil, problem.WithThread(fwd.ThId(), fmt.Errorf(`queueing message failed - %v`, err))
+		}
+		p.log.Trace(fmt.Sprintf(`queued '%s' message for %s`, mt, rt.Peer))
+		return nil, nil
+	}
+
+	go p.relay(rt, fwd.To, mt, fwd.Msg)
+	return nil, nil
+}
+
+// relay sends the message to the next hop where packed replies are discarded
+// since they can only be read by the sender of the forward message. Messages
+// are queued if the recipient is not reachable and has registered the route,
+// where a message which was received despite the timeout is discarded by the
+// recipient when it is picked up (see received).
+func (p *Prober) relay(rt models.Route, to string, mt models.MsgType, data []byte) {
+	_, err := p.client.SendWithTimeout(mt, data, rt.Endpoint, domain.RelayTimeoutMs*time.Millisecond)
+	if err == nil {
+		p.log.Trace(fmt.Sprintf(`relayed '%s' message to %s`, mt, rt.Endpoint))
+		return
+	}
+
+	var pe *domain.ProblemError
+	if errors.As(err, &pe) || rt.Peer == `` {
+		p.log.Error(fmt.Sprintf(`relaying '%s' message to %s failed - %v`, mt, rt.Endpoint, err))
+		return
+	}
+
+	if err = p.queue.add(rt.Peer, to, mt, data); err != nil {
+		p.log.Error(fmt.Sprintf(`queueing undelivered '%s' message for %s failed - %v`, mt, rt.Peer, err))
+		return
+	}
+	p.log.Debug(fmt.Sprintf(`queued '%s' message for %s since it is not reachable`, mt, rt.Peer))
+}
+
+// unwrap decrypts the forward message which should be anoncrypted to the routing key
+func (p *Prober) unwrap(data []byte) (messages.Forward, error) {
+	routingKey := p.ks.RoutingPublicKey()
+	recKeys, _, err := p.parseEnvelope(data)
+	if err != nil {
+		return messages.Forward{}, err
+	}
+
+	var found bool
+	for _, recKey := range recKeys {
+		if bytes.Equal(recKey, routingKey) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return messages.Forward{}, fmt.Errorf(`forward message is not encrypted to the routing key`)
+	}
+
+	body, _, err := p.unpack(data, routingKey, p.ks.RoutingPrivateKey())
+	if err != nil {
+		return messages.Forward{}, fmt.Errorf(`unpacking forward message failed - %v`, err)
+	}
+
+	var fwd messages.Forward
+	if err = json.Unmarshal(body, &fwd); err != nil {
+		return messages.Forward{}, fmt.Errorf(`unmarshalling forward message failed - %v`, err)
+	}
+
+	if fwd.Type != messages.ForwardV1 {
+		return messages.Forward{}, fmt.Errorf(`invalid message type for forward (%s)`, fwd.Type)
+	}
+
+	if fwd.Expired() {
+		return messages.Forward{}, problem.WithThread(fwd.ThId(), fmt.Errorf(`forward message has expired`))
+	}
+
+	return fwd, nil
+}
+
+// route returns the route registered for the recipient key while messages
+// to the keys of the agent itself are delivered to its own endpoint
+func (p *Prober) route(to string) (rt models.Route, ok bool) {
+	if rt, ok = p.mediation.route(to); ok {
+		return rt, true
+	}
+
+	if _, err := p.ks.Peer(base58.Decode(to)); err == nil {
+		return models.Route{Endpoint: p.invEndpoint}, true
+	}
+
+	return models.Route{}, false
+}
@@ -0,0 +1,162 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+)
+
+// goalRelBuild is the goal code of invitations which establish a connection
+const goalRelBuild = `aries.rel.build`
+
+/* Out-of-band 1.1 (Aries RFC-0434) where handshake reuse is returned via the
+   return route and attached requests are processed without a connection */
+
+// InviteConnectionless creates an invitation without handshake protocols where
+// the messages are attached as requests which the invitee processes without
+// establishing a connection
+func (p *Prober) InviteConnectionless(requests ...interface{}) (url string, err error) {
+	if len(requests) == 0 {
+		return ``, fmt.Errorf(`connectionless invitation should contain at least one request`)
+	}
+
+	var atts []messages.Attachment
+	for i, r := range requests {
+		byts, err := json.Marshal(r)
+		if err != nil {
+			return ``, fmt.Errorf(`marshalling request failed - %v`, err)
+		}
+
+		atts = append(atts, messages.Attachment{
+			Id:       fmt.Sprintf(`request-%d`, i),
+			MimeType: `application/json`,
+			Data:     messages.AttachmentData{Json: byts},
+		})
+	}
+
+	return p.invite(nil, atts, models.InvOptions{})
+}
+
+func supportsHandshake(protocols []string) bool {
+	for _, hp := range protocols {
+		if hp == messages.DIDExchangeV1 {
+			return true
+		}
+	}
+	return false
+}
+
+// reuse sends a handshake reuse via the completed connection with the inviter
+// and returns false if the inviter is not known or does not accept the reuse,
+// in which case a new connection should be established. Inviter is identified
+// by the did of the invitation, which is shared by its invitations, since
+// labels are not unique.
+func (p *Prober) reuse(inv messages.Invitation) bool {
+	if inv.From == `` {
+		return false
+	}
+
+	label, pr, err := p.peers.peerByRef(inv.From)
+	if err != nil || pr.State != domain.ConnCompleted {
+		return false
+	}
+
+	req := messages.HandshakeReuse{Message: returnRouteMessage(messages.HandshakeReuseV11)}
+	req.SetThread(req.Id, inv.Id)
+
+	var res messages.HandshakeReuseAccepted
+	if err = p.Request(label, models.TypHandshakeReuse, req, &res); err != nil {
+		p.log.Debug(fmt.Sprintf(`handshake reuse with %s failed and hence requesting a new connection - %v`, label, err))
+		return false
+	}
+
+	if res.Type != messages.ReuseAcceptedV11 {
+		p.log.Debug(fmt.Sprintf(`invalid message type for handshake reuse accepted (%s) and hence requesting a new connection`, res.Type))
+		return false
+	}
+
+	p.outChan <- `Connection reused with ` + label
+	return true
+}
+
+// processReuse accepts the handshake reuse if its parent thread is an
+// invitation created by the agent
+func (p *Prober) processReuse(msg models.Message) ([]byte, error) {
+	peerName, hdr, _, err := p.ReadRequest(msg)
+	if err != nil {
+		return nil, fmt.Errorf(`reading handshake reuse failed - %w`, err)
+	}
+
+	if hdr.Type != messages.HandshakeReuseV11 {
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`invalid message type for handshake reuse (%s)`, hdr.Type))
+	}
+
+	if _, err = p.invs.active(hdr.PThId()); err != nil {
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`handshake reuse is not accepted - %v`, err))
+	}
+
+	// omitted error since the response only contains strings
+	byts, _ := json.Marshal(messages.HandshakeReuseAccepted{Message: hdr.Reply(messages.ReuseAcceptedV11)})
+	p.outChan <- `Connection reused by ` + peerName
+	return p.PackMessage(peerName, byts)
+}
+
+// processInvRequests processes the requests attached to the invitation where
+// responses are anoncrypted to the invitation key since they are not bound to
+// a connection. Requests of unsupported protocols are ignored.
+func (p *Prober) processInvRequests(inv messages.Invitation, env domain.Envelope, endpoint string, invKey []byte) error {
+	for _, att := range inv.Requests {
+		var hdr messages.Message
+		if err := json.Unmarshal(att.Data.Json, &hdr); err != nil {
+			return fmt.Errorf(`attached request %s is not a didcomm message - %v`, att.Id, err)
+		}
+
+		switch hdr.Type {
+		case messages.BasicMessageV1:
+			// label of the invitation is not authenticated without a connection
+			_, out := readBasicMessage(``, att.Data.Json)
+			p.outChan <- fmt.Sprintf(`%s [unverified sender: %s]`, out, inv.Label)
+		case messages.TrustPingV1:
+			var ping messages.Ping
+			if err := json.Unmarshal(att.Data.Json, &ping); err != nil {
+				return fmt.Errorf(`unmarshalling trust ping failed - %v`, err)
+			}
+
+			if ping.ResponseRequested {
+				p.sendInvPingResponse(inv, ping.ThId(), env, endpoint, invKey)
+			}
+		default:
+			p.log.Debug(fmt.Sprintf(`ignored attached request %s of an unsupported type (%s)`, att.Id, hdr.Type))
+		}
+	}
+
+	return nil
+}
+
+// sendInvPingResponse only logs failures since the response is not required
+// to proceed with the invitation
+func (p *Prober) sendInvPingResponse(inv messages.Invitation, thId string, env domain.Envelope, endpoint string, invKey []byte) {
+	res := messages.PingResponse{Message: messages.NewMessage(messages.TrustPingResV1)}
+	res.SetThread(thId, inv.Id)
+
+	// omitted error since the response only contains strings
+	byts, _ := json.Marshal(res)
+	msg, err := p.packAnon(env, byts, invKey)
+	if err != nil {
+		p.log.Error(fmt.Sprintf(`packing trust ping response to %s failed - %v`, inv.Label, err))
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		p.log.Error(fmt.Sprintf(`marshalling trust ping response to %s failed - %v`, inv.Label, err))
+		return
+	}
+
+	if err = p.dispatch(models.TypTrustPingRes, data, endpoint); err != nil {
+		p.log.Error(fmt.Sprintf(`sending trust ping response to %s failed - %v`, inv.Label, err))
+	}
+}
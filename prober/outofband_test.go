@@ -0,0 +1,109 @@
+package prober
+
+import (
+	"bytes"
+	"github.com/YasiruR/didcomm-prober/crypto"
+	"github.com/YasiruR/didcomm-prober/didcomm/did"
+	"github.com/YasiruR/didcomm-prober/didcomm/invitation"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/container"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/store"
+	"net/url"
+	"testing"
+)
+
+func newTestInviter(t *testing.T, label string) *Prober {
+	ks, h := crypto.NewKeyManager(), did.NewHandler()
+	if err := ks.GenerateInviterKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	inviterDID, err := h.CreatePeerDID0(ks.InviterPublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Prober{
+		label:       label,
+		inviterDID:  inviterDID,
+		invEndpoint: `tcp://127.0.0.1:9090`,
+		ks:          ks,
+		did:         h,
+		oob:         invitation.NewOOBService(&container.Config{Hostname: `tcp://127.0.0.1:9090`}, h, did.NewRegistry(h)),
+		invs:        initInvitations(store.NewMemory(), ks),
+		prefEnv:     domain.EnvelopeRFC19,
+	}
+}
+
+func parseTestInv(t *testing.T, p *Prober, invUrl string) (from string, key []byte) {
+	u, err := url.Parse(invUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv, _, key, err := p.oob.ParseInv(u.Query().Get(`oob`))
+	if err != nil {
+		t.Fatalf(`parsing invitation failed - %v`, err)
+	}
+	return inv.From, key
+}
+
+// TestReuse_SameInviter checks that a connection established via one
+// invitation is found for another invitation of the same inviter
+func TestReuse_SameInviter(t *testing.T) {
+	inviter := newTestInviter(t, `alice`)
+	first, err := inviter.Invite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := inviter.Invite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from1, key1 := parseTestInv(t, inviter, first)
+	from2, key2 := parseTestInv(t, inviter, second)
+	if from1 == `` || from1 != from2 {
+		t.Fatalf(`invitations should share the did of the inviter (%s, %s)`, from1, from2)
+	}
+
+	if bytes.Equal(key1, key2) {
+		t.Error(`invitations should have separate keys`)
+	}
+
+	// connection of the invitee established via the first invitation
+	prs, err := initPeerStore(store.NewMemory(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = prs.add(`alice`, models.Peer{DID: `did:peer:2.connection`, InvDID: from1, State: domain.ConnCompleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	label, pr, err := prs.peerByRef(from2)
+	if err != nil {
+		t.Fatalf(`no connection found for the second invitation - %v`, err)
+	}
+
+	if label != `alice` || pr.State != domain.ConnCompleted {
+		t.Errorf(`expected the completed connection with alice but got %s (%s)`, label, pr.State)
+	}
+
+	other := newTestInviter(t, `alice`)
+	otherInv, err := other.Invite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherFrom, _ := parseTestInv(t, other, otherInv)
+	if otherFrom == from1 {
+		t.Error(`inviters with the same label should not share the did`)
+	}
+
+	if _, _, err = prs.peerByRef(otherFrom); err == nil {
+		t.Error(`invitation of another inviter with the same label should not match the connection`)
+	}
+}
@@ -0,0 +1,128 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+)
+
+// accepts returns the envelope profiles supported by the agent
+// in the order of preference
+func (p *Prober) accepts() []string {
+	accept := []string{string(p.prefEnv)}
+	for _, env := range []domain.Envelope{domain.EnvelopeV2, domain.EnvelopeRFC19} {
+		if env != p.prefEnv {
+			accept = append(accept, string(env))
+		}
+	}
+	return accept
+}
+
+// envelope selects the first profile from the peer's accept list which is
+// supported by the agent. Peers which do not advertise any profile are
+// assumed to only support RFC-0019 envelopes.
+func (p *Prober) envelope(accept []string) domain.Envelope {
+	for _, a := range accept {
+		if _, ok := p.packers[domain.Envelope(a)]; ok {
+			return domain.Envelope(a)
+		}
+	}
+	return domain.EnvelopeRFC19
+}
+
+func (p *Prober) packer(env domain.Envelope) (services.Packer, error) {
+	pckr, ok := p.packers[env]
+	if !ok {
+		return nil, fmt.Errorf(`no packer found for the envelope profile %s`, env)
+	}
+	return pckr, nil
+}
+
+func (p *Prober) pack(env domain.Envelope, input, recPubKey, sendPubKey, sendPrvKey []byte) (messages.AuthCryptMsg, error) {
+	pckr, err := p.packer(env)
+	if err != nil {
+		return messages.AuthCryptMsg{}, err
+	}
+	return pckr.Pack(input, [][]byte{recPubKey}, sendPubKey, sendPrvKey)
+}
+
+func (p *Prober) packAnon(env domain.Envelope, input, recPubKey []byte) (messages.AuthCryptMsg, error) {
+	pckr, err := p.packer(env)
+	if err != nil {
+		return messages.AuthCryptMsg{}, err
+	}
+	return pckr.PackAnon(input, [][]byte{recPubKey})
+}
+
+// unpack detects the envelope profile of the message and decrypts it with
+// the corresponding packer. Sender key is nil for anoncrypted messages.
+func (p *Prober) unpack(data, recPubKey, recPrvKey []byte) (output, sendPubKey []byte, err error) {
+	_, env, err := p.parseEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pckr, err := p.packer(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pckr.Unpack(data, recPubKey, recPrvKey)
+}
+
+// parseEnvelope returns the recipient keys of the message along with its
+// envelope profile. DIDComm v2 messages list recipients in the top level of
+// the JWE whereas RFC-0019 messages include them in the protected header.
+func (p *Prober) parseEnvelope(data []byte) (recKeys [][]byte, env domain.Envelope, err error) {
+	var msg messages.AuthCryptMsg
+	if err = json.Unmarshal(data, &msg); err != nil {
+		return nil, ``, fmt.Errorf(`unmarshalling authcrypt message failed - %v`, err)
+	}
+
+	env = domain.EnvelopeRFC19
+	if len(msg.Recipients) != 0 {
+		env = domain.EnvelopeV2
+	}
+
+	pckr, err := p.packer(env)
+	if err != nil {
+		return nil, ``, err
+	}
+
+	recKeys, err = pckr.RecipientKeys(data)
+	if err != nil {
+		return nil, ``, fmt.Errorf(`parsing recipients failed - %v`, err)
+	}
+
+	return recKeys, env, nil
+}
+
+// PackMessage authcrypts the message to the message service of the peer
+func (p *Prober) PackMessage(peer string, body []byte) ([]byte, error) {
+	pr, err := p.peers.peerByLabel(peer)
+	if err != nil {
+		return nil, fmt.Errorf(`no didcomm connection found for %s - %v`, peer, err)
+	}
+
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return nil, fmt.Errorf(`getting message service failed - %v`, err)
+	}
+
+	// omitted errors since keys exist for all connections
+	ownPubKey, _ := p.ks.PublicKey(peer)
+	ownPrvKey, _ := p.ks.PrivateKey(peer)
+
+	msg, err := p.pack(pr.Envelope, body, prMsgPubKy, ownPubKey, ownPrvKey)
+	if err != nil {
+		return nil, fmt.Errorf(`packing message failed - %v`, err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling didcomm message failed - %v`, err)
+	}
+
+	return data, nil
+}
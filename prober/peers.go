@@ -3,64 +3,92 @@ package prober
 import (
 	"fmt"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/tryfix/log"
+	"strings"
 	"sync"
 )
 
+// peers serves the connections from memory while the store is
+// written through such that lookups do not read from the disk
 type peers struct {
-	store *sync.Map
-	log   log.Logger
+	db      services.ConnectionStore
+	cache   *sync.Map // key: label
+	exchIds *sync.Map // exchange thread id to label
+	log     log.Logger
 }
 
-func initPeerStore(l log.Logger) *peers {
-	return &peers{
-		store: &sync.Map{},
-		log:   l,
+func initPeerStore(db services.ConnectionStore, l log.Logger) (*peers, error) {
+	p := &peers{db: db, cache: &sync.Map{}, exchIds: &sync.Map{}, log: l}
+	prs, err := db.Peers()
+	if err != nil {
+		return nil, fmt.Errorf(`restoring connections failed - %v`, err)
 	}
+
+	for label, pr := range prs {
+		p.cache.Store(label, pr)
+		p.exchIds.Store(pr.ExchangeThId, label)
+	}
+	return p, nil
 }
 
 // name as the key may not be ideal
-func (p *peers) add(label string, pr models.Peer) {
-	p.store.Store(label, pr)
+func (p *peers) add(label string, pr models.Peer) error {
+	if err := p.db.AddPeer(label, pr); err != nil {
+		return fmt.Errorf(`storing peer %s failed - %v`, label, err)
+	}
+
+	p.cache.Store(label, pr)
+	p.exchIds.Store(pr.ExchangeThId, label)
+	return nil
 }
 
 func (p *peers) peerByLabel(label string) (models.Peer, error) {
-	val, ok := p.store.Load(label)
+	val, ok := p.cache.Load(label)
 	if !ok {
-		return models.Peer{}, fmt.Errorf(`requested peer (%s) does not exist in store`, label)
-	}
-
-	pr, ok := val.(models.Peer)
-	if !ok {
-		return models.Peer{}, fmt.Errorf(`invalid type found for peer %s (%v)`, label, val)
+		return models.Peer{}, fmt.Errorf(`requested peer (%s) does not exist`, label)
 	}
+	return val.(models.Peer), nil
+}
 
-	return pr, nil
+func (p *peers) all() map[string]models.Peer {
+	prs := map[string]models.Peer{}
+	p.cache.Range(func(key, val any) bool {
+		prs[key.(string)] = val.(models.Peer)
+		return true
+	})
+	return prs
 }
 
-// todo check
-func (p *peers) peerByExchId(exchId string) (name string, pr models.Peer, exists bool) {
-	p.store.Range(func(key, val any) bool {
-		tmpPr, ok := val.(models.Peer)
-		if !ok {
-			p.log.Error(fmt.Sprintf(`invalid type found for peer (%v)`, val))
-			return true
+// peerByRef returns the connection by its label or by the did of the peer,
+// where the did may also be the one which the peer used in its invitation
+func (p *peers) peerByRef(ref string) (label string, pr models.Peer, err error) {
+	if !strings.HasPrefix(ref, `did:`) {
+		pr, err = p.peerByLabel(ref)
+		return ref, pr, err
+	}
+
+	for label, tmpPr := range p.all() {
+		if tmpPr.DID == ref || tmpPr.InvDID == ref {
+			return label, tmpPr, nil
 		}
+	}
 
-		if tmpPr.ExchangeThId == exchId {
-			name, ok = key.(string)
-			if !ok {
-				p.log.Error(fmt.Sprintf(`peer name should be a string (%v)`, key))
-				return false
-			}
+	return ``, models.Peer{}, fmt.Errorf(`no connection found with the did %s`, ref)
+}
 
-			pr = tmpPr
-			exists = true
-			return false
-		}
+func (p *peers) peerByExchId(exchId string) (name string, pr models.Peer, exists bool) {
+	val, ok := p.exchIds.Load(exchId)
+	if !ok {
+		return
+	}
 
-		return true
-	})
+	name = val.(string)
+	pr, err := p.peerByLabel(name)
+	// exchange of the label may have been replaced by a later one
+	if err != nil || pr.ExchangeThId != exchId {
+		return ``, models.Peer{}, false
+	}
 
-	return
+	return name, pr, true
 }
@@ -0,0 +1,54 @@
+package prober
+
+import (
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/store"
+	"testing"
+)
+
+func TestPeers_Restore(t *testing.T) {
+	db := store.NewMemory()
+	if err := db.AddPeer(`bob`, models.Peer{DID: `did:peer:2.bob`, ExchangeThId: `exch1`, State: domain.ConnCompleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	prs, err := initPeerStore(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = prs.peerByLabel(`bob`); err != nil {
+		t.Errorf(`stored connection should be restored - %v`, err)
+	}
+
+	if label, _, ok := prs.peerByExchId(`exch1`); !ok || label != `bob` {
+		t.Errorf(`expected the connection with bob for the exchange but got '%s'`, label)
+	}
+}
+
+func TestPeers_ExchangeReplaced(t *testing.T) {
+	prs, err := initPeerStore(store.NewMemory(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exchId := range []string{`exch1`, `exch2`} {
+		if err = prs.add(`bob`, models.Peer{ExchangeThId: exchId}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, ok := prs.peerByExchId(`exch1`); ok {
+		t.Error(`replaced exchange should not match the connection`)
+	}
+
+	if _, _, ok := prs.peerByExchId(`exch2`); !ok {
+		t.Error(`current exchange should match the connection`)
+	}
+
+	stored, err := prs.db.Peer(`bob`)
+	if err != nil || stored.ExchangeThId != `exch2` {
+		t.Errorf(`connection should be written through to the store (err: %v)`, err)
+	}
+}
@@ -0,0 +1,191 @@
+package prober
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"sync"
+)
+
+/* Message pickup (Aries RFC-0685) where responses of the mediator are returned
+   via the return route since recipients may not have a reachable endpoint */
+
+var errDuplicate = errors.New(`message has already been received`)
+
+// received keeps the ids of the latest messages such that a message which was
+// queued by the mediator after a relay timed out is not processed again when
+// it is picked up
+type received struct {
+	ids   map[string]bool
+	order []string
+	lock  *sync.Mutex
+}
+
+func initReceived() *received {
+	return &received{ids: map[string]bool{}, lock: &sync.Mutex{}}
+}
+
+// first records the id and returns false if it was already received
+func (r *received) first(id string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.ids[id] {
+		return false
+	}
+
+	// queues of the mediator are limited and hence older ids are not retained
+	if len(r.order) == domain.MaxQueuedMsgs {
+		delete(r.ids, r.order[0])
+		r.order = r.order[1:]
+	}
+
+	r.ids[id] = true
+	r.order = append(r.order, id)
+	return true
+}
+
+// processPickup returns the packed status or delivery of the queued messages
+// of the connection which should be replied to the pickup request
+func (p *Prober) processPickup(msg models.Message) ([]byte, error) {
+	peerName, hdr, body, err := p.ReadRequest(msg)
+	if err != nil {
+		return nil, fmt.Errorf(`reading pickup request failed - %w`, err)
+	}
+
+	var res interface{}
+	switch hdr.Type {
+	case messages.PickupStatusReqV2:
+		var req messages.PickupStatusRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf(`unmarshalling status request failed - %v`, err)
+		}
+		res = p.pickupStatus(peerName, req.RecipientKey, req.Message)
+	case messages.PickupDeliveryReqV2:
+		var req messages.PickupDeliveryRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf(`unmarshalling delivery request failed - %v`, err)
+		}
+		res = p.pickupDelivery(peerName, req)
+	case messages.PickupReceivedV2:
+		var req messages.PickupReceived
+		if err = json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf(`unmarshalling messages-received failed - %v`, err)
+		}
+		if err = p.queue.remove(peerName, req.MessageIdList); err != nil {
+			return nil, problem.WithThread(hdr.ThId(), err)
+		}
+		res = p.pickupStatus(peerName, ``, req.Message)
+	default:
+		return nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`invalid message type for pickup (%s)`, hdr.Type))
+	}
+
+	// omitted error since responses only contain strings and raw json
+	byts, _ := json.Marshal(res)
+	return p.PackMessage(peerName, byts)
+}
+
+func (p *Prober) pickupStatus(peer, recKey string, req messages.Message) messages.PickupStatus {
+	qs := p.queue.status(peer, recKey)
+	s := messages.PickupStatus{
+		Message:              req.Reply(messages.PickupStatusV2),
+		RecipientKey:         recKey,
+		MessageCount:         qs.count,
+		LongestWaitedSeconds: qs.longestWaitedSec,
+		TotalBytes:           qs.totalBytes,
+	}
+
+	if qs.count != 0 {
+		s.OldestReceivedTime = qs.oldest.UTC().Format(messages.TimeFormat)
+		s.NewestReceivedTime = qs.newest.UTC().Format(messages.TimeFormat)
+	}
+	return s
+}
+
+// pickupDelivery returns a status instead of a delivery if there are no queued messages
+func (p *Prober) pickupDelivery(peer string, req messages.PickupDeliveryRequest) interface{} {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = domain.PickupBatchSize
+	}
+
+	msgs := p.queue.list(peer, req.RecipientKey, limit)
+	if len(msgs) == 0 {
+		return p.pickupStatus(peer, req.RecipientKey, req.Message)
+	}
+
+	d := messages.PickupDelivery{Message: req.Reply(messages.PickupDeliveryV2), RecipientKey: req.RecipientKey}
+	for _, m := range msgs {
+		att := messages.QueuedAttachment{Id: m.Id, MsgType: m.Type.String()}
+		att.Data.Json = m.Data
+		d.Attachments = append(d.Attachments, att)
+	}
+	return d
+}
+
+// QueuedMessages returns the number of messages queued by the mediator for the connection
+func (p *Prober) QueuedMessages(mediator string) (int, error) {
+	req := messages.PickupStatusRequest{Message: returnRouteMessage(messages.PickupStatusReqV2)}
+	var status messages.PickupStatus
+	if err := p.Request(mediator, models.TypPickup, req, &status); err != nil {
+		return 0, err
+	}
+
+	return status.MessageCount, nil
+}
+
+// Pickup collects the messages queued by the mediator in batches and processes
+// them as if they were received directly, until the queue is empty
+func (p *Prober) Pickup(mediator string) (n int, err error) {
+	for {
+		req := messages.PickupDeliveryRequest{Message: returnRouteMessage(messages.PickupDeliveryReqV2), Limit: domain.PickupBatchSize}
+		var d messages.PickupDelivery
+		if err = p.Request(mediator, models.TypPickup, req, &d); err != nil {
+			return n, err
+		}
+
+		// mediator responds with a status when the queue is empty
+		if d.Type == messages.PickupStatusV2 {
+			return n, nil
+		}
+
+		if d.Type != messages.PickupDeliveryV2 {
+			return n, fmt.Errorf(`invalid message type for delivery (%s)`, d.Type)
+		}
+
+		var ids []string
+		for _, att := range d.Attachments {
+			p.deliver(att)
+			ids = append(ids, att.Id)
+		}
+		n += len(ids)
+
+		ack := messages.PickupReceived{Message: returnRouteMessage(messages.PickupReceivedV2), MessageIdList: ids}
+		ack.SetThread(d.ThId(), ``)
+		var status messages.PickupStatus
+		if err = p.Request(mediator, models.TypPickup, ack, &status); err != nil {
+			return n, fmt.Errorf(`acknowledging delivered messages failed - %v`, err)
+		}
+
+		if status.MessageCount == 0 {
+			return n, nil
+		}
+	}
+}
+
+// deliver passes the queued message to the handlers of the agent via its own
+// endpoint. Failures are only logged since the message has been delivered.
+func (p *Prober) deliver(att messages.QueuedAttachment) {
+	mt, ok := models.MsgTypeByName(att.MsgType)
+	if !ok {
+		p.log.Error(fmt.Sprintf(`invalid message type of the queued message %s (%s)`, att.Id, att.MsgType))
+		return
+	}
+
+	if _, err := p.client.Send(mt, att.Data.Json, p.invEndpoint); err != nil {
+		p.log.Error(fmt.Sprintf(`processing queued '%s' message %s failed - %v`, mt, att.Id, err))
+	}
+}
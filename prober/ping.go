@@ -0,0 +1,185 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"sync"
+	"time"
+)
+
+// pendingPing is a trust ping waiting for the response of the peer
+type pendingPing struct {
+	peer string
+	res  chan bool
+}
+
+// pings tracks pending trust pings by id and the round-trip times per peer
+type pings struct {
+	pending *sync.Map
+	stats   map[string]models.PingStats
+	lock    *sync.Mutex
+}
+
+func initPings() *pings {
+	return &pings{
+		pending: &sync.Map{},
+		stats:   map[string]models.PingStats{},
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (p *pings) sent(peer string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.stats[peer]
+	s.Sent++
+	p.stats[peer] = s
+}
+
+func (p *pings) received(peer string, rtt time.Duration) models.PingStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := p.stats[peer]
+	s.Received++
+	s.Last = rtt
+	if s.Min == 0 || rtt < s.Min {
+		s.Min = rtt
+	}
+	if rtt > s.Max {
+		s.Max = rtt
+	}
+	s.Avg += (rtt - s.Avg) / time.Duration(s.Received)
+	p.stats[peer] = s
+	return s
+}
+
+func (p *pings) get(peer string) (models.PingStats, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s, ok := p.stats[peer]
+	return s, ok
+}
+
+// Ping sends a trust ping (Aries RFC-0048) requesting a response and waits
+// until the response is received or the timeout elapses
+func (p *Prober) Ping(peer string) (rtt time.Duration, err error) {
+	ping := messages.Ping{Message: messages.NewMessage(messages.TrustPingV1), ResponseRequested: true}
+	ping.ExpiresIn(domain.PingTimeoutMs * time.Millisecond)
+
+	byts, err := json.Marshal(ping)
+	if err != nil {
+		return 0, fmt.Errorf(`marshalling trust ping failed - %v`, err)
+	}
+
+	res := make(chan bool, 1)
+	p.pings.pending.Store(ping.Id, pendingPing{peer: peer, res: res})
+	defer p.pings.pending.Delete(ping.Id)
+
+	start := time.Now()
+	if err = p.send(models.TypTrustPing, peer, string(byts), false); err != nil {
+		return 0, fmt.Errorf(`sending trust ping failed - %w`, err)
+	}
+	p.pings.sent(peer)
+
+	select {
+	case <-res:
+		rtt = time.Since(start)
+	case <-time.After(domain.PingTimeoutMs * time.Millisecond):
+		return 0, fmt.Errorf(`trust ping to %s timed out after %dms`, peer, domain.PingTimeoutMs)
+	}
+
+	stats := p.pings.received(peer, rtt)
+	p.outChan <- fmt.Sprintf(`Ping response received from %s in %s (avg: %s, min: %s, max: %s)`, peer, rtt, stats.Avg, stats.Min, stats.Max)
+	return rtt, nil
+}
+
+func (p *Prober) PingStats(peer string) (stats models.PingStats, ok bool) {
+	return p.pings.get(peer)
+}
+
+// processPing responds to the ping once it is acknowledged if a response
+// is requested, since the sender may be pinging simultaneously
+func (p *Prober) processPing(msg models.Message) error {
+	peerName, body, err := p.ReadMessage(msg)
+	if err != nil {
+		return fmt.Errorf(`reading trust ping failed - %v`, err)
+	}
+
+	var ping messages.Ping
+	if err = json.Unmarshal([]byte(body), &ping); err != nil {
+		return fmt.Errorf(`unmarshalling trust ping failed - %v`, err)
+	}
+
+	if ping.Type != messages.TrustPingV1 {
+		return problem.WithThread(ping.ThId(), fmt.Errorf(`invalid message type for trust ping (%s)`, ping.Type))
+	}
+
+	if !ping.ResponseRequested {
+		return nil
+	}
+
+	if peerName == `` {
+		return problem.WithThread(ping.ThId(), fmt.Errorf(`response can not be sent to an anonymous trust ping`))
+	}
+
+	go p.sendPingResponse(peerName, ping.ThId())
+	return nil
+}
+
+func (p *Prober) sendPingResponse(peer, thId string) {
+	res := messages.PingResponse{Message: messages.NewMessage(messages.TrustPingResV1)}
+	res.SetThread(thId, ``)
+
+	// omitted error since the response only contains strings
+	byts, _ := json.Marshal(res)
+	if err := p.send(models.TypTrustPingRes, peer, string(byts), false); err != nil {
+		p.log.Error(fmt.Sprintf(`sending trust ping response to %s failed - %v`, peer, err))
+	}
+}
+
+func (p *Prober) processPingResponse(msg models.Message) error {
+	peerName, body, err := p.ReadMessage(msg)
+	if err != nil {
+		return fmt.Errorf(`reading trust ping response failed - %v`, err)
+	}
+
+	var res messages.PingResponse
+	if err = json.Unmarshal([]byte(body), &res); err != nil {
+		return fmt.Errorf(`unmarshalling trust ping response failed - %v`, err)
+	}
+
+	if res.Type != messages.TrustPingResV1 {
+		return problem.WithThread(res.ThId(), fmt.Errorf(`invalid message type for trust ping response (%s)`, res.Type))
+	}
+
+	// responses to pings attached to invitations are anoncrypted by invitees
+	if peerName == `` {
+		if _, err = p.invs.active(res.PThId()); err != nil {
+			return problem.WithThread(res.ThId(), fmt.Errorf(`anonymous trust ping response is not accepted - %v`, err))
+		}
+		p.outChan <- fmt.Sprintf(`Ping response received for the invitation %s`, res.PThId())
+		return nil
+	}
+
+	val, ok := p.pings.pending.Load(res.ThId())
+	if !ok {
+		return problem.WithThread(res.ThId(), fmt.Errorf(`no pending trust ping found for the response`))
+	}
+
+	pp := val.(pendingPing)
+	if pp.peer != peerName {
+		return problem.WithThread(res.ThId(), fmt.Errorf(`trust ping response of %s is not sent by %s`, pp.peer, peerName))
+	}
+
+	// duplicate responses are ignored
+	select {
+	case pp.res <- true:
+	default:
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package prober
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+)
+
+// reply acknowledges the message or replies with a problem report if processing failed
+func (p *Prober) reply(msg models.Message, code string, err error) {
+	if err == nil {
+		msg.Reply <- nil
+		return
+	}
+
+	// duplicates are acknowledged since the message has been processed
+	if errors.Is(err, errDuplicate) {
+		p.log.Debug(err)
+		msg.Reply <- nil
+		return
+	}
+
+	p.log.Error(err)
+	msg.Reply <- p.ReportProblem(msg, code, err)
+}
+
+// ReportProblem creates the problem report replied to a message which could not
+// be processed. The report is packed to the peer if the message was received via
+// a connection and otherwise it is replied in plaintext.
+func (p *Prober) ReportProblem(msg models.Message, code string, err error) []byte {
+	peerName, ownPubKey, ownPrvKey, keyErr := p.keysByMsg(msg.Data)
+	if keyErr != nil || peerName == `` {
+		return problem.Marshal(code, err)
+	}
+
+	pr, prErr := p.peers.peerByLabel(peerName)
+	if prErr != nil {
+		return problem.Marshal(code, err)
+	}
+
+	_, prMsgPubKy, svcErr := p.infoByServc(domain.ServcMessage, pr.Services)
+	if svcErr != nil {
+		return problem.Marshal(code, err)
+	}
+
+	// omitted error since the report only contains strings
+	report, _ := json.Marshal(problem.Report(code, err))
+	packed, packErr := p.pack(pr.Envelope, report, prMsgPubKy, ownPubKey, ownPrvKey)
+	if packErr != nil {
+		p.log.Error(fmt.Sprintf(`packing problem report for %s failed - %v`, peerName, packErr))
+		return problem.Marshal(code, err)
+	}
+
+	data, _ := json.Marshal(packed)
+	return data
+}
+
+// dispatch sends the message and returns the problem reported by the
+// recipient, either in plaintext or packed, as *domain.ProblemError
+func (p *Prober) dispatch(mt models.MsgType, data []byte, endpoint string) error {
+	res, err := p.client.Send(mt, data, endpoint)
+	if err != nil {
+		return err
+	}
+
+	if res == `` {
+		return nil
+	}
+
+	_, ownPubKey, ownPrvKey, err := p.keysByMsg([]byte(res))
+	if err != nil {
+		return fmt.Errorf(`getting keys of the reply failed - %v`, err)
+	}
+
+	body, _, err := p.unpack([]byte(res), ownPubKey, ownPrvKey)
+	if err != nil {
+		return fmt.Errorf(`unpacking reply failed - %v`, err)
+	}
+
+	return problem.Parse(body)
+}
@@ -0,0 +1,120 @@
+package prober
+
+import (
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// queue holds the messages of offline recipients until they are picked up
+// via the connection which registered the route. Messages are persisted in
+// the connection store such that they survive restarts of the mediator.
+type queue struct {
+	db   services.ConnectionStore
+	msgs map[string][]models.QueuedMsg // key: label of the connection
+	lock *sync.Mutex
+}
+
+// queueStatus summarizes the queued messages of a recipient
+type queueStatus struct {
+	count            int
+	totalBytes       int
+	oldest, newest   time.Time
+	longestWaitedSec int
+}
+
+func initQueue(db services.ConnectionStore) (*queue, error) {
+	msgs, err := db.Queues()
+	if err != nil {
+		return nil, fmt.Errorf(`restoring queued messages failed - %v`, err)
+	}
+
+	return &queue{db: db, msgs: msgs, lock: &sync.Mutex{}}, nil
+}
+
+func (q *queue) add(peer, recKey string, mt models.MsgType, data []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.msgs[peer]) >= domain.MaxQueuedMsgs {
+		return fmt.Errorf(`queue of %s is full (%d messages)`, peer, domain.MaxQueuedMsgs)
+	}
+
+	msgs := append(q.msgs[peer], models.QueuedMsg{Id: uuid.New().String(), RecKey: recKey, Type: mt, Data: data, Received: time.Now()})
+	return q.save(peer, msgs)
+}
+
+// list returns the oldest messages of the recipient key, or of all
+// keys of the connection if the key is empty
+func (q *queue) list(peer, recKey string, limit int) []models.QueuedMsg {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var msgs []models.QueuedMsg
+	for _, m := range q.msgs[peer] {
+		if len(msgs) == limit {
+			break
+		}
+
+		if recKey == `` || m.RecKey == recKey {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// remove deletes the messages with the given ids while unknown ids are ignored
+func (q *queue) remove(peer string, ids []string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	received := map[string]bool{}
+	for _, id := range ids {
+		received[id] = true
+	}
+
+	var msgs []models.QueuedMsg
+	for _, m := range q.msgs[peer] {
+		if !received[m.Id] {
+			msgs = append(msgs, m)
+		}
+	}
+
+	return q.save(peer, msgs)
+}
+
+func (q *queue) save(peer string, msgs []models.QueuedMsg) error {
+	if err := q.db.SaveQueue(peer, msgs); err != nil {
+		return fmt.Errorf(`storing queue of %s failed - %v`, peer, err)
+	}
+
+	if len(msgs) == 0 {
+		delete(q.msgs, peer)
+		return nil
+	}
+	q.msgs[peer] = msgs
+	return nil
+}
+
+func (q *queue) status(peer, recKey string) (s queueStatus) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, m := range q.msgs[peer] {
+		if recKey != `` && m.RecKey != recKey {
+			continue
+		}
+
+		if s.count == 0 {
+			s.oldest = m.Received
+		}
+		s.newest = m.Received
+		s.count++
+		s.totalBytes += len(m.Data)
+	}
+
+	if s.count != 0 {
+		s.longestWaitedSec = int(time.Since(s.oldest).Seconds())
+	}
+	return s
+}
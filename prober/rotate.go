@@ -0,0 +1,279 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"time"
+)
+
+// Rotate generates new keys and a DID for the connection with peer and
+// notifies the peer with a rotate message packed with the previous keys
+// (Aries RFC-0794). Messages to the previous keys are accepted until the
+// grace period elapses since the peer may not have processed the rotation.
+// The previous keys and DID are restored only if the rotate message was not
+// sent, since the peer may have already rotated the connection otherwise.
+func (p *Prober) Rotate(peer string) error {
+	pr, err := p.peers.peerByLabel(peer)
+	if err != nil {
+		return fmt.Errorf(`no didcomm connection found for %s - %v`, peer, err)
+	}
+
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return fmt.Errorf(`getting message endpoint failed - %v`, err)
+	}
+
+	prevPubKey, err := p.ks.PublicKey(peer)
+	if err != nil {
+		return fmt.Errorf(`getting public key for connection with %s failed - %v`, peer, err)
+	}
+
+	prevPrvKey, err := p.ks.PrivateKey(peer)
+	if err != nil {
+		return fmt.Errorf(`getting private key for connection with %s failed - %v`, peer, err)
+	}
+
+	prevDid, prevDoc, err := p.didStore.get(peer)
+	if err != nil {
+		return fmt.Errorf(`fetching dids failed - %v`, err)
+	}
+
+	if err = p.ks.RotateKeys(peer, domain.RotationGracePeriodMs*time.Millisecond); err != nil {
+		return fmt.Errorf(`rotating keys failed - %v`, err)
+	}
+
+	// omitted error since keys are generated above
+	pubKey, _ := p.ks.PublicKey(peer)
+	if err = p.createDID(peer, pubKey); err != nil {
+		p.restoreKeys(peer, prevPubKey)
+		return err
+	}
+
+	did, err := p.notifyRotation(peer, pr, prMsgPubKy, prevPubKey, prevPrvKey)
+	if services.Undelivered(err) {
+		p.revertRotation(peer, prevPubKey, pubKey, prevDid, prevDoc)
+		return err
+	}
+
+	p.retireMediatedKey(peer, prevPubKey)
+	if err != nil {
+		// the rotate ack confirms the rotation if the peer processed the message
+		return fmt.Errorf(`rotate message may not have been processed by %s and hence the new did is retained - %w`, peer, err)
+	}
+
+	p.log.Trace(fmt.Sprintf(`rotate message sent to %s with the new did %s`, peer, did))
+	return nil
+}
+
+// notifyRotation sends the rotate message with the new DID of the connection
+// which is packed with the previous keys to prove the control of the connection.
+// Errors are marked by services.NotSent unless the message has been sent.
+func (p *Prober) notifyRotation(peer string, pr models.Peer, prMsgPubKy, prevPubKey, prevPrvKey []byte) (did string, err error) {
+	did, doc, err := p.didStore.get(peer)
+	if err != nil {
+		return ``, services.NotSent(fmt.Errorf(`fetching dids failed - %v`, err))
+	}
+
+	rm, err := p.conn.CreateRotate(did, doc)
+	if err != nil {
+		return ``, services.NotSent(fmt.Errorf(`creating rotate message failed - %v`, err))
+	}
+
+	rmBytes, err := json.Marshal(rm)
+	if err != nil {
+		return ``, services.NotSent(fmt.Errorf(`marshalling rotate message failed - %v`, err))
+	}
+
+	msg, err := p.pack(pr.Envelope, rmBytes, prMsgPubKy, prevPubKey, prevPrvKey)
+	if err != nil {
+		return ``, services.NotSent(fmt.Errorf(`packing rotate message failed - %v`, err))
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return ``, services.NotSent(fmt.Errorf(`marshalling didcomm message failed - %v`, err))
+	}
+
+	if err = p.forward(pr.Envelope, models.TypDIDRotate, data, pr.Services); err != nil {
+		return ``, fmt.Errorf(`sending rotate message failed - %w`, err)
+	}
+
+	return did, nil
+}
+
+// revertRotation restores the previous keys and DID of the connection since
+// the peer, which was not notified, would not reach the agent once the grace
+// period of the previous keys elapses, and removes the new key from the
+// mediator if registered
+func (p *Prober) revertRotation(peer string, prevPubKey, pubKey []byte, prevDid string, prevDoc messages.DIDDocument) {
+	p.restoreKeys(peer, prevPubKey)
+	if err := p.didStore.add(peer, prevDid, prevDoc); err != nil {
+		p.log.Error(fmt.Sprintf(`restoring the previous did of the connection with %s failed - %v`, peer, err))
+	}
+
+	if mediator, _, _, ok := p.mediation.get(); ok && mediator != peer {
+		if err := p.updateKeylist(mediator, pubKey, messages.KeylistRemove); err != nil {
+			p.log.Error(fmt.Sprintf(`removing the key of the reverted rotation with %s from the mediator failed - %v`, peer, err))
+		}
+	}
+}
+
+func (p *Prober) restoreKeys(peer string, prevPubKey []byte) {
+	if err := p.ks.RestoreKeys(peer, prevPubKey); err != nil {
+		p.log.Error(fmt.Sprintf(`restoring the previous keys of the connection with %s failed - %v`, peer, err))
+	}
+}
+
+// processRotate updates the DID and services of the peer if the rotate
+// message is authenticated with the current key of the peer
+func (p *Prober) processRotate(msg models.Message) error {
+	peerName, ownPubKey, ownPrvKey, err := p.keysByMsg(msg.Data)
+	if err != nil {
+		return fmt.Errorf(`getting peer info failed - %v`, err)
+	}
+
+	body, sendPubKey, err := p.unpack(msg.Data, ownPubKey, ownPrvKey)
+	if err != nil {
+		return fmt.Errorf(`unpacking rotate message failed - %v`, err)
+	}
+
+	// reports refer to the thread of the rotation even if the message is invalid
+	var hdr messages.Message
+	_ = json.Unmarshal(body, &hdr)
+
+	pr, err := p.peers.peerByLabel(peerName)
+	if err != nil {
+		return problem.WithThread(hdr.ThId(), fmt.Errorf(`no didcomm connection found for %s - %v`, peerName, err))
+	}
+
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return problem.WithThread(hdr.ThId(), fmt.Errorf(`getting message service of %s failed - %v`, peerName, err))
+	}
+
+	if sendPubKey == nil || !bytes.Equal(sendPubKey, prMsgPubKy) {
+		return problem.WithThread(hdr.ThId(), fmt.Errorf(`rotate message is not authenticated with the current key of %s`, peerName))
+	}
+
+	thId, did, docBytes, err := p.conn.ParseRotate(body)
+	if err != nil {
+		return problem.WithThread(hdr.ThId(), fmt.Errorf(`parsing rotate message failed - %v`, err))
+	}
+
+	doc, err := p.rotatedDoc(did, docBytes)
+	if err != nil {
+		return problem.WithThread(thId, err)
+	}
+
+	svcs, err := p.servicesByDoc(doc)
+	if err != nil {
+		return problem.WithThread(thId, fmt.Errorf(`getting peer data failed - %v`, err))
+	}
+
+	pr.DID, pr.Services = did, svcs
+	if err = p.peers.add(peerName, pr); err != nil {
+		return err
+	}
+	p.outChan <- `Connection with ` + peerName + ` rotated to ` + did
+
+	// ack is sent asynchronously since the peer may be blocked until the rotate is replied
+	go func() {
+		if err := p.sendRotateAck(peerName, thId, pr); err != nil {
+			p.log.Error(fmt.Sprintf(`acknowledging rotation of %s failed - %v`, peerName, err))
+		}
+	}()
+	return nil
+}
+
+// rotatedDoc resolves the new did of the peer and falls back to the attached
+// did doc for dids which can not be resolved, in which case the did should
+// be derived from the attachment (did:peer:1)
+func (p *Prober) rotatedDoc(did string, docBytes []byte) (messages.DIDDocument, error) {
+	if err := p.did.ValidatePeerDID(did); err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`invalid did to rotate - %v`, err)
+	}
+
+	if doc, err := p.did.ResolvePeerDID(did); err == nil {
+		return doc, nil
+	}
+
+	var doc messages.DIDDocument
+	if err := json.Unmarshal(docBytes, &doc); err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`unmarshalling did doc failed - %v`, err)
+	}
+
+	docDid, err := p.did.CreatePeerDID(doc)
+	if err != nil {
+		return messages.DIDDocument{}, fmt.Errorf(`deriving did from did doc failed - %v`, err)
+	}
+
+	if docDid != did {
+		return messages.DIDDocument{}, fmt.Errorf(`did (%s) does not match the attached did doc`, did)
+	}
+
+	return doc, nil
+}
+
+// sendRotateAck acknowledges the rotation via the new services of the peer
+func (p *Prober) sendRotateAck(peer, thId string, pr models.Peer) error {
+	_, prMsgPubKy, err := p.infoByServc(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return fmt.Errorf(`getting message endpoint failed - %v`, err)
+	}
+
+	ownPubKey, err := p.ks.PublicKey(peer)
+	if err != nil {
+		return fmt.Errorf(`getting public key for connection with %s failed - %v`, peer, err)
+	}
+
+	ownPrvKey, err := p.ks.PrivateKey(peer)
+	if err != nil {
+		return fmt.Errorf(`getting private key for connection with %s failed - %v`, peer, err)
+	}
+
+	ackBytes, err := json.Marshal(p.conn.CreateRotateAck(thId))
+	if err != nil {
+		return fmt.Errorf(`marshalling rotate ack failed - %v`, err)
+	}
+
+	msg, err := p.pack(pr.Envelope, ackBytes, prMsgPubKy, ownPubKey, ownPrvKey)
+	if err != nil {
+		return fmt.Errorf(`packing rotate ack failed - %v`, err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf(`marshalling didcomm message failed - %v`, err)
+	}
+
+	if err = p.forward(pr.Envelope, models.TypDIDRotateAck, data, pr.Services); err != nil {
+		return fmt.Errorf(`sending rotate ack failed - %w`, err)
+	}
+
+	return nil
+}
+
+func (p *Prober) processRotateAck(msg models.Message) error {
+	peerName, body, err := p.ReadMessage(msg)
+	if err != nil {
+		return fmt.Errorf(`reading rotate ack failed - %v`, err)
+	}
+
+	var ack messages.RotateAck
+	if err = json.Unmarshal([]byte(body), &ack); err != nil {
+		return fmt.Errorf(`unmarshalling rotate ack failed - %v`, err)
+	}
+
+	if ack.Type != messages.DIDRotateAckV1 {
+		return problem.WithThread(ack.ThId(), fmt.Errorf(`invalid message type for rotate ack (%s)`, ack.Type))
+	}
+
+	p.outChan <- `Rotation acknowledged by ` + peerName
+	return nil
+}
@@ -0,0 +1,227 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/YasiruR/didcomm-prober/crypto"
+	"github.com/YasiruR/didcomm-prober/didcomm/connection"
+	"github.com/YasiruR/didcomm-prober/didcomm/did"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/YasiruR/didcomm-prober/store"
+	"github.com/tryfix/log"
+	"testing"
+	"time"
+)
+
+// testClient delivers the messages to the handlers of the agent serving the
+// endpoint unless a failure is set for the message type, in which case the
+// message is delivered before the failure if it was sent
+type testClient struct {
+	services.Client
+	agents   map[string]*Prober
+	failures map[models.MsgType]error
+}
+
+func (c *testClient) Send(typ models.MsgType, data []byte, endpoint string) (string, error) {
+	err, failed := c.failures[typ]
+	if failed && services.Undelivered(err) {
+		return ``, err
+	}
+
+	msg := models.Message{Type: typ, Data: data}
+	switch typ {
+	case models.TypDIDRotate:
+		if procErr := c.agents[endpoint].processRotate(msg); procErr != nil && !failed {
+			return ``, procErr
+		}
+	case models.TypDIDRotateAck:
+		if procErr := c.agents[endpoint].processRotateAck(msg); procErr != nil && !failed {
+			return ``, procErr
+		}
+	}
+
+	return ``, err
+}
+
+func newTestAgent(label string, client services.Client) *Prober {
+	logger := log.Constructor.Log(log.WithLevel(log.FATAL))
+	db := store.NewMemory()
+	prs, _ := initPeerStore(db, logger)
+	return &Prober{
+		label:           label,
+		exchEndpoint:    label,
+		grpJoinEndpoint: label,
+		ks:              crypto.NewKeyManager(),
+		packers:         map[domain.Envelope]services.Packer{domain.EnvelopeRFC19: crypto.NewPacker(logger)},
+		prefEnv:         domain.EnvelopeRFC19,
+		did:             did.NewHandler(),
+		conn:            connection.NewConnector(),
+		peers:           prs,
+		didStore:        initDIDStore(db),
+		outChan:         make(chan string, 10),
+		log:             logger,
+		client:          client,
+		mediation:       initMediation(db),
+		received:        initReceived(),
+	}
+}
+
+// connectTestAgents sets up a completed connection between the agents
+// without the exchange protocol
+func connectTestAgents(t *testing.T, failures map[models.MsgType]error) (alice, bob *Prober) {
+	c := &testClient{agents: map[string]*Prober{}, failures: failures}
+	alice, bob = newTestAgent(`alice`, c), newTestAgent(`bob`, c)
+	c.agents[`alice`], c.agents[`bob`] = alice, bob
+
+	for _, pair := range [][2]*Prober{{alice, bob}, {bob, alice}} {
+		if _, _, err := pair[0].setConnPrereqs(pair[1].label); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, pair := range [][2]*Prober{{alice, bob}, {bob, alice}} {
+		peerDid, doc, err := pair[1].didStore.get(pair[0].label)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		svcs, err := pair[0].servicesByDoc(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pr := models.Peer{DID: peerDid, Services: svcs, Envelope: domain.EnvelopeRFC19, State: domain.ConnCompleted}
+		if err = pair[0].peers.add(pair[1].label, pr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return alice, bob
+}
+
+func TestRotate(t *testing.T) {
+	alice, bob := connectTestAgents(t, nil)
+	prevPubKey, _ := alice.ks.PublicKey(`bob`)
+	if err := alice.Rotate(`bob`); err != nil {
+		t.Fatalf(`rotation failed - %v`, err)
+	}
+
+	did, _, _ := alice.didStore.get(`bob`)
+	pr, _ := bob.peers.peerByLabel(`alice`)
+	if pr.DID != did {
+		t.Errorf(`expected bob to rotate the connection to %s but got %s`, did, pr.DID)
+	}
+
+	pubKey, _ := alice.ks.PublicKey(`bob`)
+	if _, svcKey, _ := bob.infoByServc(domain.ServcMessage, pr.Services); !bytes.Equal(svcKey, pubKey) || bytes.Equal(pubKey, prevPubKey) {
+		t.Error(`bob should send messages to the new key of alice`)
+	}
+
+	// messages packed before the rotation are accepted during the grace period
+	if _, err := alice.ks.PrivateKeyByPubKey(prevPubKey); err != nil {
+		t.Errorf(`previous keys should be retained for the grace period - %v`, err)
+	}
+
+	select {
+	case out := <-alice.outChan:
+		if out != `Rotation acknowledged by bob` {
+			t.Errorf(`unexpected output (%s)`, out)
+		}
+	case <-time.After(time.Second):
+		t.Error(`rotation was not acknowledged by bob`)
+	}
+}
+
+func TestRotate_NotSent(t *testing.T) {
+	alice, bob := connectTestAgents(t, map[models.MsgType]error{models.TypDIDRotate: services.NotSent(errors.New(`connection refused`))})
+	prevDid, _, _ := alice.didStore.get(`bob`)
+	prevPubKey, _ := alice.ks.PublicKey(`bob`)
+	if err := alice.Rotate(`bob`); err == nil {
+		t.Fatal(`rotation should fail if the rotate message is not sent`)
+	}
+
+	did, _, _ := alice.didStore.get(`bob`)
+	pubKey, _ := alice.ks.PublicKey(`bob`)
+	if did != prevDid || !bytes.Equal(pubKey, prevPubKey) {
+		t.Error(`previous did and keys should be restored if bob was not notified`)
+	}
+
+	if pr, _ := bob.peers.peerByLabel(`alice`); pr.DID != prevDid {
+		t.Errorf(`bob should not rotate the connection (did: %s)`, pr.DID)
+	}
+}
+
+// TestRotate_Unconfirmed checks that the new did is retained if the rotate
+// message was sent, even if the reply failed, since the peer may have rotated
+func TestRotate_Unconfirmed(t *testing.T) {
+	alice, bob := connectTestAgents(t, map[models.MsgType]error{models.TypDIDRotate: errors.New(`no reply received`)})
+	prevDid, _, _ := alice.didStore.get(`bob`)
+	if err := alice.Rotate(`bob`); err == nil {
+		t.Fatal(`rotation should report the failed reply`)
+	}
+
+	did, _, _ := alice.didStore.get(`bob`)
+	if did == prevDid {
+		t.Fatal(`new did should be retained if the rotate message was sent`)
+	}
+
+	if pr, _ := bob.peers.peerByLabel(`alice`); pr.DID != did {
+		t.Errorf(`expected connection to remain in sync with %s but bob has %s`, did, pr.DID)
+	}
+
+	pubKey, _ := alice.ks.PublicKey(`bob`)
+	pr, _ := bob.peers.peerByLabel(`alice`)
+	if _, svcKey, _ := bob.infoByServc(domain.ServcMessage, pr.Services); !bytes.Equal(svcKey, pubKey) {
+		t.Error(`bob should send messages to the key held by alice`)
+	}
+}
+
+func TestProcessRotate_Unauthenticated(t *testing.T) {
+	alice, bob := connectTestAgents(t, nil)
+	prevDid, _, _ := alice.didStore.get(`bob`)
+
+	// rotate message addressed to the connection of bob with alice but packed with other keys
+	ks := crypto.NewKeyManager()
+	if err := ks.GenerateKeys(`mallory`); err != nil {
+		t.Fatal(err)
+	}
+	pubKey, _ := ks.PublicKey(`mallory`)
+	prvKey, _ := ks.PrivateKey(`mallory`)
+
+	did, doc, err := alice.didStore.get(`bob`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := alice.conn.CreateRotate(did, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rmBytes, err := json.Marshal(rm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bobPubKey, _ := bob.ks.PublicKey(`alice`)
+	msg, err := alice.pack(domain.EnvelopeRFC19, rmBytes, bobPubKey, pubKey, prvKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = bob.processRotate(models.Message{Type: models.TypDIDRotate, Data: data}); err == nil {
+		t.Error(`rotate message which is not packed with the key of alice should be rejected`)
+	}
+
+	if pr, _ := bob.peers.peerByLabel(`alice`); pr.DID != prevDid {
+		t.Errorf(`connection should not be rotated (did: %s)`, pr.DID)
+	}
+}
@@ -0,0 +1,186 @@
+package prober
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// forward sends the message to the message service of the peer, via the
+// mediators if the service lists routing keys (Aries RFC-0094)
+func (p *Prober) forward(env domain.Envelope, mt models.MsgType, data []byte, svcs []models.Service) error {
+	svc, err := serviceByType(domain.ServcMessage, svcs)
+	if err != nil {
+		return services.NotSent(err)
+	}
+
+	if len(svc.RoutingKeys) == 0 {
+		return p.dispatch(mt, data, svc.Endpoint)
+	}
+
+	wrapped, err := p.wrap(env, mt, data, svc.PubKey, svc.RoutingKeys, false)
+	if err != nil {
+		return services.NotSent(fmt.Errorf(`wrapping message in forward messages failed - %v`, err))
+	}
+
+	// service endpoint is the endpoint of the outermost mediator
+	return p.dispatch(models.TypForward, wrapped, svc.Endpoint)
+}
+
+// wrap nests the message in a forward message per routing key, each of which
+// is anoncrypted to the routing key and addressed to the key of the previous
+// layer, such that the last routing key is unwrapped first. Each layer enables
+// the return route if the reply of the recipient is expected.
+func (p *Prober) wrap(env domain.Envelope, mt models.MsgType, data, recKey []byte, routingKeys [][]byte, returnRoute bool) ([]byte, error) {
+	to := recKey
+	for i, rk := range routingKeys {
+		fwd := messages.Forward{Message: messages.NewMessage(messages.ForwardV1), To: base58.Encode(to), Msg: data}
+		if returnRoute {
+			fwd.Message = returnRouteMessage(messages.ForwardV1)
+		}
+
+		if i == 0 {
+			fwd.MsgType = mt.String()
+		}
+
+		byts, err := json.Marshal(fwd)
+		if err != nil {
+			return nil, fmt.Errorf(`marshalling forward message failed - %v`, err)
+		}
+
+		packed, err := p.packAnon(env, byts, rk)
+		if err != nil {
+			return nil, fmt.Errorf(`packing forward message failed - %v`, err)
+		}
+
+		if data, err = json.Marshal(packed); err != nil {
+			return nil, fmt.Errorf(`marshalling didcomm message failed - %v`, err)
+		}
+		to = rk
+	}
+
+	return data, nil
+}
+
+func serviceByType(filter string, svcs []models.Service) (models.Service, error) {
+	for _, s := range svcs {
+		if s.Type == filter {
+			return s, nil
+		}
+	}
+	return models.Service{}, fmt.Errorf(`services does not contain %s`, filter)
+}
+
+/* Requests of which the responses are returned via the return route (Aries RFC-0092)
+   since they are only expected by the sender while it is connected */
+
+// returnRouteMessage creates a message which expects the response via the return route
+func returnRouteMessage(typ string) messages.Message {
+	m := messages.NewMessage(typ)
+	m.Transport = &messages.TransportDecorator{ReturnRoute: messages.ReturnRouteAll}
+	return m
+}
+
+// Request sends the message to the peer, given by the label or the did of the
+// connection, and unmarshalls the response returned via the return route into res
+func (p *Prober) Request(peer string, mt models.MsgType, req, res interface{}) error {
+	label, pr, err := p.peers.peerByRef(peer)
+	if err != nil {
+		return fmt.Errorf(`no didcomm connection found for %s - %v`, peer, err)
+	}
+
+	reqByts, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf(`marshalling request failed - %v`, err)
+	}
+
+	var hdr messages.Message
+	if err = json.Unmarshal(reqByts, &hdr); err != nil {
+		return fmt.Errorf(`request is not a didcomm message - %v`, err)
+	}
+
+	data, err := p.PackMessage(label, reqByts)
+	if err != nil {
+		return err
+	}
+
+	svc, err := serviceByType(domain.ServcMessage, pr.Services)
+	if err != nil {
+		return fmt.Errorf(`getting message endpoint failed - %v`, err)
+	}
+
+	// mediators relay the request and return the reply of the peer
+	sendType := mt
+	if len(svc.RoutingKeys) != 0 {
+		if data, err = p.wrap(pr.Envelope, mt, data, svc.PubKey, svc.RoutingKeys, true); err != nil {
+			return fmt.Errorf(`wrapping request in forward messages failed - %v`, err)
+		}
+		sendType = models.TypForward
+	}
+
+	reply, err := p.client.Send(sendType, data, svc.Endpoint)
+	if err != nil {
+		return fmt.Errorf(`sending '%s' failed - %w`, hdr.Type, err)
+	}
+
+	_, resBody, err := p.ReadMessage(models.Message{Type: mt, Data: []byte(reply)})
+	if err != nil {
+		return fmt.Errorf(`reading response of '%s' failed - %w`, hdr.Type, err)
+	}
+
+	var resHdr messages.Message
+	if err = json.Unmarshal([]byte(resBody), &resHdr); err != nil {
+		return fmt.Errorf(`unmarshalling response of '%s' failed - %v`, hdr.Type, err)
+	}
+
+	if resHdr.ThId() != hdr.ThId() {
+		return fmt.Errorf(`response does not belong to '%s' (%s)`, hdr.Type, resHdr.ThId())
+	}
+
+	if err = json.Unmarshal([]byte(resBody), res); err != nil {
+		return fmt.Errorf(`unmarshalling response of '%s' failed - %v`, hdr.Type, err)
+	}
+
+	p.log.Trace(fmt.Sprintf(`response of '%s' received from %s - %s`, hdr.Type, label, resBody))
+	return nil
+}
+
+// ReadRequest reads a message received via a connection which expects
+// the response via the return route
+func (p *Prober) ReadRequest(msg models.Message) (peer string, hdr messages.Message, body []byte, err error) {
+	peer, text, err := p.ReadMessage(msg)
+	if err != nil {
+		return ``, hdr, nil, err
+	}
+
+	body = []byte(text)
+	if err = json.Unmarshal(body, &hdr); err != nil {
+		return ``, hdr, nil, fmt.Errorf(`unmarshalling message failed - %v`, err)
+	}
+
+	if peer == `` {
+		return ``, hdr, nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`'%s' can not be processed for an anonymous sender`, hdr.Type))
+	}
+
+	if hdr.Transport == nil || hdr.Transport.ReturnRoute == `` || hdr.Transport.ReturnRoute == messages.ReturnRouteNone {
+		return ``, hdr, nil, problem.WithThread(hdr.ThId(), fmt.Errorf(`'%s' does not enable the return route`, hdr.Type))
+	}
+
+	return peer, hdr, body, nil
+}
+
+// respond replies the response of a request via the return route or a problem
+// report if processing failed
+func (p *Prober) respond(msg models.Message, code string, process func(msg models.Message) ([]byte, error)) {
+	res, err := process(msg)
+	if err != nil {
+		p.reply(msg, code, err)
+		return
+	}
+	msg.Reply <- res
+}
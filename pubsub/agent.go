@@ -12,7 +12,6 @@ import (
 	"github.com/YasiruR/didcomm-prober/pubsub/transport"
 	"github.com/YasiruR/didcomm-prober/pubsub/validator"
 	"github.com/btcsuite/btcutil/base58"
-	"github.com/google/uuid"
 	zmqPkg "github.com/pebbe/zmq4"
 	"github.com/tryfix/log"
 	"net/url"
@@ -33,6 +32,7 @@ type state struct {
 
 type services struct {
 	km     servicesPkg.KeyManager
+	db     servicesPkg.GroupStore
 	probr  servicesPkg.Agent
 	client servicesPkg.Client
 	log    log.Logger
@@ -62,15 +62,21 @@ func NewAgent(zmqCtx *zmqPkg.Context, c *container.Container) (*Agent, error) {
 		return nil, fmt.Errorf(`initializing internal services of group agent failed - %v`, err)
 	}
 
+	invs, err := c.GroupStore.Invitations()
+	if err != nil {
+		return nil, fmt.Errorf(`restoring group invitations failed - %v`, err)
+	}
+
 	a := &Agent{
 		state: &state{
 			myLabel:     c.Cfg.Name,
 			pubEndpoint: c.Cfg.PubEndpoint,
-			invs:        make(map[string]string),
+			invs:        invs,
 		},
 		internals: in,
 		services: &services{
 			km:     c.KeyManager,
+			db:     c.GroupStore,
 			probr:  c.Prober,
 			client: c.Client,
 			log:    c.Log,
@@ -85,24 +91,34 @@ func NewAgent(zmqCtx *zmqPkg.Context, c *container.Container) (*Agent, error) {
 	}
 	a.zmq = tr
 	a.start(c.Server)
+	go a.rejoinAll()
 
 	return a, nil
 }
 
 // initInternals initializes the internal components required by the group agent
 func initInternals(c *container.Container) (*internals, error) {
-	gs := stores.NewGroupStore()
+	gs, err := stores.NewGroupStore(c.GroupStore)
+	if err != nil {
+		return nil, fmt.Errorf(`initializing group store failed - %v`, err)
+	}
+
+	subs, err := stores.NewSubStore(c.GroupStore)
+	if err != nil {
+		return nil, fmt.Errorf(`initializing subscriber store failed - %v`, err)
+	}
+
 	compctr, err := newCompactor()
 	if err != nil {
 		return nil, fmt.Errorf(`initializing compressor failed - %v`, err)
 	}
 
 	return &internals{
-		subs:     stores.NewSubStore(),
+		subs:     subs,
 		gs:       gs,
 		compactr: compctr,
 		syncr:    newSyncer(gs),
-		packr:    newPacker(c),
+		packr:    newPacker(c, gs),
 		peers:    transport.InitPeerStore(c),
 	}, nil
 }
@@ -120,8 +136,8 @@ func (a *Agent) start(srvr servicesPkg.Server) {
 	srvr.AddHandler(models.TypGroupJoin, joinChan, false)
 
 	// initialize internal handlers for zmq requests on REQ sockets
-	a.process(joinChan, a.proc.joinReqs)
-	a.process(subChan, a.proc.subscriptions)
+	a.process(joinChan, domain.ProblemJoinNotAccepted, a.proc.joinReqs)
+	a.process(subChan, domain.ProblemSubNotAccepted, a.proc.subscriptions)
 }
 
 // Create constructs a group including creator's invitation
@@ -132,15 +148,15 @@ func (a *Agent) Create(topic string, publisher bool, gp models.GroupParams) erro
 		return fmt.Errorf(`generating invitation failed - %v`, err)
 	}
 
-	m := models.Member{
-		Active:      true,
-		Publisher:   publisher,
-		Label:       a.myLabel,
-		Inv:         inv,
-		PubEndpoint: a.pubEndpoint,
+	if err = a.setInv(topic, inv); err != nil {
+		return err
+	}
+
+	m, err := a.member(topic, true, publisher, gp.Signed)
+	if err != nil {
+		return err
 	}
 
-	a.invs[topic] = inv
 	if err = a.gs.SetParams(topic, gp); err != nil {
 		return fmt.Errorf(`updating group params failed - %v`, err)
 	}
@@ -169,19 +185,38 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 		return fmt.Errorf(`generating invitation failed - %v`, err)
 	}
 
-	a.invs[topic] = inv
+	if err = a.setInv(topic, inv); err != nil {
+		return err
+	}
+
 	group, err := a.reqState(topic, acceptor, inv)
 	if err != nil {
-		return fmt.Errorf(`requesting group state from %s failed - %v`, acceptor, err)
+		return fmt.Errorf(`requesting group state from %s failed - %w`, acceptor, err)
+	}
+
+	if err = a.register(topic, acceptor, publisher, group); err != nil {
+		return err
+	}
+
+	a.log.Trace(fmt.Sprintf(`joining to group %s completed successfully in %dms`, topic, time.Since(startTime).Milliseconds()))
+	return nil
+}
+
+// register connects and subscribes to the members in the group state shared
+// by the acceptor, and publishes the active status of the current member
+func (a *Agent) register(topic, acceptor string, publisher bool, group *messages.ResGroupJoin) error {
+	// current member is included in the state if it is rejoining
+	var others []models.Member
+	for _, m := range group.Members {
+		if m.Label != a.myLabel {
+			others = append(others, m)
+		}
 	}
 
 	// adding this node as a member
-	joiner := models.Member{
-		Active:      true,
-		Publisher:   publisher,
-		Label:       a.myLabel,
-		Inv:         inv,
-		PubEndpoint: a.pubEndpoint,
+	joiner, err := a.member(topic, true, publisher, group.Params.Signed)
+	if err != nil {
+		return err
 	}
 
 	if err = a.gs.SetParams(topic, group.Params); err != nil {
@@ -194,7 +229,7 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 
 	wg := &sync.WaitGroup{}
 	resSmMap := &sync.Map{}
-	for _, m := range group.Members {
+	for _, m := range others {
 		wg.Add(1)
 		go func(m models.Member, resSmMap *sync.Map, wg *sync.WaitGroup) {
 			defer wg.Done()
@@ -218,7 +253,7 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 	wg.Wait()
 
 	hashMap := make(map[string]string)
-	for _, m := range group.Members {
+	for _, m := range others {
 		if !m.Active {
 			continue
 		}
@@ -239,7 +274,7 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 		}
 	}
 
-	if len(group.Members) > 1 {
+	if len(others) > 1 {
 		if err = validator.ValidJoin(acceptor, group.Members, hashMap); err != nil {
 			if group.Params.JoinConsistent {
 				return fmt.Errorf(`join failed due to inconsistent view of the group - %v`, err)
@@ -254,7 +289,7 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 		return fmt.Errorf(`adding group members failed - %v`, err)
 	}
 
-	if err = a.waitForConns(topic, group.Members); err != nil {
+	if err = a.waitForConns(topic, others); err != nil {
 		return fmt.Errorf(`waiting for connections failed - %v`, err)
 	}
 
@@ -263,10 +298,162 @@ func (a *Agent) Join(topic, acceptor string, publisher bool) error {
 		return fmt.Errorf(`publishing active status failed - %v`, err)
 	}
 
-	a.log.Trace(fmt.Sprintf(`joining to group %s completed successfully in %dms`, topic, time.Since(startTime).Milliseconds()))
 	return nil
 }
 
+// rejoinAll rejoins the groups restored from the store
+func (a *Agent) rejoinAll() {
+	for _, topic := range a.gs.Topics() {
+		if err := a.rejoin(topic); err != nil {
+			a.log.Error(fmt.Sprintf(`rejoining group %s failed - %v`, topic, err))
+			continue
+		}
+		a.outChan <- `Rejoined group ` + topic
+	}
+}
+
+// rejoin requests the current state of the group from any reachable member,
+// removes the members which are no longer in the group and re-establishes
+// the connections with the rest since transport keys are renewed on restart
+func (a *Agent) rejoin(topic string) error {
+	me := a.gs.Membr(topic, a.myLabel)
+	if me == nil {
+		return fmt.Errorf(`current member does not exist in the restored group`)
+	}
+
+	params := a.gs.Params(topic)
+	if params == nil {
+		return fmt.Errorf(`group params do not exist in the restored group`)
+	}
+
+	inv, ok := a.invs[topic]
+	if !ok {
+		return fmt.Errorf(`invitation does not exist for the restored group`)
+	}
+
+	var acceptor string
+	var group *messages.ResGroupJoin
+	var others int
+	for _, m := range a.gs.Membrs(topic) {
+		if m.Label == a.myLabel || !m.Active {
+			continue
+		}
+
+		others++
+		grp, err := a.reqState(topic, m.Label, inv)
+		if err != nil {
+			a.log.Warn(fmt.Sprintf(`requesting state of group %s from %s failed - %v`, topic, m.Label, err))
+			continue
+		}
+		acceptor, group = m.Label, grp
+		break
+	}
+
+	if group == nil {
+		if params.OrderEnabled {
+			a.syncr.init(topic)
+		}
+
+		if others != 0 {
+			a.log.Warn(fmt.Sprintf(`no member of group %s is reachable and hence proceeded with the restored state`, topic))
+		}
+		return nil
+	}
+
+	current := map[string]bool{}
+	for _, m := range group.Members {
+		current[m.Label] = true
+	}
+
+	for _, m := range a.gs.Membrs(topic) {
+		if m.Label == a.myLabel || current[m.Label] {
+			continue
+		}
+
+		if err := a.subs.Delete(topic, m.Label); err != nil {
+			return fmt.Errorf(`deleting subscriber %s failed - %v`, m.Label, err)
+		}
+
+		if err := a.gs.DeleteMembr(topic, m.Label); err != nil {
+			return fmt.Errorf(`deleting member %s failed - %v`, m.Label, err)
+		}
+		a.log.Debug(fmt.Sprintf(`removed %s from restored group %s as it is no longer a member`, m.Label, topic))
+	}
+
+	return a.register(topic, acceptor, me.Publisher, group)
+}
+
+func (a *Agent) setInv(topic, inv string) error {
+	a.invs[topic] = inv
+	if err := a.db.SaveInvitation(topic, inv); err != nil {
+		return fmt.Errorf(`persisting invitation failed - %v`, err)
+	}
+	return nil
+}
+
+// member constructs the models.Member of the current node along with the
+// keys which are used by subscribers to authenticate its group messages
+func (a *Agent) member(topic string, active, publisher, signed bool) (models.Member, error) {
+	m := models.Member{
+		Active:      active,
+		Publisher:   publisher,
+		Label:       a.myLabel,
+		Inv:         a.invs[topic],
+		PubEndpoint: a.pubEndpoint,
+	}
+
+	if !publisher {
+		return m, nil
+	}
+
+	sigKey, err := a.sigKey(topic, signed)
+	if err != nil {
+		return models.Member{}, fmt.Errorf(`setting up signing key failed - %v`, err)
+	}
+
+	grpKey, err := a.groupKey(topic)
+	if err != nil {
+		return models.Member{}, fmt.Errorf(`setting up group key failed - %v`, err)
+	}
+
+	m.SigKey, m.GroupKey = sigKey, grpKey
+	return m, nil
+}
+
+// groupKey returns the base58 encoded public key of the topic which
+// group messages of the current member are packed with
+func (a *Agent) groupKey(topic string) (string, error) {
+	if err := a.km.GenerateGroupKeys(topic); err != nil {
+		return ``, fmt.Errorf(`generating group keys failed - %v`, err)
+	}
+
+	pubKey, err := a.km.GroupPublicKey(topic)
+	if err != nil {
+		return ``, err
+	}
+
+	return base58.Encode(pubKey), nil
+}
+
+// sigKey returns the base58 encoded verification key of the topic
+// if group messages are signed
+func (a *Agent) sigKey(topic string, signed bool) (string, error) {
+	if !signed {
+		return ``, nil
+	}
+
+	if err := a.km.GenerateSigningKeys(topic); err != nil {
+		return ``, fmt.Errorf(`generating signing keys failed - %v`, err)
+	}
+
+	pubKey, err := a.km.SigningPublicKey(topic)
+	if err != nil {
+		return ``, err
+	}
+
+	return base58.Encode(pubKey), nil
+}
+
 func (a *Agent) waitForConns(topic string, grp []models.Member) error {
 	// wait till didcomm connections are established with all group members
 	for _, m := range grp {
@@ -279,7 +466,7 @@ func (a *Agent) waitForConns(topic string, grp []models.Member) error {
 	}
 
 	// pack hello msgs for each peer
-	sm := messages.Status{Id: uuid.New().String(), Type: messages.HelloProtocolV1, Topic: topic, AuthMsgs: map[string]string{}}
+	sm := messages.Status{Message: messages.NewMessage(messages.HelloProtocolV1), Topic: topic, AuthMsgs: map[string]string{}}
 	for _, m := range grp {
 		pr, err := a.probr.Peer(m.Label)
 		if err != nil {
@@ -323,7 +510,7 @@ retry:
 }
 
 func (a *Agent) connectMember(topic string, publisher bool, m models.Member, resSm messages.ResSubscribe) error {
-	if err := a.proc.sendAuth(m.Label, resSm.Transport.ServrPubKey, resSm.Transport.ClientPubKey, resSm.Publisher); err != nil {
+	if err := a.proc.sendAuth(m.Label, resSm.ZmqTransport.ServrPubKey, resSm.ZmqTransport.ClientPubKey, resSm.Publisher); err != nil {
 		return fmt.Errorf(`sending internal auth message failed - %v`, err)
 	}
 
@@ -339,9 +526,7 @@ func (a *Agent) connectMember(topic string, publisher bool, m models.Member, res
 	if err != nil {
 		return fmt.Errorf(`fetching service info failed for peer %s - %v`, m.Label, err)
 	}
-	a.subs.Add(topic, m.Label, s.PubKey)
-
-	return nil
+	return a.subs.Add(topic, m.Label, s.PubKey)
 }
 
 // reqState checks if requester has already connected with acceptor
@@ -354,13 +539,16 @@ func (a *Agent) reqState(topic, accptr, inv string) (*messages.ResGroupJoin, err
 		return nil, fmt.Errorf(`fetching service info failed for peer %s - %v`, accptr, err)
 	}
 
-	byts, err := json.Marshal(messages.ReqGroupJoin{
-		Id:           uuid.New().String(),
-		Type:         messages.JoinRequestV1,
+	req := messages.ReqGroupJoin{
+		Message:      messages.NewMessage(messages.JoinRequestV1),
 		Label:        a.myLabel,
 		Topic:        topic,
 		RequesterInv: inv,
-	})
+	}
+	// response is expected as the reply of the request
+	req.Transport = &messages.TransportDecorator{ReturnRoute: messages.ReturnRouteThread}
+
+	byts, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf(`marshalling group-join request failed - %v`, err)
 	}
@@ -372,13 +560,13 @@ func (a *Agent) reqState(topic, accptr, inv string) (*messages.ResGroupJoin, err
 
 	res, err := a.client.Send(models.TypGroupJoin, data, s.Endpoint)
 	if err != nil {
-		return nil, fmt.Errorf(`group-join request failed - %v`, err)
+		return nil, fmt.Errorf(`group-join request failed - %w`, err)
 	}
 	a.log.Debug(`group-join response received`, res)
 
 	_, unpackedMsg, err := a.probr.ReadMessage(models.Message{Type: models.TypGroupJoin, Data: []byte(res), Reply: nil})
 	if err != nil {
-		return nil, fmt.Errorf(`unpacking group-join response failed - %v`, err)
+		return nil, fmt.Errorf(`unpacking group-join response failed - %w`, err)
 	}
 
 	var resGroup messages.ResGroupJoin
@@ -386,6 +574,11 @@ func (a *Agent) reqState(topic, accptr, inv string) (*messages.ResGroupJoin, err
 		return nil, fmt.Errorf(`unmarshalling group-join response failed - %v`, err)
 	}
 
+	// responses without a thread decorator can not be correlated
+	if resGroup.Thread != nil && resGroup.ThId() != req.Id {
+		return nil, fmt.Errorf(`group-join response does not belong to the request (%s)`, resGroup.ThId())
+	}
+
 	return &resGroup, nil
 }
 
@@ -410,20 +603,35 @@ func (a *Agent) Send(topic, msg string) (n []int, err error) {
 		return nil, fmt.Errorf(`constructing ordered group message failed - %v`, err)
 	}
 
+	// signs before encryption so that the signature is only visible to members
+	if a.gs.Signed(topic) {
+		if syncdMsg, err = a.packr.sign(topic, syncdMsg); err != nil {
+			return nil, fmt.Errorf(`signing group message failed - %v`, err)
+		}
+	}
+
+	// encrypts the message once for all subscribers (per envelope profile) in
+	// single-queue mode and once per subscriber queue otherwise
+	grpMsgs, err := a.packr.packGroup(topic, subs, syncdMsg)
+	if err != nil {
+		return nil, fmt.Errorf(`packing data message failed - %v`, err)
+	}
+
 	var published bool
-	for sub, key := range subs {
-		data, err := a.packr.pack(sub, key, syncdMsg)
-		if err != nil {
-			return nil, fmt.Errorf(`packing data message for %s failed - %v`, sub, err)
+	for _, gm := range grpMsgs {
+		// all subscribers read from the same queue in single-queue mode
+		var sub string
+		if a.gs.Mode(topic) != domain.SingleQueueMode {
+			sub = gm.subs[0]
 		}
 
-		if err = a.proc.sendPublish(a.zmq.DataTopic(topic, a.myLabel, sub), data); err != nil {
+		if err = a.proc.sendPublish(a.zmq.DataTopic(topic, a.myLabel, sub), gm.data); err != nil {
 			return nil, fmt.Errorf(`sending internal publish message failed - %v`, err)
 		}
 
 		published = true
-		n = append(n, len(data))
-		a.log.Trace(fmt.Sprintf(`published %s to %s of %s`, msg, topic, sub))
+		n = append(n, len(gm.data))
+		a.log.Trace(fmt.Sprintf(`published %s to %s for %v`, msg, topic, gm.subs))
 	}
 
 	if published {
@@ -464,25 +672,24 @@ func (a *Agent) subscribeData(topic string, publisher bool, m models.Member) (re
 		return messages.ResSubscribe{}, fmt.Errorf(`fetching public key for the connection failed - %v`, err)
 	}
 
+	membr, err := a.member(topic, true, publisher, a.gs.Signed(topic))
+	if err != nil {
+		return messages.ResSubscribe{}, err
+	}
+
 	// B sends agent subscribe msg to member
 	sm := messages.Subscribe{
-		Id:        uuid.New().String(),
-		Type:      messages.SubscribeV1,
+		Message:   messages.NewMessage(messages.SubscribeV1),
 		Subscribe: true,
 		PubKey:    base58.Encode(subPublcKey),
 		Topic:     topic,
-		Member: models.Member{
-			Active:      true,
-			Publisher:   publisher,
-			Label:       a.myLabel,
-			Inv:         a.invs[topic],
-			PubEndpoint: a.pubEndpoint,
-		},
-		Transport: messages.Transport{
+		Member:    membr,
+		ZmqTransport: messages.Transport{
 			ServrPubKey:  a.zmq.ServrPubKey(),
 			ClientPubKey: a.zmq.ClientPubKey(),
 		},
 	}
+	sm.Transport = &messages.TransportDecorator{ReturnRoute: messages.ReturnRouteThread}
 
 	byts, err := json.Marshal(sm)
 	if err != nil {
@@ -501,32 +708,41 @@ func (a *Agent) subscribeData(topic string, publisher bool, m models.Member) (re
 
 	res, err := a.client.Send(models.TypSubscribe, data, s.Endpoint)
 	if err != nil {
-		return messages.ResSubscribe{}, fmt.Errorf(`sending subscribe message failed - %v`, err)
+		return messages.ResSubscribe{}, fmt.Errorf(`sending subscribe message failed - %w`, err)
 	}
 
 	_, unpackedMsg, err := a.probr.ReadMessage(models.Message{Type: models.TypSubscribe, Data: []byte(res), Reply: nil})
 	if err != nil {
-		return messages.ResSubscribe{}, fmt.Errorf(`reading subscribe didcomm response failed - %v`, err)
+		return messages.ResSubscribe{}, fmt.Errorf(`reading subscribe didcomm response failed - %w`, err)
 	}
 
 	if err = json.Unmarshal([]byte(unpackedMsg), &resSm); err != nil {
 		return messages.ResSubscribe{}, fmt.Errorf(`unmarshalling didcomm message into subscribe response struct failed - %v`, err)
 	}
 
-	//if err = a.proc.sendAuth(m.Label, resSm.Transport.ServrPubKey, resSm.Transport.ClientPubKey, resSm.Publisher); err != nil {
+	if resSm.Thread != nil && resSm.ThId() != sm.Id {
+		return messages.ResSubscribe{}, fmt.Errorf(`subscribe response does not belong to the request (%s)`, resSm.ThId())
+	}
+
+	//if err = a.proc.sendAuth(m.Label, resSm.ZmqTransport.ServrPubKey, resSm.ZmqTransport.ClientPubKey, resSm.Publisher); err != nil {
 	//	return ``, fmt.Errorf(`sending internal auth message failed - %v`, err)
 	//}
 
 	return resSm, nil
 }
 
-func (a *Agent) process(inChan chan models.Message, handlerFunc func(msg *models.Message) error) {
+// process replies with a problem report of the code if the handler
+// failed before responding to the message
+func (a *Agent) process(inChan chan models.Message, code string, handlerFunc func(msg *models.Message) error) {
 	go func() {
 		for {
 			msg := <-inChan
 			go func(msg models.Message) {
 				if err := handlerFunc(&msg); err != nil {
 					a.log.Error(fmt.Sprintf(`processing message by handler failed - %v`, err))
+					if msg.Reply != nil {
+						msg.Reply <- a.probr.ReportProblem(msg, code, err)
+					}
 				}
 			}(msg)
 		}
@@ -551,14 +767,14 @@ func (a *Agent) notifyAll(topic string, active, publisher bool) error {
 }
 
 func (a *Agent) compressStatus(topic string, active, publisher bool) ([]byte, error) {
-	sm := messages.Status{Id: uuid.New().String(), Type: messages.MemberStatusV1, Topic: topic, AuthMsgs: map[string]string{}}
-	byts, err := json.Marshal(models.Member{
-		Label:       a.myLabel,
-		Active:      active,
-		Inv:         a.invs[topic],
-		Publisher:   publisher,
-		PubEndpoint: a.pubEndpoint,
-	})
+	sm := messages.Status{Message: messages.NewMessage(messages.MemberStatusV1), Topic: topic, AuthMsgs: map[string]string{}}
+	// keys are included since the status replaces the member in the group state
+	m, err := a.member(topic, active, publisher, a.gs.Signed(topic))
+	if err != nil {
+		return nil, err
+	}
+
+	byts, err := json.Marshal(m)
 	if err != nil {
 		return nil, fmt.Errorf(`marshalling member failed - %v`, err)
 	}
@@ -620,11 +836,39 @@ func (a *Agent) Leave(topic string) error {
 	}
 
 	a.subs.DeleteTopic(topic)
-	a.gs.DeleteTopic(topic)
+	if err := a.gs.DeleteTopic(topic); err != nil {
+		return err
+	}
 	a.outChan <- `Left group ` + topic
 	return nil
 }
 
+// Roles returns subscriber if the agent is a member of a group and
+// publisher if it publishes to any of the groups
+func (a *Agent) Roles() (roles []string) {
+	var member, publisher bool
+	for _, topic := range a.gs.Topics() {
+		m := a.gs.Membr(topic, a.myLabel)
+		if m == nil {
+			continue
+		}
+
+		member = true
+		if m.Publisher {
+			publisher = true
+		}
+	}
+
+	if publisher {
+		roles = append(roles, `publisher`)
+	}
+
+	if member {
+		roles = append(roles, `subscriber`)
+	}
+	return roles
+}
+
 func (a *Agent) Info(topic string) (gp models.GroupParams, mems []models.Member) {
 	// removing invitation for more clarity
 	for _, m := range a.gs.Membrs(topic) {
@@ -6,32 +6,35 @@ import (
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	servicesPkg "github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/YasiruR/didcomm-prober/pubsub/stores"
+	"github.com/btcsuite/btcutil/base58"
 )
 
 // packer is an internal wrapper for the packing processes of group agent
 type packer struct {
 	*services
-	pckr servicesPkg.Packer
+	gs    *stores.Group
+	pckrs map[domain.Envelope]servicesPkg.Packer
+	signr servicesPkg.Signer
 }
 
-func newPacker(c *container.Container) *packer {
+func newPacker(c *container.Container, gs *stores.Group) *packer {
 	return &packer{
 		services: &services{
 			km:    c.KeyManager,
 			probr: c.Prober,
 		},
-		pckr: c.Packer,
+		gs:    gs,
+		pckrs: c.Packers,
+		signr: c.Signer,
 	}
 }
 
 // pack constructs and encodes an authcrypt message to the given receiver
 func (p *packer) pack(receiver string, recPubKey []byte, msg []byte) ([]byte, error) {
-	if recPubKey == nil {
-		s, err := p.probr.SyncService(domain.ServcGroupJoin, receiver, 5000)
-		if err != nil {
-			return nil, fmt.Errorf(`fetching service info failed for peer %s - %v`, receiver, err)
-		}
-		recPubKey = s.PubKey
+	recPubKey, err := p.recKey(receiver, recPubKey)
+	if err != nil {
+		return nil, err
 	}
 
 	ownPubKey, err := p.km.PublicKey(receiver)
@@ -44,7 +47,106 @@ func (p *packer) pack(receiver string, recPubKey []byte, msg []byte) ([]byte, er
 		return nil, fmt.Errorf(`getting private key for connection with %s failed - %v`, receiver, err)
 	}
 
-	encryptdMsg, err := p.pckr.Pack(msg, recPubKey, ownPubKey, ownPrvKey)
+	// packs with the envelope profile negotiated for the connection
+	env, err := p.envelope(receiver)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.encode(env, msg, [][]byte{recPubKey}, ownPubKey, ownPrvKey)
+}
+
+// packedMsg is a packed group message along with its intended subscribers
+type packedMsg struct {
+	data []byte
+	subs []string
+}
+
+// packGroup encrypts the message once per envelope profile used by the
+// subscribers such that each envelope contains a recipient per subscriber
+// if they read from a single queue. Otherwise, a separate envelope with a
+// single recipient is packed for the queue of each subscriber. Sender
+// key-pair of the topic is used since connection keys differ for each
+// subscriber.
+func (p *packer) packGroup(topic string, subs map[string][]byte, msg []byte) ([]packedMsg, error) {
+	if err := p.km.GenerateGroupKeys(topic); err != nil {
+		return nil, fmt.Errorf(`generating group keys failed - %v`, err)
+	}
+
+	// omitted errors since keys are generated above
+	grpPubKey, _ := p.km.GroupPublicKey(topic)
+	grpPrvKey, _ := p.km.GroupPrivateKey(topic)
+
+	recKeys := make(map[domain.Envelope][][]byte)
+	recSubs := make(map[domain.Envelope][]string)
+	var msgs []packedMsg
+	for sub, key := range subs {
+		key, err := p.recKey(sub, key)
+		if err != nil {
+			return nil, err
+		}
+
+		env, err := p.envelope(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.gs.Mode(topic) != domain.SingleQueueMode {
+			data, err := p.encode(env, msg, [][]byte{key}, grpPubKey, grpPrvKey)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, packedMsg{data: data, subs: []string{sub}})
+			continue
+		}
+
+		recKeys[env] = append(recKeys[env], key)
+		recSubs[env] = append(recSubs[env], sub)
+	}
+
+	for env, keys := range recKeys {
+		data, err := p.encode(env, msg, keys, grpPubKey, grpPrvKey)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, packedMsg{data: data, subs: recSubs[env]})
+	}
+
+	return msgs, nil
+}
+
+// recKey prefers the current group-join service key of the receiver since
+// the key may have been rotated after it was provided
+func (p *packer) recKey(receiver string, recPubKey []byte) ([]byte, error) {
+	if s, err := p.probr.Service(domain.ServcGroupJoin, receiver); err == nil {
+		return s.PubKey, nil
+	}
+
+	if recPubKey != nil {
+		return recPubKey, nil
+	}
+
+	s, err := p.probr.SyncService(domain.ServcGroupJoin, receiver, 5000)
+	if err != nil {
+		return nil, fmt.Errorf(`fetching service info failed for peer %s - %v`, receiver, err)
+	}
+	return s.PubKey, nil
+}
+
+func (p *packer) envelope(receiver string) (domain.Envelope, error) {
+	pr, err := p.probr.Peer(receiver)
+	if err != nil {
+		return ``, fmt.Errorf(`fetching peer %s failed - %v`, receiver, err)
+	}
+
+	if _, ok := p.pckrs[pr.Envelope]; !ok {
+		return ``, fmt.Errorf(`no packer found for the envelope profile (%s) of %s`, pr.Envelope, receiver)
+	}
+	return pr.Envelope, nil
+}
+
+func (p *packer) encode(env domain.Envelope, msg []byte, recPubKeys [][]byte, sendPubKey, sendPrvKey []byte) ([]byte, error) {
+	encryptdMsg, err := p.pckrs[env].Pack(msg, recPubKeys, sendPubKey, sendPrvKey)
 	if err != nil {
 		return nil, fmt.Errorf(`packing error - %v`, err)
 	}
@@ -56,3 +158,54 @@ func (p *packer) pack(receiver string, recPubKey []byte, msg []byte) ([]byte, er
 
 	return data, nil
 }
+
+// sign wraps the group message in a signed message with the signing key of
+// the topic so that subscribers can prove the authorship to other members
+func (p *packer) sign(topic string, msg []byte) ([]byte, error) {
+	pubKey, err := p.km.SigningPublicKey(topic)
+	if err != nil {
+		return nil, fmt.Errorf(`fetching signing public key failed - %v`, err)
+	}
+
+	prvKey, err := p.km.SigningPrivateKey(topic)
+	if err != nil {
+		return nil, fmt.Errorf(`fetching signing private key failed - %v`, err)
+	}
+
+	return p.signr.Sign(msg, pubKey, prvKey)
+}
+
+// authenticate checks if the group message is packed with the group key
+// that the publisher has advertised in the group
+func (p *packer) authenticate(topic, publisher string, sendPubKey []byte) error {
+	m := p.gs.Membr(topic, publisher)
+	if m == nil {
+		return fmt.Errorf(`publisher %s is not a member of the group`, publisher)
+	}
+
+	if m.GroupKey == `` || m.GroupKey != base58.Encode(sendPubKey) {
+		return fmt.Errorf(`message is not packed with the group key of %s`, publisher)
+	}
+	return nil
+}
+
+// verify checks if the message is signed by the verification key that
+// the publisher has advertised in the group and returns the signed payload
+func (p *packer) verify(topic, publisher string, data []byte) (payload []byte, signer string, err error) {
+	payload, signerKey, err := p.signr.Verify(data)
+	if err != nil {
+		return nil, ``, err
+	}
+
+	m := p.gs.Membr(topic, publisher)
+	if m == nil {
+		return nil, ``, fmt.Errorf(`publisher %s is not a member of the group`, publisher)
+	}
+
+	signer = base58.Encode(signerKey)
+	if m.SigKey == `` || m.SigKey != signer {
+		return nil, ``, fmt.Errorf(`message is not signed by the verification key of %s`, publisher)
+	}
+
+	return payload, signer, nil
+}
@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/crypto"
+	"github.com/YasiruR/didcomm-prober/domain"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	servicesPkg "github.com/YasiruR/didcomm-prober/domain/services"
+	"github.com/YasiruR/didcomm-prober/pubsub/stores"
+	"github.com/YasiruR/didcomm-prober/store"
+	"testing"
+)
+
+// testProber serves the connections of subscribers with RFC-0019 envelopes
+type testProber struct {
+	servicesPkg.Agent
+}
+
+func (t *testProber) Peer(string) (models.Peer, error) {
+	return models.Peer{Envelope: domain.EnvelopeRFC19}, nil
+}
+
+func (t *testProber) Service(name, peer string) (*models.Service, error) {
+	return nil, fmt.Errorf(`service %s of %s is not required`, name, peer)
+}
+
+// testPacker records the number of recipients of each packed envelope
+type testPacker struct {
+	servicesPkg.Packer
+	recipients []int
+}
+
+func (t *testPacker) Pack(_ []byte, recPubKeys [][]byte, _, _ []byte) (messages.AuthCryptMsg, error) {
+	t.recipients = append(t.recipients, len(recPubKeys))
+	return messages.AuthCryptMsg{}, nil
+}
+
+func TestPacker_PackGroup(t *testing.T) {
+	subs := map[string][]byte{`bob`: []byte(`bob-key`), `charlie`: []byte(`charlie-key`), `dave`: []byte(`dave-key`)}
+	tests := []struct {
+		mode       domain.GroupMode
+		envelopes  int
+		recipients int
+	}{
+		{domain.SingleQueueMode, 1, len(subs)},
+		{domain.MultipleQueueMode, len(subs), 1},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.mode), func(t *testing.T) {
+			gs, err := stores.NewGroupStore(store.NewMemoryGroups())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = gs.SetParams(`topic`, models.GroupParams{Mode: test.mode}); err != nil {
+				t.Fatal(err)
+			}
+
+			pckr := &testPacker{}
+			p := &packer{
+				services: &services{km: crypto.NewKeyManager(), probr: &testProber{}},
+				gs:       gs,
+				pckrs:    map[domain.Envelope]servicesPkg.Packer{domain.EnvelopeRFC19: pckr},
+			}
+
+			// each packed message is published once to the queue of its subscribers
+			msgs, err := p.packGroup(`topic`, subs, []byte(`hello`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(msgs) != test.envelopes || len(pckr.recipients) != test.envelopes {
+				t.Fatalf(`expected %d published envelope(s) but got %d (packed: %d)`, test.envelopes, len(msgs), len(pckr.recipients))
+			}
+
+			published := map[string]bool{}
+			for i, m := range msgs {
+				if pckr.recipients[i] != test.recipients || len(m.subs) != test.recipients {
+					t.Errorf(`expected %d recipient(s) per envelope but got %d for %v`, test.recipients, pckr.recipients[i], m.subs)
+				}
+
+				for _, sub := range m.subs {
+					published[sub] = true
+				}
+			}
+
+			if len(published) != len(subs) {
+				t.Errorf(`expected the message to be published to all subscribers but got %v`, published)
+			}
+		})
+	}
+}
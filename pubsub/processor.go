@@ -59,12 +59,12 @@ func (p *processor) joinReqs(msg *models.Message) error {
 	}
 
 	byts, err := json.Marshal(messages.ResGroupJoin{
-		Id:   uuid.New().String(),
-		Type: messages.JoinResponseV1,
+		Message: req.Message.Reply(messages.JoinResponseV1),
 		Params: models.GroupParams{
 			OrderEnabled:   p.gs.OrderEnabled(req.Topic),
 			JoinConsistent: p.gs.JoinConsistent(req.Topic),
 			Mode:           p.gs.Mode(req.Topic),
+			Signed:         p.gs.Signed(req.Topic),
 		},
 		Members: p.gs.Membrs(req.Topic),
 		//Members: p.addIntruder(req.Topic),
@@ -78,8 +78,9 @@ func (p *processor) joinReqs(msg *models.Message) error {
 		return fmt.Errorf(`packing group-join response failed - %v`, err)
 	}
 
-	// no response is sent if process failed
+	// problem report is replied instead if process failed
 	msg.Reply <- packedMsg
+	msg.Reply = nil
 	p.log.Debug(fmt.Sprintf(`shared group state upon join request by %s`, req.Label), string(byts))
 
 	return nil
@@ -97,20 +98,19 @@ func (p *processor) subscriptions(msg *models.Message) error {
 	}
 
 	if !sm.Subscribe {
-		p.subs.Delete(sm.Topic, sm.Member.Label)
-		return nil
+		return p.subs.Delete(sm.Topic, sm.Member.Label)
 	}
 
 	if !p.validJoiner(sm.Member.Label) {
 		return fmt.Errorf(`requester (%s) is not eligible`, sm.Member.Label)
 	}
 
-	if err = p.sendAuth(sm.Member.Label, sm.Transport.ServrPubKey, sm.Transport.ClientPubKey, sm.Member.Publisher); err != nil {
+	if err = p.sendAuth(sm.Member.Label, sm.ZmqTransport.ServrPubKey, sm.ZmqTransport.ClientPubKey, sm.Member.Publisher); err != nil {
 		return fmt.Errorf(`sending internal auth message failed - %v`, err)
 	}
 
 	// send response back to subscriber along with zmq server pub-key of this node
-	if err = p.sendSubscribeRes(sm.Topic, sm.Member, msg); err != nil {
+	if err = p.sendSubscribeRes(sm.Topic, sm.ThId(), sm.Member, msg); err != nil {
 		return fmt.Errorf(`sending subscribe response failed - %v`, err)
 	}
 
@@ -119,7 +119,9 @@ func (p *processor) subscriptions(msg *models.Message) error {
 	}
 
 	sk := base58.Decode(sm.PubKey)
-	p.subs.Add(sm.Topic, sm.Member.Label, sk)
+	if err = p.subs.Add(sm.Topic, sm.Member.Label, sk); err != nil {
+		return fmt.Errorf(`adding subscriber failed - %v`, err)
+	}
 	p.log.Debug(`processed subscription request`, sm)
 
 	return nil
@@ -181,15 +183,31 @@ func (p *processor) state(_, msg string) error {
 
 func (p *processor) data(zmqTopic, msg string) error {
 	topic := p.zmq.GroupNameByDataTopic(zmqTopic)
-	sender, data, err := p.probr.ReadMessage(models.Message{Type: models.TypGroupMsg, Data: []byte(msg)})
+	// sender key is checked against the group key advertised by the publisher
+	var authErr error
+	sender, data, err := p.probr.ReadGroupMessage(models.Message{Type: models.TypGroupMsg, Data: []byte(msg)}, func(sender string, sendPubKey []byte) error {
+		authErr = p.packr.authenticate(topic, sender, sendPubKey)
+		return authErr
+	})
 	if err != nil {
-		if p.gs.Mode(topic) == domain.SingleQueueMode {
+		// messages of the shared queue may not be intended to this member
+		if p.gs.Mode(topic) == domain.SingleQueueMode && authErr == nil {
 			//p.log.Debug(fmt.Sprintf(`message may not be intended to this member - %v`, err))
 			return nil
 		}
 		return fmt.Errorf(`reading subscribed message failed - %v`, err)
 	}
 
+	// signature is verified against the key advertised by the publisher
+	var signer string
+	if p.gs.Signed(topic) {
+		payload, sigKey, err := p.packr.verify(topic, sender, []byte(data))
+		if err != nil {
+			return fmt.Errorf(`verifying signed group message failed - %v`, err)
+		}
+		data, signer = string(payload), sigKey
+	}
+
 	data, err = p.syncr.parse(topic, data)
 	if err != nil {
 		return fmt.Errorf(`parsing data message via syncer failed - %v`, err)
@@ -199,6 +217,11 @@ func (p *processor) data(zmqTopic, msg string) error {
 		p.ackChans[sender] <- data
 	}
 
+	if signer != `` {
+		p.outChan <- fmt.Sprintf(`%s sent in group '%s' (signed by %s): %s`, sender, topic, signer, data)
+		return nil
+	}
+
 	p.outChan <- fmt.Sprintf(`%s sent in group '%s': %s`, sender, topic, data)
 	return nil
 }
@@ -217,21 +240,25 @@ func (p *processor) extractStatus(msg string) (*messages.Status, error) {
 	return &sm, nil
 }
 
-func (p *processor) sendSubscribeRes(topic string, m models.Member, msg *models.Message) error {
+func (p *processor) sendSubscribeRes(topic, thId string, m models.Member, msg *models.Message) error {
 	// to fetch if current node is a publisher of the topic
 	curntMembr := p.gs.Membr(topic, p.myLabel)
 	if curntMembr == nil {
 		return fmt.Errorf(`current member or topic does not exist in group store`)
 	}
 
-	resByts, err := json.Marshal(messages.ResSubscribe{
-		Transport: messages.Transport{
+	res := messages.ResSubscribe{
+		Message: messages.NewMessage(messages.SubscribeResV1),
+		ZmqTransport: messages.Transport{
 			ServrPubKey:  p.zmq.ServrPubKey(),
 			ClientPubKey: p.zmq.ClientPubKey(),
 		},
 		Publisher: curntMembr.Publisher,
 		Checksum:  p.gs.Checksum(topic),
-	})
+	}
+	res.SetThread(thId, ``)
+
+	resByts, err := json.Marshal(res)
 	if err != nil {
 		return fmt.Errorf(`marshalling subscribe response failed - %v`, err)
 	}
@@ -242,6 +269,7 @@ func (p *processor) sendSubscribeRes(topic string, m models.Member, msg *models.
 	}
 
 	msg.Reply <- packedMsg
+	msg.Reply = nil
 	return nil
 }
 
@@ -256,7 +284,10 @@ func (p *processor) removeMember(m models.Member, status *messages.Status) error
 		}
 	}
 
-	p.subs.Delete(status.Topic, m.Label)
+	if err := p.subs.Delete(status.Topic, m.Label); err != nil {
+		return fmt.Errorf(`deleting subscriber failed - %v`, err)
+	}
+
 	if err := p.gs.DeleteMembr(status.Topic, m.Label); err != nil {
 		return fmt.Errorf(`deleting member failed - %v`, err)
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/YasiruR/didcomm-prober/pubsub/validator"
 	"sync"
 )
@@ -14,16 +15,50 @@ const (
 
 /* Group Store */
 
+// Group store persists each update of a topic and restores the
+// persisted groups on initialization
 type Group struct {
 	*sync.RWMutex
 	groups map[string]*models.Group // todo changing this (eg: cons level) maliciously is a threat?
+	db     services.GroupStore
 }
 
-func NewGroupStore() *Group {
-	return &Group{
+func NewGroupStore(db services.GroupStore) (*Group, error) {
+	grps, err := db.Groups()
+	if err != nil {
+		return nil, fmt.Errorf(`restoring groups failed - %v`, err)
+	}
+
+	g := &Group{
 		RWMutex: &sync.RWMutex{},
 		groups:  map[string]*models.Group{},
+		db:      db,
+	}
+
+	for topic, grp := range grps {
+		tmpGrp := grp
+		g.groups[topic] = &tmpGrp
+	}
+
+	return g, nil
+}
+
+// Topics returns the list of groups which the current member has joined
+func (g *Group) Topics() (topics []string) {
+	g.RLock()
+	defer g.RUnlock()
+	for topic := range g.groups {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// save should be called while holding the write lock
+func (g *Group) save(topic string) error {
+	if err := g.db.SaveGroup(topic, *g.groups[topic]); err != nil {
+		return fmt.Errorf(`persisting group %s failed - %v`, topic, err)
 	}
+	return nil
 }
 
 func (g *Group) AddMembrs(topic string, mems ...models.Member) error {
@@ -52,7 +87,7 @@ func (g *Group) AddMembrs(topic string, mems ...models.Member) error {
 	}
 
 	g.groups[topic].Checksum = hash
-	return nil
+	return g.save(topic)
 }
 
 func (g *Group) DeleteMembr(topic, membr string) error {
@@ -74,13 +109,17 @@ func (g *Group) DeleteMembr(topic, membr string) error {
 	}
 
 	g.groups[topic].Checksum = hash
-	return nil
+	return g.save(topic)
 }
 
-func (g *Group) DeleteTopic(topic string) {
+func (g *Group) DeleteTopic(topic string) error {
 	g.Lock()
 	defer g.Unlock()
 	delete(g.groups, topic)
+	if err := g.db.DeleteGroup(topic); err != nil {
+		return fmt.Errorf(`deleting persisted group %s failed - %v`, topic, err)
+	}
+	return nil
 }
 
 // Joined checks if current member has already Joined a group
@@ -141,7 +180,7 @@ func (g *Group) SetParams(topic string, gp models.GroupParams) error {
 	}
 
 	g.groups[topic].GroupParams = &gp
-	return nil
+	return g.save(topic)
 }
 
 func (g *Group) Params(topic string) *models.GroupParams {
@@ -173,6 +212,12 @@ func (g *Group) JoinConsistent(topic string) bool {
 	return g.groups[topic].JoinConsistent
 }
 
+func (g *Group) Signed(topic string) bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.groups[topic].Signed
+}
+
 func (g *Group) Checksum(topic string) string {
 	g.RLock()
 	g.RUnlock()
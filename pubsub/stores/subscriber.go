@@ -2,6 +2,7 @@ package stores
 
 import (
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	"sync"
 )
 
@@ -12,10 +13,21 @@ import (
 type Subscriber struct {
 	*sync.RWMutex
 	subs map[string]subKey // can extend to multiple keys per peer
+	db   services.GroupStore
 }
 
-func NewSubStore() *Subscriber {
-	return &Subscriber{RWMutex: &sync.RWMutex{}, subs: map[string]subKey{}}
+func NewSubStore(db services.GroupStore) (*Subscriber, error) {
+	persisted, err := db.Subscribers()
+	if err != nil {
+		return nil, fmt.Errorf(`restoring subscribers failed - %v`, err)
+	}
+
+	s := &Subscriber{RWMutex: &sync.RWMutex{}, subs: map[string]subKey{}, db: db}
+	for topic, sk := range persisted {
+		s.subs[topic] = sk
+	}
+
+	return s, nil
 }
 
 type subKey map[string][]byte // subscriber to public key map
@@ -32,23 +44,41 @@ func (s *Subscriber) QueryByTopic(topic string) (subKey, error) {
 }
 
 // Add replaces the key if already exists for the subscriber
-func (s *Subscriber) Add(topic, sub string, key []byte) {
+func (s *Subscriber) Add(topic, sub string, key []byte) error {
 	s.Lock()
 	defer s.Unlock()
 	if s.subs[topic] == nil {
 		s.subs[topic] = subKey{}
 	}
 	s.subs[topic][sub] = key
+	return s.save(topic)
 }
 
-func (s *Subscriber) Delete(topic, label string) {
+func (s *Subscriber) Delete(topic, label string) error {
 	s.Lock()
 	defer s.Unlock()
 	delete(s.subs[topic], label)
+	return s.save(topic)
 }
 
+// DeleteTopic only updates the memory since the persisted subscribers
+// are removed along with the group
 func (s *Subscriber) DeleteTopic(topic string) {
 	s.Lock()
 	defer s.Unlock()
 	delete(s.subs, topic)
 }
+
+// save persists a copy of the subscribers of the topic and should
+// be called while holding the write lock
+func (s *Subscriber) save(topic string) error {
+	subs := map[string][]byte{}
+	for sub, key := range s.subs[topic] {
+		subs[sub] = key
+	}
+
+	if err := s.db.SaveSubscribers(topic, subs); err != nil {
+		return fmt.Errorf(`persisting subscribers of %s failed - %v`, topic, err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/gorilla/mux"
@@ -13,23 +14,23 @@ import (
 )
 
 type HTTP struct {
-	port   int
-	packer services.Packer
-	ks     services.KeyManager
-	log    log.Logger
-	router *mux.Router
-	client *http.Client
-	inChan chan []byte
+	port    int
+	packers map[domain.Envelope]services.Packer
+	ks      services.KeyManager
+	log     log.Logger
+	router  *mux.Router
+	client  *http.Client
+	inChan  chan []byte
 }
 
 func NewHTTP(c *container.Container) *HTTP {
 	return &HTTP{
-		port:   c.Cfg.Args.Port,
-		packer: c.Packer,
-		ks:     c.KeyManager,
-		log:    c.Log,
-		client: &http.Client{},
-		router: mux.NewRouter(),
+		port:    c.Cfg.Args.Port,
+		packers: c.Packers,
+		ks:      c.KeyManager,
+		log:     c.Log,
+		client:  &http.Client{},
+		router:  mux.NewRouter(),
 		//inChan: c.InChan,
 	}
 }
@@ -34,6 +34,7 @@ func Start(c *container.Container) {
 	r.HandleFunc(JoinEndpoint, m.handleJoin).Methods(http.MethodPost)
 	r.HandleFunc(GrpMsgAckEndpoint, m.handleGrpMsgListnr).Methods(http.MethodPost)
 	r.HandleFunc(KillEndpoint, m.handleKill).Methods(http.MethodPost)
+	r.HandleFunc(TrustPingEndpoint, m.handleTrustPing).Methods(http.MethodPost)
 
 	go func(mockPort int, r *mux.Router) {
 		if err := http.ListenAndServe(":"+strconv.Itoa(mockPort), r); err != nil {
@@ -127,6 +128,54 @@ func (m *mocker) handleJoin(_ http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleTrustPing pings the peer over didcomm and responds with the round-trip
+// times of this request along with the statistics of all pings to the peer
+func (m *mocker) handleTrustPing(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		m.log.Error(err)
+		return
+	}
+
+	var req reqPing
+	if err = json.Unmarshal(data, &req); err != nil {
+		m.log.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req.Count < 1 {
+		req.Count = 1
+	}
+
+	res := resPing{Peer: req.Peer}
+	for i := 0; i < req.Count; i++ {
+		rtt, err := m.ctr.Prober.Ping(req.Peer)
+		if err != nil {
+			m.log.Error(fmt.Sprintf(`trust ping to %s failed - %v`, req.Peer, err))
+			continue
+		}
+		res.RTTs = append(res.RTTs, ms(rtt))
+	}
+
+	stats, ok := m.ctr.Prober.PingStats(req.Peer)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	res.Sent, res.Received = stats.Sent, stats.Received
+	res.Min, res.Avg, res.Max = ms(stats.Min), ms(stats.Avg), ms(stats.Max)
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		m.log.Error(err)
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
 func (m *mocker) handleGrpMsgListnr(_ http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	data, err := ioutil.ReadAll(r.Body)
@@ -10,6 +10,7 @@ const (
 	JoinEndpoint      = `/join`
 	GrpMsgAckEndpoint = `/msg-ack`
 	KillEndpoint      = `/kill`
+	TrustPingEndpoint = `/trust-ping`
 )
 
 type reqCreate struct {
@@ -24,6 +25,22 @@ type reqJoin struct {
 	Publisher bool   `json:"publisher"`
 }
 
+type reqPing struct {
+	Peer  string `json:"peer"`
+	Count int    `json:"count"`
+}
+
+// resPing contains the round-trip times in milliseconds
+type resPing struct {
+	Peer     string    `json:"peer"`
+	RTTs     []float64 `json:"rtts"`
+	Sent     int       `json:"sent"`
+	Received int       `json:"received"`
+	Min      float64   `json:"min"`
+	Avg      float64   `json:"avg"`
+	Max      float64   `json:"max"`
+}
+
 type ReqRegAck struct {
 	Peer             string `json:"peer"`
 	Msg              string `json:"msg"`
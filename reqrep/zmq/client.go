@@ -3,17 +3,21 @@ package zmq
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	zmq "github.com/pebbe/zmq4"
 	"github.com/tryfix/log"
 	"sync"
+	"time"
 )
 
 type req struct {
 	typ      models.MsgType
 	data     []byte
 	endpoint string
+	timeout  time.Duration
 	resChan  chan res
 }
 
@@ -38,7 +42,13 @@ func NewClient(zmqCtx *zmq.Context, log log.Logger) *Client {
 
 // Send connects to the endpoint per each message since it is more appropriate8
 // with DIDComm as by nature it manifests an asynchronous simplex communication.
+// Acknowledgements are returned as empty responses and problem reports which are
+// not packed as *domain.ProblemError.
 func (c *Client) Send(typ models.MsgType, data []byte, endpoint string) (response string, err error) {
+	return c.SendWithTimeout(typ, data, endpoint, domain.SendTimeoutMs*time.Millisecond)
+}
+
+func (c *Client) SendWithTimeout(typ models.MsgType, data []byte, endpoint string, timeout time.Duration) (response string, err error) {
 	inChan, ok := c.sendr(endpoint)
 	if !ok {
 		inChan = make(chan req)
@@ -46,10 +56,10 @@ func (c *Client) Send(typ models.MsgType, data []byte, endpoint string) (respons
 	}
 
 	resChan := make(chan res)
-	inChan <- req{typ: typ, data: data, endpoint: endpoint, resChan: resChan}
+	inChan <- req{typ: typ, data: data, endpoint: endpoint, timeout: timeout, resChan: resChan}
 	resMsg := <-resChan
 	if resMsg.err != nil {
-		return ``, fmt.Errorf(`send error - %v`, resMsg.err)
+		return ``, fmt.Errorf(`send error - %w`, resMsg.err)
 	}
 
 	return resMsg.msg, nil
@@ -71,15 +81,7 @@ func (c *Client) sendr(endpoint string) (inChan chan req, ok bool) {
 
 func (c *Client) initSendr(endpoint string, inChan chan req) {
 	c.chanMap.Store(endpoint, inChan)
-	skt, err := c.ctx.NewSocket(zmq.REQ)
-	if err != nil {
-		c.log.Fatal(fmt.Sprintf(`creating new socket for endpoint %s failed - %v`, endpoint, err))
-	}
-
-	if err = skt.Connect(endpoint); err != nil {
-		c.log.Fatal(fmt.Sprintf(`connecting to zmq socket (%s) failed - %v`, endpoint, err))
-	}
-
+	skt := c.connect(endpoint)
 	for {
 		reqMsg := <-inChan
 		if string(reqMsg.data) == domain.MsgTerminate {
@@ -88,20 +90,29 @@ func (c *Client) initSendr(endpoint string, inChan chan req) {
 
 		metaByts, err := json.Marshal(metadata{Type: int(reqMsg.typ)})
 		if err != nil {
-			reqMsg.resChan <- res{msg: ``, err: fmt.Errorf(`marshalling metadata failed - %v`, err)}
+			reqMsg.resChan <- res{msg: ``, err: services.NotSent(fmt.Errorf(`marshalling metadata failed - %v`, err))}
+			continue
+		}
+
+		if err = skt.SetRcvtimeo(reqMsg.timeout); err != nil {
+			reqMsg.resChan <- res{msg: ``, err: services.NotSent(fmt.Errorf(`setting receive timeout failed - %v`, err))}
 			continue
 		}
 
 		if _, err = skt.SendMessage([][]byte{metaByts, reqMsg.data}); err != nil {
-			reqMsg.resChan <- res{msg: ``, err: fmt.Errorf(`sending zmq message by sender failed - %v`, err)}
+			reqMsg.resChan <- res{msg: ``, err: services.NotSent(fmt.Errorf(`sending zmq message by sender failed - %v`, err))}
 			continue
 		}
 
-	receive:
 		resMsgs, err := skt.RecvMessage(0)
 		if err != nil {
+			// a REQ socket can not send again until the reply is received
+			// and hence is replaced when the recipient does not respond
 			if err.Error() == errTempUnavail {
-				goto receive
+				skt.Close()
+				skt = c.connect(endpoint)
+				reqMsg.resChan <- res{msg: ``, err: fmt.Errorf(`no reply received from %s within %dms`, endpoint, reqMsg.timeout.Milliseconds())}
+				continue
 			}
 			reqMsg.resChan <- res{msg: ``, err: fmt.Errorf(`receiving zmq message by sender failed - %v`, err)}
 			continue
@@ -112,8 +123,14 @@ func (c *Client) initSendr(endpoint string, inChan chan req) {
 			continue
 		}
 
-		if resMsgs[0] == failedRes {
-			reqMsg.resChan <- res{msg: ``, err: fmt.Errorf(`received an error message`)}
+		if resMsgs[0] == successRes {
+			reqMsg.resChan <- res{msg: ``, err: nil}
+			continue
+		}
+
+		// problem reports of the recipient which are not packed are returned as errors
+		if err = problem.Parse([]byte(resMsgs[0])); err != nil {
+			reqMsg.resChan <- res{msg: ``, err: err}
 			continue
 		}
 
@@ -121,6 +138,26 @@ func (c *Client) initSendr(endpoint string, inChan chan req) {
 	}
 }
 
+// connect creates a socket which discards pending messages on close so that
+// messages to an unreachable recipient are not delivered after a timeout.
+// Receive timeout is set per message.
+func (c *Client) connect(endpoint string) *zmq.Socket {
+	skt, err := c.ctx.NewSocket(zmq.REQ)
+	if err != nil {
+		c.log.Fatal(fmt.Sprintf(`creating new socket for endpoint %s failed - %v`, endpoint, err))
+	}
+
+	if err = skt.SetLinger(0); err != nil {
+		c.log.Fatal(fmt.Sprintf(`setting linger of zmq socket (%s) failed - %v`, endpoint, err))
+	}
+
+	if err = skt.Connect(endpoint); err != nil {
+		c.log.Fatal(fmt.Sprintf(`connecting to zmq socket (%s) failed - %v`, endpoint, err))
+	}
+
+	return skt
+}
+
 func (c *Client) Close() error {
 	c.chanMap.Range(func(key, val any) bool {
 		inChan, ok := val.(chan req)
@@ -4,10 +4,7 @@ const (
 	errTempUnavail = `resource temporarily unavailable`
 )
 
-const (
-	successRes = `success`
-	failedRes  = `failed`
-)
+const successRes = `success`
 
 // metadata is sent in a separate frame to preserve
 // backward and forward compatibility
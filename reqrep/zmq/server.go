@@ -3,6 +3,7 @@ package zmq
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/YasiruR/didcomm-prober/didcomm/problem"
 	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/models"
@@ -10,6 +11,7 @@ import (
 	zmq "github.com/pebbe/zmq4"
 	"github.com/tryfix/log"
 	"sync"
+	"time"
 )
 
 type handler struct {
@@ -48,8 +50,16 @@ func (s *Server) AddHandler(mt models.MsgType, notifier chan models.Message, asy
 	s.handlrs.Store(mt, &handler{async: async, notifier: notifier})
 }
 
-func (s *Server) RemoveHandler(msgType string) {
-	s.handlrs.Delete(msgType)
+func (s *Server) RemoveHandler(mt models.MsgType) {
+	s.handlrs.Delete(mt)
+}
+
+func (s *Server) Handlers() (mts []models.MsgType) {
+	s.handlrs.Range(func(key, _ any) bool {
+		mts = append(mts, key.(models.MsgType))
+		return true
+	})
+	return mts
 }
 
 func (s *Server) Start() error {
@@ -92,25 +102,55 @@ func (s *Server) Start() error {
 			continue
 		}
 
-		m.Reply = make(chan []byte)
-		h.notifier <- m
-		s.sendRes(<-m.Reply)
+		// reply is buffered so that handlers exceeding the timeout are not blocked
+		m.Reply = make(chan []byte, 1)
+		s.sendRes(s.await(h, m))
 	}
 }
 
+// await hands over the message to the synchronous handler and returns its
+// response, or a problem report if the handler is busy or does not respond
+// within the timeout such that the server keeps accepting messages
+func (s *Server) await(h *handler, m models.Message) []byte {
+	timeout := time.After(domain.HandlerTimeoutMs * time.Millisecond)
+	err := fmt.Errorf(`no response from the handler of %s within %dms`, m.Type, domain.HandlerTimeoutMs)
+	select {
+	case h.notifier <- m:
+	case <-timeout:
+		s.log.Error(err)
+		return problem.Marshal(domain.ProblemTransport, err)
+	}
+
+	select {
+	case res := <-m.Reply:
+		return res
+	case <-timeout:
+		s.log.Error(err)
+		return problem.Marshal(domain.ProblemTransport, err)
+	}
+}
+
+// sendAck replies with a plaintext problem report for failures in the
+// transport since the sender of the message can not be identified
 func (s *Server) sendAck(err error) {
 	msg := successRes
 	if err != nil {
 		s.log.Error(err)
-		msg = failedRes
+		msg = string(problem.Marshal(domain.ProblemTransport, err))
 	}
 
 	if _, sendErr := s.skt.Send(msg, 0); sendErr != nil {
-		s.log.Error(fmt.Sprintf(`sending zmq ack message by receiver failed - %v`, err))
+		s.log.Error(fmt.Sprintf(`sending zmq ack message by receiver failed - %v`, sendErr))
 	}
 }
 
+// sendRes replies with the response of a synchronous handler where
+// an empty response acknowledges the message
 func (s *Server) sendRes(data []byte) {
+	if len(data) == 0 {
+		data = []byte(successRes)
+	}
+
 	if _, err := s.skt.Send(string(data), 0); err != nil {
 		s.log.Error(fmt.Sprintf(`sending zmq response message by receiver failed - %v`, err))
 	}
@@ -124,7 +164,7 @@ func (s *Server) handlrByTyp(msgTyp models.MsgType) (*handler, error) {
 
 	h, ok := val.(*handler)
 	if !ok {
-		return nil, fmt.Errorf(`invalid type for handler found for message type %s - should be *handler`)
+		return nil, fmt.Errorf(`invalid type for handler found for message type %s - should be *handler`, msgTyp)
 	}
 
 	return h, nil
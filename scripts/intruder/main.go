@@ -7,12 +7,15 @@ import (
 	"github.com/YasiruR/didcomm-prober/didcomm/connection"
 	"github.com/YasiruR/didcomm-prober/didcomm/did"
 	"github.com/YasiruR/didcomm-prober/didcomm/invitation"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	log3 "github.com/YasiruR/didcomm-prober/log"
 	"github.com/YasiruR/didcomm-prober/prober"
 	"github.com/YasiruR/didcomm-prober/pubsub"
 	reqRepZmq "github.com/YasiruR/didcomm-prober/reqrep/zmq"
+	"github.com/YasiruR/didcomm-prober/store"
 	zmq "github.com/pebbe/zmq4"
 	"github.com/tryfix/log"
 	log2 "log"
@@ -108,7 +111,10 @@ func init() {
 	ip := `tcp://` + ips[0].String() + `:`
 
 	logger := log3.NewLogger(true, 3, log3.LevelTrace)
-	packer := crypto.NewPacker(logger)
+	packers := map[domain.Envelope]services.Packer{
+		domain.EnvelopeRFC19: crypto.NewPacker(logger),
+		domain.EnvelopeV2:    crypto.NewJWEPacker(logger),
+	}
 	km := crypto.NewKeyManager()
 	ctx, err := zmq.NewContext()
 	if err != nil {
@@ -117,10 +123,11 @@ func init() {
 
 	cfg := container.Config{
 		Args: &container.Args{
-			Name:    "intruder",
-			Port:    9090,
-			Verbose: true,
-			PubPort: 9091,
+			Name:     "intruder",
+			Port:     9090,
+			Verbose:  true,
+			PubPort:  9091,
+			Envelope: domain.EnvelopeRFC19,
 		},
 		Hostname:    ip,
 		InvEndpoint: ip + strconv.Itoa(9090),
@@ -128,13 +135,19 @@ func init() {
 		LogLevel:    "DEBUG",
 	}
 
+	didHandler := did.NewHandler()
+	resolver := did.NewRegistry(didHandler)
 	c := &container.Container{
 		Cfg:          &cfg,
 		KeyManager:   km,
-		Packer:       packer,
-		DidAgent:     did.NewHandler(),
+		ConnStore:    store.NewMemory(),
+		GroupStore:   store.NewMemoryGroups(),
+		Packers:      packers,
+		Signer:       crypto.NewJWSSigner(),
+		DidAgent:     didHandler,
+		Resolver:     resolver,
 		Connector:    connection.NewConnector(),
-		OOB:          invitation.NewOOBService(&cfg),
+		OOB:          invitation.NewOOBService(&cfg, didHandler, resolver),
 		Client:       reqRepZmq.NewClient(ctx, logger),
 		Log:          logger,
 		ConnDoneChan: make(chan models.Connection),
@@ -6,12 +6,15 @@ import (
 	"github.com/YasiruR/didcomm-prober/didcomm/connection"
 	"github.com/YasiruR/didcomm-prober/didcomm/did"
 	"github.com/YasiruR/didcomm-prober/didcomm/invitation"
+	"github.com/YasiruR/didcomm-prober/domain"
 	"github.com/YasiruR/didcomm-prober/domain/container"
 	"github.com/YasiruR/didcomm-prober/domain/models"
+	"github.com/YasiruR/didcomm-prober/domain/services"
 	"github.com/YasiruR/didcomm-prober/log"
 	"github.com/YasiruR/didcomm-prober/prober"
 	"github.com/YasiruR/didcomm-prober/pubsub"
 	reqRepZmq "github.com/YasiruR/didcomm-prober/reqrep/zmq"
+	"github.com/YasiruR/didcomm-prober/store"
 	zmq "github.com/pebbe/zmq4"
 	log2 "log"
 	"net"
@@ -21,10 +24,11 @@ import (
 
 func InitAgent(name string, port, pubPort int) *container.Container {
 	return initContainer(setConfigs(&container.Args{
-		Name:    name,
-		Port:    port,
-		Verbose: true,
-		PubPort: pubPort,
+		Name:     name,
+		Port:     port,
+		Verbose:  true,
+		PubPort:  pubPort,
+		Envelope: domain.EnvelopeRFC19,
 	}))
 }
 
@@ -59,20 +63,29 @@ func setConfigs(args *container.Args) *container.Config {
 
 func initContainer(cfg *container.Config) *container.Container {
 	logger := log.NewLogger(cfg.Args.Verbose, 2, log.LevelWarn)
-	packer := crypto.NewPacker(logger)
+	packers := map[domain.Envelope]services.Packer{
+		domain.EnvelopeRFC19: crypto.NewPacker(logger),
+		domain.EnvelopeV2:    crypto.NewJWEPacker(logger),
+	}
 	km := crypto.NewKeyManager()
 	ctx, err := zmq.NewContext()
 	if err != nil {
 		logger.Fatal(`test-agent`, fmt.Sprintf(`zmq context initialization failed - %v`, err))
 	}
 
+	didHandler := did.NewHandler()
+	resolver := did.NewRegistry(didHandler)
 	c := &container.Container{
 		Cfg:          cfg,
 		KeyManager:   km,
-		Packer:       packer,
-		DidAgent:     did.NewHandler(),
+		ConnStore:    store.NewMemory(),
+		GroupStore:   store.NewMemoryGroups(),
+		Packers:      packers,
+		Signer:       crypto.NewJWSSigner(),
+		DidAgent:     didHandler,
+		Resolver:     resolver,
 		Connector:    connection.NewConnector(),
-		OOB:          invitation.NewOOBService(cfg),
+		OOB:          invitation.NewOOBService(cfg, didHandler, resolver),
 		Client:       reqRepZmq.NewClient(ctx, logger),
 		Log:          logger,
 		ConnDoneChan: make(chan models.Connection),
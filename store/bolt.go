@@ -0,0 +1,359 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	bktPeers   = []byte(`peers`)
+	bktDIDs    = []byte(`dids`)
+	bktGroups  = []byte(`groups`)
+	bktSubs    = []byte(`subscribers`)
+	bktInvs    = []byte(`invitations`)
+	bktInvRecs = []byte(`invitation-records`)
+	bktMed     = []byte(`mediation`)
+	bktMedPeer = []byte(`mediated`)
+	bktRoutes  = []byte(`routes`)
+	bktQueues  = []byte(`queues`)
+)
+
+// keyMediation is the key of the single mediation granted to the agent
+const keyMediation = `mediation`
+
+// Bolt persists connections and group state in an embedded bbolt database
+// file such that they are restored when the agent restarts
+type Bolt struct {
+	db *bolt.DB
+}
+
+func NewBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf(`opening database failed - %v`, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bkt := range [][]byte{bktPeers, bktDIDs, bktGroups, bktSubs, bktInvs, bktInvRecs, bktMed, bktMedPeer, bktRoutes, bktQueues} {
+			if _, err := tx.CreateBucketIfNotExists(bkt); err != nil {
+				return fmt.Errorf(`creating bucket %s failed - %v`, bkt, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Bolt{db: db}, nil
+}
+
+func (b *Bolt) AddPeer(label string, pr models.Peer) error {
+	return b.put(bktPeers, label, pr)
+}
+
+func (b *Bolt) Peer(label string) (models.Peer, error) {
+	var pr models.Peer
+	ok, err := b.get(bktPeers, label, &pr)
+	if err != nil {
+		return models.Peer{}, err
+	}
+
+	if !ok {
+		return models.Peer{}, fmt.Errorf(`requested peer (%s) does not exist in store`, label)
+	}
+
+	return pr, nil
+}
+
+func (b *Bolt) Peers() (map[string]models.Peer, error) {
+	prs := map[string]models.Peer{}
+	err := b.forEach(bktPeers, func(k string, v []byte) error {
+		var pr models.Peer
+		if err := json.Unmarshal(v, &pr); err != nil {
+			return fmt.Errorf(`unmarshalling peer %s failed - %v`, k, err)
+		}
+		prs[k] = pr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return prs, nil
+}
+
+func (b *Bolt) AddDID(label, did string, doc messages.DIDDocument) error {
+	return b.put(bktDIDs, label, ownDID{DID: did, Doc: doc})
+}
+
+func (b *Bolt) DID(label string) (did string, doc messages.DIDDocument, err error) {
+	var d ownDID
+	ok, err := b.get(bktDIDs, label, &d)
+	if err != nil {
+		return ``, messages.DIDDocument{}, err
+	}
+
+	if !ok {
+		return ``, messages.DIDDocument{}, fmt.Errorf(`did does not exist for %s`, label)
+	}
+
+	return d.DID, d.Doc, nil
+}
+
+func (b *Bolt) AddInvRecord(rec models.InvRecord) error {
+	return b.put(bktInvRecs, rec.Id, rec)
+}
+
+func (b *Bolt) InvRecord(id string) (models.InvRecord, error) {
+	var rec models.InvRecord
+	ok, err := b.get(bktInvRecs, id, &rec)
+	if err != nil {
+		return models.InvRecord{}, err
+	}
+
+	if !ok {
+		return models.InvRecord{}, fmt.Errorf(`requested invitation (%s) does not exist in store`, id)
+	}
+
+	return rec, nil
+}
+
+func (b *Bolt) InvRecords() (map[string]models.InvRecord, error) {
+	recs := map[string]models.InvRecord{}
+	err := b.forEach(bktInvRecs, func(k string, v []byte) error {
+		var rec models.InvRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return fmt.Errorf(`unmarshalling invitation record %s failed - %v`, k, err)
+		}
+		recs[k] = rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
+func (b *Bolt) DeleteInvRecord(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bktInvRecs).Delete([]byte(id))
+	})
+}
+
+func (b *Bolt) SaveMediation(m models.Mediation) error {
+	return b.put(bktMed, keyMediation, m)
+}
+
+func (b *Bolt) Mediation() (models.Mediation, error) {
+	var m models.Mediation
+	ok, err := b.get(bktMed, keyMediation, &m)
+	if err != nil {
+		return models.Mediation{}, err
+	}
+
+	if !ok {
+		return models.Mediation{}, fmt.Errorf(`mediation does not exist in store`)
+	}
+
+	return m, nil
+}
+
+func (b *Bolt) AddMediated(label string) error {
+	return b.put(bktMedPeer, label, true)
+}
+
+func (b *Bolt) Mediated(label string) (bool, error) {
+	var granted bool
+	if _, err := b.get(bktMedPeer, label, &granted); err != nil {
+		return false, err
+	}
+	return granted, nil
+}
+
+func (b *Bolt) AddRoute(key string, rt models.Route) error {
+	return b.put(bktRoutes, key, rt)
+}
+
+func (b *Bolt) Route(key string) (models.Route, error) {
+	var rt models.Route
+	ok, err := b.get(bktRoutes, key, &rt)
+	if err != nil {
+		return models.Route{}, err
+	}
+
+	if !ok {
+		return models.Route{}, fmt.Errorf(`route does not exist for %s`, key)
+	}
+
+	return rt, nil
+}
+
+func (b *Bolt) Routes() (map[string]models.Route, error) {
+	rts := map[string]models.Route{}
+	err := b.forEach(bktRoutes, func(k string, v []byte) error {
+		var rt models.Route
+		if err := json.Unmarshal(v, &rt); err != nil {
+			return fmt.Errorf(`unmarshalling route of %s failed - %v`, k, err)
+		}
+		rts[k] = rt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rts, nil
+}
+
+func (b *Bolt) DeleteRoute(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bktRoutes).Delete([]byte(key))
+	})
+}
+
+func (b *Bolt) SaveQueue(label string, msgs []models.QueuedMsg) error {
+	if len(msgs) == 0 {
+		return b.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bktQueues).Delete([]byte(label))
+		})
+	}
+	return b.put(bktQueues, label, msgs)
+}
+
+func (b *Bolt) Queues() (map[string][]models.QueuedMsg, error) {
+	qs := map[string][]models.QueuedMsg{}
+	err := b.forEach(bktQueues, func(k string, v []byte) error {
+		var msgs []models.QueuedMsg
+		if err := json.Unmarshal(v, &msgs); err != nil {
+			return fmt.Errorf(`unmarshalling queue of %s failed - %v`, k, err)
+		}
+		qs[k] = msgs
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return qs, nil
+}
+
+func (b *Bolt) SaveGroup(topic string, g models.Group) error {
+	return b.put(bktGroups, topic, g)
+}
+
+func (b *Bolt) Groups() (map[string]models.Group, error) {
+	grps := map[string]models.Group{}
+	err := b.forEach(bktGroups, func(k string, v []byte) error {
+		var g models.Group
+		if err := json.Unmarshal(v, &g); err != nil {
+			return fmt.Errorf(`unmarshalling group %s failed - %v`, k, err)
+		}
+		grps[k] = g
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grps, nil
+}
+
+func (b *Bolt) SaveSubscribers(topic string, subs map[string][]byte) error {
+	return b.put(bktSubs, topic, subs)
+}
+
+func (b *Bolt) Subscribers() (map[string]map[string][]byte, error) {
+	subs := map[string]map[string][]byte{}
+	err := b.forEach(bktSubs, func(k string, v []byte) error {
+		var s map[string][]byte
+		if err := json.Unmarshal(v, &s); err != nil {
+			return fmt.Errorf(`unmarshalling subscribers of %s failed - %v`, k, err)
+		}
+		subs[k] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (b *Bolt) SaveInvitation(topic, inv string) error {
+	return b.put(bktInvs, topic, inv)
+}
+
+func (b *Bolt) Invitations() (map[string]string, error) {
+	invs := map[string]string{}
+	err := b.forEach(bktInvs, func(k string, v []byte) error {
+		var inv string
+		if err := json.Unmarshal(v, &inv); err != nil {
+			return fmt.Errorf(`unmarshalling invitation of %s failed - %v`, k, err)
+		}
+		invs[k] = inv
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invs, nil
+}
+
+func (b *Bolt) DeleteGroup(topic string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, bkt := range [][]byte{bktGroups, bktSubs, bktInvs} {
+			if err := tx.Bucket(bkt).Delete([]byte(topic)); err != nil {
+				return fmt.Errorf(`deleting %s of %s failed - %v`, bkt, topic, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+func (b *Bolt) put(bkt []byte, key string, val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf(`marshalling value of %s failed - %v`, key, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bkt).Put([]byte(key), data)
+	})
+}
+
+func (b *Bolt) get(bkt []byte, key string, val any) (ok bool, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bkt).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		ok = true
+		if err := json.Unmarshal(data, val); err != nil {
+			return fmt.Errorf(`unmarshalling value of %s failed - %v`, key, err)
+		}
+		return nil
+	})
+
+	return ok, err
+}
+
+func (b *Bolt) forEach(bkt []byte, fn func(key string, val []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bkt).ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"fmt"
+	"github.com/YasiruR/didcomm-prober/domain/messages"
+	"github.com/YasiruR/didcomm-prober/domain/models"
+	"sync"
+)
+
+type ownDID struct {
+	DID string               `json:"did"`
+	Doc messages.DIDDocument `json:"doc"`
+}
+
+// Memory keeps connections only for the lifetime of the agent
+type Memory struct {
+	peers     *sync.Map // key: peer label
+	dids      *sync.Map // key: peer label
+	invs      *sync.Map // key: invitation id
+	mediation *sync.Map // key: label of the mediator
+	mediated  *sync.Map // key: peer label
+	routes    *sync.Map // key: base58 encoded recipient key
+	queues    *sync.Map // key: peer label
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		peers:     &sync.Map{},
+		dids:      &sync.Map{},
+		invs:      &sync.Map{},
+		mediation: &sync.Map{},
+		mediated:  &sync.Map{},
+		routes:    &sync.Map{},
+		queues:    &sync.Map{},
+	}
+}
+
+func (m *Memory) AddPeer(label string, pr models.Peer) error {
+	m.peers.Store(label, pr)
+	return nil
+}
+
+func (m *Memory) Peer(label string) (models.Peer, error) {
+	val, ok := m.peers.Load(label)
+	if !ok {
+		return models.Peer{}, fmt.Errorf(`requested peer (%s) does not exist in store`, label)
+	}
+	return val.(models.Peer), nil
+}
+
+func (m *Memory) Peers() (map[string]models.Peer, error) {
+	prs := map[string]models.Peer{}
+	m.peers.Range(func(key, val any) bool {
+		prs[key.(string)] = val.(models.Peer)
+		return true
+	})
+	return prs, nil
+}
+
+func (m *Memory) AddDID(label, did string, doc messages.DIDDocument) error {
+	m.dids.Store(label, ownDID{DID: did, Doc: doc})
+	return nil
+}
+
+func (m *Memory) DID(label string) (did string, doc messages.DIDDocument, err error) {
+	val, ok := m.dids.Load(label)
+	if !ok {
+		return ``, messages.DIDDocument{}, fmt.Errorf(`did does not exist for %s`, label)
+	}
+
+	d := val.(ownDID)
+	return d.DID, d.Doc, nil
+}
+
+func (m *Memory) AddInvRecord(rec models.InvRecord) error {
+	m.invs.Store(rec.Id, rec)
+	return nil
+}
+
+func (m *Memory) InvRecord(id string) (models.InvRecord, error) {
+	val, ok := m.invs.Load(id)
+	if !ok {
+		return models.InvRecord{}, fmt.Errorf(`requested invitation (%s) does not exist in store`, id)
+	}
+	return val.(models.InvRecord), nil
+}
+
+func (m *Memory) InvRecords() (map[string]models.InvRecord, error) {
+	recs := map[string]models.InvRecord{}
+	m.invs.Range(func(key, val any) bool {
+		recs[key.(string)] = val.(models.InvRecord)
+		return true
+	})
+	return recs, nil
+}
+
+func (m *Memory) DeleteInvRecord(id string) error {
+	m.invs.Delete(id)
+	return nil
+}
+
+func (m *Memory) SaveMediation(med models.Mediation) error {
+	m.mediation.Store(keyMediation, med)
+	return nil
+}
+
+func (m *Memory) Mediation() (models.Mediation, error) {
+	val, ok := m.mediation.Load(keyMediation)
+	if !ok {
+		return models.Mediation{}, fmt.Errorf(`mediation does not exist in store`)
+	}
+	return val.(models.Mediation), nil
+}
+
+func (m *Memory) AddMediated(label string) error {
+	m.mediated.Store(label, true)
+	return nil
+}
+
+func (m *Memory) Mediated(label string) (bool, error) {
+	_, ok := m.mediated.Load(label)
+	return ok, nil
+}
+
+func (m *Memory) AddRoute(key string, rt models.Route) error {
+	m.routes.Store(key, rt)
+	return nil
+}
+
+func (m *Memory) Route(key string) (models.Route, error) {
+	val, ok := m.routes.Load(key)
+	if !ok {
+		return models.Route{}, fmt.Errorf(`route does not exist for %s`, key)
+	}
+	return val.(models.Route), nil
+}
+
+func (m *Memory) Routes() (map[string]models.Route, error) {
+	rts := map[string]models.Route{}
+	m.routes.Range(func(key, val any) bool {
+		rts[key.(string)] = val.(models.Route)
+		return true
+	})
+	return rts, nil
+}
+
+func (m *Memory) DeleteRoute(key string) error {
+	m.routes.Delete(key)
+	return nil
+}
+
+func (m *Memory) SaveQueue(label string, msgs []models.QueuedMsg) error {
+	if len(msgs) == 0 {
+		m.queues.Delete(label)
+		return nil
+	}
+	m.queues.Store(label, msgs)
+	return nil
+}
+
+func (m *Memory) Queues() (map[string][]models.QueuedMsg, error) {
+	qs := map[string][]models.QueuedMsg{}
+	m.queues.Range(func(key, val any) bool {
+		qs[key.(string)] = val.([]models.QueuedMsg)
+		return true
+	})
+	return qs, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// MemoryGroups keeps group state only for the lifetime of the agent
+type MemoryGroups struct {
+	groups *sync.Map // key: topic
+	subs   *sync.Map // key: topic
+	invs   *sync.Map // key: topic
+}
+
+func NewMemoryGroups() *MemoryGroups {
+	return &MemoryGroups{groups: &sync.Map{}, subs: &sync.Map{}, invs: &sync.Map{}}
+}
+
+func (m *MemoryGroups) SaveGroup(topic string, g models.Group) error {
+	m.groups.Store(topic, g)
+	return nil
+}
+
+func (m *MemoryGroups) Groups() (map[string]models.Group, error) {
+	grps := map[string]models.Group{}
+	m.groups.Range(func(key, val any) bool {
+		grps[key.(string)] = val.(models.Group)
+		return true
+	})
+	return grps, nil
+}
+
+func (m *MemoryGroups) SaveSubscribers(topic string, subs map[string][]byte) error {
+	m.subs.Store(topic, subs)
+	return nil
+}
+
+func (m *MemoryGroups) Subscribers() (map[string]map[string][]byte, error) {
+	subs := map[string]map[string][]byte{}
+	m.subs.Range(func(key, val any) bool {
+		subs[key.(string)] = val.(map[string][]byte)
+		return true
+	})
+	return subs, nil
+}
+
+func (m *MemoryGroups) SaveInvitation(topic, inv string) error {
+	m.invs.Store(topic, inv)
+	return nil
+}
+
+func (m *MemoryGroups) Invitations() (map[string]string, error) {
+	invs := map[string]string{}
+	m.invs.Range(func(key, val any) bool {
+		invs[key.(string)] = val.(string)
+		return true
+	})
+	return invs, nil
+}
+
+func (m *MemoryGroups) DeleteGroup(topic string) error {
+	m.groups.Delete(topic)
+	m.subs.Delete(topic)
+	m.invs.Delete(topic)
+	return nil
+}